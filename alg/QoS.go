@@ -21,6 +21,15 @@ const (
 type QoSDeploymentInfo struct {
 	NumberOfPodOnEdge int
 	NumberOfPods      int
+	// Sum of the proximities of the pods on edge to the users,
+	// equal to NumberOfPodOnEdge in the share QoS model.
+	EdgeProximity float64
+}
+
+// Returns the share of the deployment's pods on edge,
+// weighted by how close they are to the users.
+func (info *QoSDeploymentInfo) EffectiveShare() float64 {
+	return info.EdgeProximity / float64(info.NumberOfPods)
 }
 
 type QoSResult struct {
@@ -33,6 +42,16 @@ const (
 	PRE_SATISFACTION   = 0.8
 )
 
+// Priorities are divided by the scale when weighing deployments' QoS, a
+// deployment with this priority counts twice as much as one with 0.
+const PRIORITY_SCALE = 1000
+
+// Returns the weight of the deployment's QoS in the score,
+// deployments with priority 0 or lower have weight 1.
+func PriorityWeight(deployment *model.Deployment) float64 {
+	return 1 + math.Max(float64(deployment.Priority), 0)/PRIORITY_SCALE
+}
+
 func QoS(currentShare, promisedShare float64) float64 {
 	if math.Abs(currentShare-promisedShare) < 1e-9 {
 		return SATISFACTION_SCORE
@@ -57,9 +76,13 @@ func CalcNumberOfQosSatisfactions(
 ) (QoSResult, error) {
 	deploymentPods := make(map[int]map[int]int)
 	deploymentsQoS := make(map[int]*QoSDeploymentInfo)
+	// the last known pod object of each pod id
+	pods := make(map[int]*model.Pod)
+
+	setState := func(state int, statePods []*model.Pod) error {
+		for _, pod := range statePods {
+			pods[pod.Id] = pod
 
-	setState := func(state int, pods []*model.Pod) error {
-		for _, pod := range pods {
 			_, ok := deploymentPods[pod.Deployment.Id]
 			if !ok {
 				deploymentPods[pod.Deployment.Id] = make(map[int]int)
@@ -97,28 +120,35 @@ func CalcNumberOfQosSatisfactions(
 
 	var score float64
 
-	for deploymentId, PodToState := range deploymentPods {
-		numberOfPods := 0
-		numberOfPodsOnEdge := 0
-
-		for _, state := range PodToState {
-			numberOfPods += 1
-			if (state & CLUSTER) > 0 {
-				numberOfPodsOnEdge += 1
-			}
-		}
+	edgeNodes := make(map[int]*model.Node)
+	for _, node := range edgeConfig.Nodes {
+		edgeNodes[node.Id] = node
+	}
 
+	for deploymentId, PodToState := range deploymentPods {
 		deployment, ok := edgeConfig.DeploymentIdToDeployment[deploymentId]
 		if !ok {
 			return QoSResult{}, fmt.Errorf("one of the deployment with id %d is not configured at first", deploymentId)
 		}
 
-		score += QoS(float64(numberOfPodsOnEdge)/float64(numberOfPods), deployment.EdgeShare)
+		info := &QoSDeploymentInfo{}
+		for podId, state := range PodToState {
+			info.NumberOfPods += 1
+			if (state & CLUSTER) == 0 {
+				continue
+			}
 
-		deploymentsQoS[deploymentId] = &QoSDeploymentInfo{
-			NumberOfPodOnEdge: numberOfPodsOnEdge,
-			NumberOfPods:      numberOfPods,
+			info.NumberOfPodOnEdge += 1
+			// New edge pods are not mapped to their nodes yet.
+			if node, ok := edgeNodes[nodeIdOf(pods[podId])]; ok && state == PRE_EDGE {
+				info.EdgeProximity += Proximity(deployment, node)
+			} else {
+				info.EdgeProximity += bestProximity(edgeConfig, deployment)
+			}
 		}
+
+		score += PriorityWeight(deployment) * QoS(info.EffectiveShare(), deployment.EdgeShare)
+		deploymentsQoS[deploymentId] = info
 	}
 
 	return QoSResult{
@@ -126,3 +156,12 @@ func CalcNumberOfQosSatisfactions(
 		DeploymentsQoS: deploymentsQoS,
 	}, nil
 }
+
+// Returns the id of the pod's node, -1 if it is not on any node.
+func nodeIdOf(pod *model.Pod) int {
+	if pod.Node == nil {
+		return -1
+	}
+
+	return pod.Node.Id
+}
@@ -0,0 +1,89 @@
+package alg
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Names of the algorithms that are shipped with the scheduler.
+const (
+	QOS_ALGORITHM       = "qos"
+	FIRST_FIT_ALGORITHM = "first_fit"
+)
+
+// A scheduling algorithm, used by both the scheduler and the simulator.
+// Implementations must not change the given cluster state.
+type Algorithm interface {
+	// Decides which new pods go to edge and which go to cloud,
+	// and which pods on edge should be offloaded or migrated for them.
+	// If canMigrate is false, pods on edge must stay where they are.
+	DecideForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) model.DecisionForNewPods
+	// Maps the pods that are decided to go to edge to edge nodes,
+	// assuming the freed pods are removed and the migrations are done.
+	MapPodToEdge(c *model.ClusterState, pods []*model.Pod, freedPods []*model.Pod, migrations []*model.Migration) model.EdgePodMapping
+	// Suggests pods on cloud that should be moved to edge,
+	// with the decision for placing them.
+	SuggestReorder(c *model.ClusterState) model.ReorderSuggestion
+}
+
+// Registered algorithms' constructors by their names.
+var algorithms = make(map[string]func() Algorithm)
+
+// Makes the algorithm selectable by its name in config,
+// custom algorithms should be registered in their package's init.
+func Register(name string, newAlgorithm func() Algorithm) {
+	if _, ok := algorithms[name]; ok {
+		panic(fmt.Sprintf("algorithm %s is registered twice", name))
+	}
+
+	algorithms[name] = newAlgorithm
+}
+
+// Returns a new instance of the algorithm registered with the name,
+// the QoS algorithm if the name is empty.
+func Get(name string) (Algorithm, error) {
+	if name == "" {
+		name = QOS_ALGORITHM
+	}
+
+	newAlgorithm, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("algorithm %q is not registered, registered algorithms are %v", name, Names())
+	}
+
+	return newAlgorithm(), nil
+}
+
+// Returns the names of all registered algorithms, sorted.
+func Names() []string {
+	var names []string
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func init() {
+	Register(QOS_ALGORITHM, func() Algorithm { return &qosAlgorithm{} })
+	Register(FIRST_FIT_ALGORITHM, func() Algorithm { return &firstFitAlgorithm{} })
+}
+
+// The scheduler's own algorithm, which maximizes the
+// QoS of deployments and reorders pods to keep edge defragmented.
+type qosAlgorithm struct{}
+
+func (*qosAlgorithm) DecideForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) model.DecisionForNewPods {
+	return MakeDecisionForNewPods(c, newPods, canMigrate)
+}
+
+func (*qosAlgorithm) MapPodToEdge(c *model.ClusterState, pods []*model.Pod, freedPods []*model.Pod, migrations []*model.Migration) model.EdgePodMapping {
+	return MapPodToEdge(c, pods, freedPods, migrations)
+}
+
+func (*qosAlgorithm) SuggestReorder(c *model.ClusterState) model.ReorderSuggestion {
+	return SuggestReorder(c)
+}
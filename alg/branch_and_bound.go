@@ -0,0 +1,467 @@
+package alg
+
+import (
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Maximum number of partial edge/cloud splits that the branch and bound
+// solver visits for each choice of the pod groups that go to edge,
+// after that the best found split is returned.
+const BRANCH_AND_BOUND_MAXIMUM_VISITS = 200000
+
+// Maximum number of pod groups of the new pods that the branch and
+// bound solver searches each choice of, with more of them
+// the exhaustive solver decides.
+const BRANCH_AND_BOUND_MAXIMUM_POD_GROUPS = 10
+
+// Scores closer than this are considered equal.
+const SCORE_EPSILON = 1e-9
+
+// New pods of a deployment are interchangeable, so the
+// branch and bound solver only decides how many of them go to edge.
+type podGroup struct {
+	deployment *model.Deployment
+	// indices of the group's pods in new pods,
+	// the ones that must go to edge first
+	indices []int
+	// number of the group's pods that must go to edge
+	minimum int
+	// gains[k] is the deployment's QoS if k of the group's
+	// pods go to edge (without freeing any pod)
+	gains []float64
+	// upper concave envelope of gains,
+	// as the list of k's on the envelope
+	hull []int
+}
+
+type branchAndBoundSolver struct {
+	c          *model.ClusterState
+	newPods    []*model.Pod
+	canMigrate bool
+
+	// names of the pod groups (of model.PodGroup) of the new pods
+	// that go to edge, the others go to cloud
+	edgePodGroups map[string]bool
+
+	groups []*podGroup
+	// QoS of deployments that have no new pods
+	othersScore float64
+	// edge resources that the new pods can use at most
+	limit *mat.VecDense
+
+	// visits of the current choice of the pod groups
+	visits int
+	// whether the search stopped at the maximum visits for some choice
+	isCut     bool
+	bestMask  []bool
+	bestScore float64
+	splits    splitRecorder
+}
+
+// Finds the best decision like the exhaustive solver by searching over the
+// number of new pods of each deployment that go to edge, and pruning
+// the search with an upper bound of the score which is relaxed
+// from the edge resources (a fractional knapsack for each resource).
+// Pods which should be freed are only computed for complete splits
+// and migrations only for the chosen split.
+// Between splits with equal scores, the first pods of each deployment
+// are chosen like the exhaustive solver, but between different
+// deployments the chosen split may differ.
+// Pod groups go to edge or to cloud as a whole, so the search is
+// done for each choice of the pod groups that go to edge.
+func BranchAndBoundDecisionForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) model.DecisionForNewPods {
+	decision, _ := branchAndBoundDecisionForNewPods(c, newPods, canMigrate)
+
+	return decision
+}
+
+// Returns the decision and whether the search is complete, the
+// decision is the best one only if the search is not cut.
+func branchAndBoundDecisionForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) (model.DecisionForNewPods, bool) {
+	solver := &branchAndBoundSolver{
+		c:          c,
+		newPods:    newPods,
+		canMigrate: canMigrate,
+		bestScore:  math.Inf(-1),
+	}
+
+	if canMigrate {
+		solver.limit = utils.SubVec(c.Edge.Config.Resources, c.EdgeReservedResources())
+	} else {
+		solver.limit = utils.SubVec(c.Edge.Config.Resources, c.Edge.UsedResources)
+	}
+
+	var podGroupNames []string
+	for name := range model.PodsByGroup(newPods) {
+		podGroupNames = append(podGroupNames, name)
+	}
+	sort.Strings(podGroupNames)
+	if len(podGroupNames) > BRANCH_AND_BOUND_MAXIMUM_POD_GROUPS {
+		log.Warn().Msgf("%d pod groups are too many for branch and bound, deciding exhaustively", len(podGroupNames))
+
+		return ExhaustiveDecisionForNewPods(c, newPods, canMigrate), true
+	}
+
+	for podGroupsMask := 0; podGroupsMask < (1 << len(podGroupNames)); podGroupsMask++ {
+		solver.edgePodGroups = make(map[string]bool)
+		for i, name := range podGroupNames {
+			if podGroupsMask&(1<<i) > 0 {
+				solver.edgePodGroups[name] = true
+			}
+		}
+
+		if err := solver.setUpGroups(); err != nil {
+			log.Err(err).Send()
+
+			return model.DecisionForNewPods{
+				Score: math.Inf(-1),
+			}, false
+		}
+
+		solver.visits = 0
+		solver.seedGreedily()
+		solver.search(0, make([]int, len(solver.groups)), mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil), 0)
+		if solver.visits >= BRANCH_AND_BOUND_MAXIMUM_VISITS {
+			log.Warn().Msgf("branch and bound stopped after %d visits, the decision may not be the best", solver.visits)
+			solver.isCut = true
+		}
+	}
+
+	if solver.bestMask == nil {
+		return model.DecisionForNewPods{
+			Score: math.Inf(-1),
+		}, !solver.isCut
+	}
+
+	// The best split may not be possible once its migrations are
+	// computed, then the next best recorded splits are tried.
+	masks := [][]bool{solver.bestMask}
+	for _, candidate := range solver.splits.best {
+		masks = append(masks, solver.maskOfPods(candidate.EdgePods))
+	}
+	for ind, mask := range masks {
+		if slices.ContainsFunc(masks[:ind], func(tried []bool) bool { return slices.Equal(tried, mask) }) {
+			continue
+		}
+
+		edgeNewPods, cloudNewPods := solver.split(mask)
+		decision, ok := evalDecision(c, edgeNewPods, cloudNewPods, canMigrate, true)
+		if !ok {
+			log.Warn().Msgf("split with %d new pods on edge is not possible with migrations, trying the next one", len(edgeNewPods))
+			continue
+		}
+		solver.splits.fill(&decision)
+
+		return decision, !solver.isCut
+	}
+
+	return model.DecisionForNewPods{
+		Score: math.Inf(-1),
+	}, !solver.isCut
+}
+
+func (s *branchAndBoundSolver) setUpGroups() error {
+	s.groups = nil
+	s.othersScore = 0
+
+	groupOf := make(map[int]*podGroup)
+	// Pods of the pod groups that go to edge are put first, so
+	// they are in every split. The ones that go to cloud are left out.
+	var cloudPods []*model.Pod
+	for _, mustGoToEdge := range []bool{true, false} {
+		for ind, pod := range s.newPods {
+			if pod.Group != nil && !s.edgePodGroups[pod.Group.Name] {
+				if mustGoToEdge {
+					cloudPods = append(cloudPods, pod)
+				}
+				continue
+			}
+			if (pod.Group != nil) != mustGoToEdge {
+				continue
+			}
+
+			group, ok := groupOf[pod.Deployment.Id]
+			if !ok {
+				group = &podGroup{
+					deployment: pod.Deployment,
+				}
+				groupOf[pod.Deployment.Id] = group
+				s.groups = append(s.groups, group)
+			}
+
+			group.indices = append(group.indices, ind)
+			if mustGoToEdge {
+				group.minimum++
+			}
+		}
+	}
+	s.othersScore -= cloudCost(s.c, cloudPods)
+
+	// Current state of all deployments, treating new pods as on cloud.
+	qosResult, err := CalcNumberOfQosSatisfactions(s.c.Edge.Config, s.c.Cloud.Pods, s.c.Edge.Pods, s.newPods, nil)
+	if err != nil {
+		return err
+	}
+
+	for deploymentId, info := range qosResult.DeploymentsQoS {
+		deployment := s.c.Edge.Config.DeploymentIdToDeployment[deploymentId]
+
+		group, ok := groupOf[deploymentId]
+		if !ok {
+			s.othersScore += PriorityWeight(deployment) * QoS(info.EffectiveShare(), deployment.EdgeShare)
+			continue
+		}
+
+		// Every new pod costs on cloud, the ones on edge save it.
+		podCost := cloudCost(s.c, []*model.Pod{s.newPods[group.indices[0]]})
+		s.othersScore -= float64(len(group.indices)) * podCost
+		proximity := bestProximity(s.c.Edge.Config, deployment)
+		for k := 0; k <= len(group.indices); k++ {
+			group.gains = append(group.gains, PriorityWeight(deployment)*QoS(
+				(info.EdgeProximity+float64(k)*proximity)/float64(info.NumberOfPods),
+				deployment.EdgeShare,
+			)+float64(k)*podCost)
+		}
+		group.hull = upperConcaveHull(group.gains)
+	}
+
+	// Deployments that gain more QoS per resource are searched
+	// first, so good splits are found early and prune the rest.
+	sort.SliceStable(s.groups, func(i, j int) bool {
+		return s.efficiency(s.groups[i]) > s.efficiency(s.groups[j])
+	})
+
+	return nil
+}
+
+// Returns the QoS that the group gains per resource it takes on edge,
+// resources are normalized by the edge resources the new pods can use.
+func (s *branchAndBoundSolver) efficiency(group *podGroup) float64 {
+	var size float64
+	for dim := 0; dim < s.limit.Len(); dim++ {
+		if s.limit.AtVec(dim) > 0 {
+			size += group.deployment.ResourcesRequired.AtVec(dim) / s.limit.AtVec(dim)
+		}
+	}
+
+	return (group.gains[len(group.gains)-1] - group.gains[0]) / float64(len(group.indices)) / math.Max(size, SCORE_EPSILON)
+}
+
+func (s *branchAndBoundSolver) search(next int, counts []int, needed *mat.VecDense, partialScore float64) {
+	if s.visits >= BRANCH_AND_BOUND_MAXIMUM_VISITS {
+		return
+	}
+	s.visits++
+
+	if next == len(s.groups) {
+		s.evaluate(s.maskOf(counts))
+		return
+	}
+
+	// Only splits with strictly better scores are searched for.
+	available := utils.SubVec(s.limit, needed)
+	if s.othersScore+partialScore+s.bound(next, available) < s.bestScore+SCORE_EPSILON {
+		return
+	}
+
+	group := s.groups[next]
+	for k := len(group.indices); k >= group.minimum; k-- {
+		current := mat.NewVecDense(needed.Len(), nil)
+		current.AddScaledVec(needed, float64(k), group.deployment.ResourcesRequired)
+		if !utils.LEThan(current, s.limit) {
+			continue
+		}
+
+		counts[next] = k
+		s.search(next+1, counts, current, partialScore+group.gains[k])
+	}
+	counts[next] = 0
+}
+
+// Returns an upper bound of the QoS of the groups from next,
+// given the available resources.
+func (s *branchAndBoundSolver) bound(next int, available *mat.VecDense) float64 {
+	type item struct {
+		size float64
+		gain float64
+	}
+
+	var base, full float64
+	for _, group := range s.groups[next:] {
+		base += group.gains[0]
+		full += group.gains[len(group.gains)-1]
+	}
+
+	ret := full
+	for dim := 0; dim < available.Len(); dim++ {
+		total := base
+		var items []item
+
+		for _, group := range s.groups[next:] {
+			for i := 1; i < len(group.hull); i++ {
+				from, to := group.hull[i-1], group.hull[i]
+				gain := group.gains[to] - group.gains[from]
+				size := float64(to-from) * group.deployment.ResourcesRequired.AtVec(dim)
+
+				if size <= 0 {
+					total += gain
+					continue
+				}
+				items = append(items, item{size: size, gain: gain})
+			}
+		}
+
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].gain/items[i].size > items[j].gain/items[j].size
+		})
+
+		capacity := available.AtVec(dim)
+		for _, it := range items {
+			if capacity <= 0 {
+				break
+			}
+
+			if it.size <= capacity {
+				total += it.gain
+				capacity -= it.size
+			} else {
+				total += it.gain * capacity / it.size
+				capacity = 0
+			}
+		}
+
+		ret = math.Min(ret, total)
+	}
+
+	return ret
+}
+
+// Finds a good split before the search, so the search can prune more.
+// Deployments that gain more QoS per resource are moved to edge first,
+// pod by pod, as long as the pods fit and the score gets better.
+func (s *branchAndBoundSolver) seedGreedily() {
+
+	counts := make([]int, len(s.groups))
+	for ind, group := range s.groups {
+		counts[ind] = group.minimum
+	}
+	mask := s.maskOf(counts)
+	score, ok := s.evaluate(mask)
+	if !ok {
+		return
+	}
+
+	for _, group := range s.groups {
+		for _, podInd := range group.indices[group.minimum:] {
+			mask[podInd] = true
+			newScore, ok := s.evaluate(mask)
+			if !ok || newScore <= score {
+				mask[podInd] = false
+				break
+			}
+			score = newScore
+		}
+	}
+}
+
+func (s *branchAndBoundSolver) maskOf(counts []int) []bool {
+	mask := make([]bool, len(s.newPods))
+	for ind, group := range s.groups {
+		for _, podInd := range group.indices[:counts[ind]] {
+			mask[podInd] = true
+		}
+	}
+
+	return mask
+}
+
+func (s *branchAndBoundSolver) maskOfPods(pods []*model.Pod) []bool {
+	ids := make(map[int]bool)
+	for _, pod := range pods {
+		ids[pod.Id] = true
+	}
+
+	mask := make([]bool, len(s.newPods))
+	for ind, pod := range s.newPods {
+		mask[ind] = ids[pod.Id]
+	}
+
+	return mask
+}
+
+// Evaluates the split and keeps it if it is the best one yet.
+func (s *branchAndBoundSolver) evaluate(mask []bool) (float64, bool) {
+	edgeNewPods, cloudNewPods := s.split(mask)
+	decision, ok := evalDecision(s.c, edgeNewPods, cloudNewPods, s.canMigrate, false)
+	if !ok {
+		return 0, false
+	}
+	s.splits.add(edgeNewPods, decision.Score)
+
+	// The exhaustive solver chooses the smallest mask between equal scores.
+	isBetter := decision.Score > s.bestScore+SCORE_EPSILON
+	isEqual := math.Abs(decision.Score-s.bestScore) <= SCORE_EPSILON
+	if isBetter || (isEqual && isSmallerMask(mask, s.bestMask)) {
+		s.bestScore = decision.Score
+		s.bestMask = append([]bool(nil), mask...)
+	}
+
+	return decision.Score, true
+}
+
+func (s *branchAndBoundSolver) split(mask []bool) ([]*model.Pod, []*model.Pod) {
+	edgeNewPods := make([]*model.Pod, 0)
+	cloudNewPods := make([]*model.Pod, 0)
+
+	for i, pod := range s.newPods {
+		if mask[i] {
+			edgeNewPods = append(edgeNewPods, pod)
+		} else {
+			cloudNewPods = append(cloudNewPods, pod)
+		}
+	}
+
+	return edgeNewPods, cloudNewPods
+}
+
+// Compares masks as binary numbers, the first pod being the least significant bit.
+func isSmallerMask(a, b []bool) bool {
+	if b == nil {
+		return true
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i] != b[i] {
+			return b[i]
+		}
+	}
+
+	return false
+}
+
+// Returns the points (as x's) on the upper concave envelope
+// of the points (x, values[x]).
+func upperConcaveHull(values []float64) []int {
+	var hull []int
+	for x := range values {
+		for len(hull) >= 2 {
+			a, b := hull[len(hull)-2], hull[len(hull)-1]
+			// b is under the segment a -> x
+			if (values[b]-values[a])*float64(x-a) <= (values[x]-values[a])*float64(b-a) {
+				hull = hull[:len(hull)-1]
+			} else {
+				break
+			}
+		}
+		hull = append(hull, x)
+	}
+
+	return hull
+}
@@ -0,0 +1,359 @@
+package alg
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+	"gonum.org/v1/gonum/mat"
+)
+
+func podIds(pods []*model.Pod) map[int]bool {
+	ret := make(map[int]bool)
+	for _, pod := range pods {
+		ret[pod.Id] = true
+	}
+
+	return ret
+}
+
+func sameIds(a, b []*model.Pod) bool {
+	aIds, bIds := podIds(a), podIds(b)
+	if len(aIds) != len(bIds) {
+		return false
+	}
+	for id := range aIds {
+		if !bIds[id] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func expectSameDecision(t *testing.T, clusterState *model.ClusterState, newPods []*model.Pod, canMigrate bool) {
+	want := ExhaustiveDecisionForNewPods(clusterState, newPods, canMigrate)
+	got := BranchAndBoundDecisionForNewPods(clusterState, newPods, canMigrate)
+
+	if math.Abs(want.Score-got.Score) > SCORE_EPSILON && !(math.IsInf(want.Score, -1) && math.IsInf(got.Score, -1)) {
+		t.Fatalf("got score %f, wanted %f", got.Score, want.Score)
+	}
+	if !sameIds(want.ToEdgePods, got.ToEdgePods) ||
+		!sameIds(want.ToCloudPods, got.ToCloudPods) ||
+		!sameIds(want.EdgeToCloudOffloadingPods, got.EdgeToCloudOffloadingPods) {
+		t.Fatalf("got a different decision than the exhaustive solver")
+	}
+}
+
+func TestBranchAndBoundMatchesExhaustive(t *testing.T) {
+	setUp()
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 2, EdgeShare: 0.5},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 0.5},
+		{Name: "C", Cpu: 0.5, Memory: 1, EdgeShare: 1},
+		{Name: "D", Cpu: 2, Memory: 4, EdgeShare: 1},
+	})
+
+	t.Run("Empty edge", func(t *testing.T) {
+		clusterState := builder.GetCluster(
+			map[*testing_tool.NodeDesc][]string{
+				{Cpu: 2, Memory: 4}: {},
+				{Cpu: 2, Memory: 2}: {},
+				{Cpu: 2, Memory: 3}: {},
+			},
+			[]string{},
+		)
+
+		expectSameDecision(t, clusterState, builder.GetPods([]string{"A", "A", "B", "B"}), true)
+	})
+
+	t.Run("Full edge", func(t *testing.T) {
+		clusterState := builder.GetCluster(
+			map[*testing_tool.NodeDesc][]string{
+				{Cpu: 2, Memory: 4}: {"A", "A"},
+				{Cpu: 2, Memory: 2}: {"B"},
+				{Cpu: 2, Memory: 3}: {"C", "C", "B"},
+			},
+			[]string{"A", "B"},
+		)
+
+		expectSameDecision(t, clusterState, builder.GetPods([]string{"D", "C", "B", "A"}), true)
+		expectSameDecision(t, clusterState, builder.GetPods([]string{"D", "C", "B", "A"}), false)
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		random := rand.New(rand.NewSource(1))
+		names := []string{"A", "B", "C", "D"}
+
+		for iter := 0; iter < 30; iter++ {
+			edge := make(map[*testing_tool.NodeDesc][]string)
+			for _, nodeDesc := range []*testing_tool.NodeDesc{
+				{Cpu: 2, Memory: 4},
+				{Cpu: 2, Memory: 2},
+				{Cpu: 2, Memory: 3},
+			} {
+				edge[nodeDesc] = nil
+				if random.Intn(2) == 0 {
+					edge[nodeDesc] = []string{"C"}
+				}
+			}
+
+			var cloud, newPods []string
+			for i := 0; i < random.Intn(3); i++ {
+				cloud = append(cloud, names[random.Intn(len(names))])
+			}
+			for i := 0; i < 1+random.Intn(8); i++ {
+				newPods = append(newPods, names[random.Intn(len(names))])
+			}
+
+			clusterState := builder.GetCluster(edge, cloud)
+			pods := builder.GetPods(newPods)
+
+			want := ExhaustiveDecisionForNewPods(clusterState, pods, iter%2 == 0)
+			got := BranchAndBoundDecisionForNewPods(clusterState, pods, iter%2 == 0)
+			if math.Abs(want.Score-got.Score) > SCORE_EPSILON {
+				t.Fatalf("got score %f, wanted %f for new pods %v", got.Score, want.Score, newPods)
+			}
+		}
+	})
+}
+
+func TestBranchAndBoundWithCloudCost(t *testing.T) {
+	setUp()
+	config.SchedulerGeneralConfig.CloudCostWeight = 1
+	defer setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 2, EdgeShare: 0.5},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 0.2},
+		{Name: "C", Cpu: 0.5, Memory: 1, EdgeShare: 0},
+	})
+
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 4}: {"A"},
+			{Cpu: 2, Memory: 3}: {},
+		},
+		[]string{"B"},
+	)
+	cloudNode := clusterState.Cloud.Nodes[0]
+	cloudNode.Resources = mat.NewVecDense(2, []float64{8, 16})
+	cloudNode.Cost = 4
+
+	newPods := builder.GetPods([]string{"A", "B", "C", "C"})
+	expectSameDecision(t, clusterState, newPods, true)
+	expectSameDecision(t, clusterState, newPods, false)
+
+	// All the pods fit on edge, so none of them should cost on cloud.
+	decision := ExhaustiveDecisionForNewPods(clusterState, newPods, false)
+	if len(decision.ToCloudPods) != 0 {
+		t.Errorf("got %d pods on cloud, wanted all of them on edge", len(decision.ToCloudPods))
+	}
+
+	// The decision's score is its QoS minus the cost of its cloud pods.
+	split, ok := evalDecision(clusterState, newPods[:2], newPods[2:], false, false)
+	if !ok {
+		t.Fatal("the split is not possible")
+	}
+	qosResult, err := CalcNumberOfQosSatisfactions(clusterState.Edge.Config, clusterState.Cloud.Pods, clusterState.Edge.Pods, newPods[2:], newPods[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// C pods take 1/16 of the cloud node's memory.
+	if cost := qosResult.Score - split.Score; math.Abs(cost-2*4.0/16) > SCORE_EPSILON {
+		t.Errorf("got cloud cost %f, wanted %f", cost, 2*4.0/16)
+	}
+}
+
+func TestBranchAndBoundFallsBackToNextSplit(t *testing.T) {
+	setUp()
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 2, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 0.5},
+	})
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 4}: {},
+		},
+		[]string{},
+	)
+	newPods := builder.GetPods([]string{"A", "B"})
+
+	best := BranchAndBoundDecisionForNewPods(clusterState, newPods, true)
+	if len(best.ToEdgePods) == 0 {
+		t.Fatal("the best split should put pods on edge")
+	}
+	neededOf := func(pods []*model.Pod) *mat.VecDense {
+		needed := mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
+		for _, pod := range pods {
+			needed.AddVec(needed, pod.Deployment.ResourcesRequired)
+		}
+		return needed
+	}
+	failing := neededOf(best.ToEdgePods)
+
+	// The best split passes the bound but its migrations can not be computed.
+	calcState = func(c *model.ClusterState, neededResources *mat.VecDense, maximumPriority int32) (model.FreeEdgeSolution, error) {
+		if mat.Equal(neededResources, failing) {
+			return model.FreeEdgeSolution{}, fmt.Errorf("resources %v can not be freed", neededResources)
+		}
+		return CalcState(c, neededResources, maximumPriority)
+	}
+	defer func() { calcState = CalcState }()
+
+	var wanted *model.SplitCandidate
+	for _, split := range best.BestSplits {
+		if !mat.Equal(neededOf(split.EdgePods), failing) {
+			wanted = split
+			break
+		}
+	}
+	if wanted == nil {
+		t.Fatal("no other split is recorded")
+	}
+
+	got := BranchAndBoundDecisionForNewPods(clusterState, newPods, true)
+	if math.IsInf(got.Score, -1) {
+		t.Fatal("got no decision, wanted the next split")
+	}
+	if !sameIds(got.ToEdgePods, wanted.EdgePods) || math.Abs(got.Score-wanted.Score) > SCORE_EPSILON {
+		t.Errorf("got %d pods on edge with score %f, wanted %d with score %f", len(got.ToEdgePods), got.Score, len(wanted.EdgePods), wanted.Score)
+	}
+}
+
+func TestBranchAndBoundManyPodGroups(t *testing.T) {
+	setUp()
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 0.5, Memory: 0.5, EdgeShare: 1},
+	})
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {},
+		},
+		[]string{},
+	)
+
+	// Each pod is a group of its own, one more than branch and bound searches.
+	var newPodsDesc []string
+	for i := 0; i <= BRANCH_AND_BOUND_MAXIMUM_POD_GROUPS; i++ {
+		newPodsDesc = append(newPodsDesc, "A")
+	}
+	newPods := builder.GetPods(newPodsDesc)
+	for ind, pod := range newPods {
+		pod.Group = &model.PodGroup{Name: fmt.Sprintf("group-%d", ind), Size: 1}
+	}
+
+	want := ExhaustiveDecisionForNewPods(clusterState, newPods, true)
+	got, isComplete := branchAndBoundDecisionForNewPods(clusterState, newPods, true)
+	if !isComplete {
+		t.Fatal("the exhaustive decision should be complete")
+	}
+	if math.Abs(want.Score-got.Score) > SCORE_EPSILON || !sameIds(want.ToEdgePods, got.ToEdgePods) {
+		t.Errorf("got score %f, wanted the exhaustive decision with score %f", got.Score, want.Score)
+	}
+}
+
+// Returns deployments of different sizes and edge shares with the
+// given number of new pods each, and a cluster with some of them on edge.
+func newBatch(deployments int, podsPerDeployment int) (*testing_tool.Builder, *model.ClusterState, []string) {
+	builder := testing_tool.New()
+	var deploymentsDesc []*testing_tool.DeploymentDesc
+	var newPodsDesc []string
+	for i := 0; i < deployments; i++ {
+		name := fmt.Sprintf("D%d", i)
+		deploymentsDesc = append(deploymentsDesc, &testing_tool.DeploymentDesc{
+			Name: name, Cpu: 0.25 * float64(1+i%4), Memory: 0.5 * float64(1+i%3), EdgeShare: 0.1 * float64(1+i%10),
+		})
+		for j := 0; j < podsPerDeployment; j++ {
+			newPodsDesc = append(newPodsDesc, name)
+		}
+	}
+	builder.ImportDeployments(deploymentsDesc)
+
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 4, Memory: 8}:  {"D0", "D1"},
+			{Cpu: 2, Memory: 4}:  {},
+			{Cpu: 8, Memory: 16}: {"D2"},
+		},
+		[]string{},
+	)
+
+	return builder, clusterState, newPodsDesc
+}
+
+func TestBranchAndBoundMediumBatch(t *testing.T) {
+	setUp()
+
+	for _, shape := range []struct {
+		name          string
+		deployments   int
+		podsPerDeploy int
+	}{
+		{name: "Few deployments", deployments: 4, podsPerDeploy: 3},
+		{name: "Many deployments", deployments: 12, podsPerDeploy: 1},
+	} {
+		t.Run(shape.name, func(t *testing.T) {
+			builder, clusterState, newPodsDesc := newBatch(shape.deployments, shape.podsPerDeploy)
+
+			for _, canMigrate := range []bool{true, false} {
+				newPods := builder.GetPods(newPodsDesc)
+				want := ExhaustiveDecisionForNewPods(clusterState, newPods, canMigrate)
+				got, isComplete := branchAndBoundDecisionForNewPods(clusterState, newPods, canMigrate)
+
+				if !isComplete {
+					t.Fatalf("the search is cut at %d visits", BRANCH_AND_BOUND_MAXIMUM_VISITS)
+				}
+				if math.Abs(want.Score-got.Score) > SCORE_EPSILON {
+					t.Fatalf("got score %f, wanted %f", got.Score, want.Score)
+				}
+			}
+		})
+	}
+}
+
+func TestBranchAndBoundLargeBatch(t *testing.T) {
+	setUp()
+
+	for _, shape := range []struct {
+		name          string
+		deployments   int
+		podsPerDeploy int
+	}{
+		{name: "Few deployments", deployments: 10, podsPerDeploy: 6},
+		{name: "Many deployments", deployments: 50, podsPerDeploy: 1},
+	} {
+		t.Run(shape.name, func(t *testing.T) {
+			builder, clusterState, newPodsDesc := newBatch(shape.deployments, shape.podsPerDeploy)
+
+			for _, canMigrate := range []bool{true, false} {
+				start := time.Now()
+				decision, isComplete := branchAndBoundDecisionForNewPods(clusterState, builder.GetPods(newPodsDesc), canMigrate)
+				elapsed := time.Since(start)
+
+				// A complete search finds the best split.
+				if !isComplete {
+					t.Fatalf("the search is cut at %d visits", BRANCH_AND_BOUND_MAXIMUM_VISITS)
+				}
+				if len(decision.ToEdgePods)+len(decision.ToCloudPods) != len(newPodsDesc) {
+					t.Fatalf("all new pods should be decided")
+				}
+				if len(decision.ToEdgePods) == 0 {
+					t.Fatalf("some pods should fit in edge")
+				}
+				if elapsed > 10*time.Second {
+					t.Fatalf("deciding for %d pods took %v", len(newPodsDesc), elapsed)
+				}
+			}
+		})
+	}
+}
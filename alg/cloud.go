@@ -24,13 +24,20 @@ func SuggestCloudToEdge(clusterState *model.ClusterState) []*model.Pod {
 		fragmentation := utils.CalcDeFragmentation(pod.Deployment.ResourcesRequired, maximumResources)
 		info := qosResult.DeploymentsQoS[pod.Deployment.Id]
 		score = QoS(
-			float64(info.NumberOfPodOnEdge+1)/float64(info.NumberOfPods), pod.Deployment.EdgeShare,
+			(info.EdgeProximity+bestProximity(clusterState.Edge.Config, pod.Deployment))/float64(info.NumberOfPods), pod.Deployment.EdgeShare,
 		) - QoS(
-			float64(info.NumberOfPodOnEdge)/float64(info.NumberOfPods), pod.Deployment.EdgeShare,
+			info.EffectiveShare(), pod.Deployment.EdgeShare,
 		)
+		score *= PriorityWeight(pod.Deployment)
+		// The pod's cloud cost is saved by moving it to edge.
+		if pod.Node != nil {
+			score += config.SchedulerGeneralConfig.CloudCostWeight * model.PodCost(pod.Deployment, pod.Node)
+		}
 		score /= fragmentation
 
-		if clusterState.NumberOfRunningPods[pod.Deployment.Id] == 1 {
+		// Pods of groups are not moved to edge alone.
+		if clusterState.NumberOfRunningPods[pod.Deployment.Id] == 1 || pod.Group != nil ||
+			!canRunOnEdge(clusterState.Edge.Config, pod.Deployment) {
 			score = math.Inf(-1)
 		}
 
@@ -60,6 +67,7 @@ func SuggestCloudToEdge(clusterState *model.ClusterState) []*model.Pod {
 
 			utils.SSubVec(availableResources, pod.Deployment.ResourcesRequired)
 			qosResult.DeploymentsQoS[pod.Deployment.Id].NumberOfPodOnEdge += 1
+			qosResult.DeploymentsQoS[pod.Deployment.Id].EdgeProximity += bestProximity(clusterState.Edge.Config, pod.Deployment)
 		}
 	}
 
@@ -3,6 +3,7 @@ package alg
 import (
 	"math"
 
+	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/model"
 	"github.com/amsen20/ecmus/internal/utils"
 	"github.com/amsen20/ecmus/logging"
@@ -11,10 +12,33 @@ import (
 
 var log = logging.Get()
 
+// Computes the freed pods and the migrations of decisions, see CalcState.
+var calcState = CalcState
+
 func MakeDecisionForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) model.DecisionForNewPods {
+	var decision model.DecisionForNewPods
+	switch config.SchedulerGeneralConfig.DecisionSolver {
+	case config.BRANCH_AND_BOUND_SOLVER:
+		decision = BranchAndBoundDecisionForNewPods(c, newPods, canMigrate)
+	default:
+		decision = ExhaustiveDecisionForNewPods(c, newPods, canMigrate)
+	}
+
+	if len(decision.EdgeToCloudOffloadingPods) == 0 &&
+		len(decision.ToCloudPods) == 0 &&
+		len(decision.ToEdgePods) == 0 {
+		decision.Migrations = nil
+	}
+
+	return decision
+}
+
+// Tries all 2^len(newPods) edge/cloud splits of the new pods.
+func ExhaustiveDecisionForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) model.DecisionForNewPods {
 	bestDecision := model.DecisionForNewPods{
 		Score: math.Inf(-1),
 	}
+	splits := &splitRecorder{}
 
 	for edgeSubSetNewPodsMask := 0; edgeSubSetNewPodsMask < (1 << len(newPods)); edgeSubSetNewPodsMask++ {
 		edgeNewPods := make([]*model.Pod, 0)
@@ -28,70 +52,168 @@ func MakeDecisionForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigr
 			}
 		}
 
-		leastResourceNeeded := mat.NewVecDense(2, nil)
-		for _, pod := range edgeNewPods {
-			utils.SAddVec(leastResourceNeeded, pod.Deployment.ResourcesRequired)
+		currentDecision, ok := evalDecision(c, edgeNewPods, cloudNewPods, canMigrate, true)
+		if !ok {
+			continue
+		}
+		splits.add(edgeNewPods, currentDecision.Score)
+
+		// maxResources := c.Edge.Config.GetMaximumResources()
+		// nodeResourcesRemained := c.GetNodesResourcesRemained()
+		// var deFragmentation float64
+		// for _, resourcesRemained := range nodeResourcesRemained {
+		// 	deFragmentation += utils.CalcDeFragmentation(resourcesRemained, maxResources)
+		// }
+
+		// currentDecision.Score += FRAGMENTATION_DECISION_COEFFICIENT * deFragmentation
+
+		if currentDecision.Score > bestDecision.Score {
+			bestDecision = currentDecision
+		}
+	}
+	splits.fill(&bestDecision)
+
+	return bestDecision
+}
+
+// Number of the best splits that are kept in decisions for auditing.
+const MAXIMUM_RECORDED_SPLITS = 5
+
+// Records the considered splits of the new pods and keeps the best ones.
+type splitRecorder struct {
+	considered int
+	best       []*model.SplitCandidate
+}
+
+func (r *splitRecorder) add(edgeNewPods []*model.Pod, score float64) {
+	r.considered++
+
+	ind := len(r.best)
+	for ind > 0 && r.best[ind-1].Score < score {
+		ind--
+	}
+	if ind >= MAXIMUM_RECORDED_SPLITS {
+		return
+	}
+
+	r.best = append(r.best, nil)
+	copy(r.best[ind+1:], r.best[ind:])
+	r.best[ind] = &model.SplitCandidate{
+		EdgePods: edgeNewPods,
+		Score:    score,
+	}
+	if len(r.best) > MAXIMUM_RECORDED_SPLITS {
+		r.best = r.best[:MAXIMUM_RECORDED_SPLITS]
+	}
+}
+
+func (r *splitRecorder) fill(decision *model.DecisionForNewPods) {
+	decision.ConsideredSplits = r.considered
+	decision.BestSplits = r.best
+}
+
+// Evaluates a single edge/cloud split of the new pods, returns false
+// if the split is not possible, or if it splits a pod group.
+// The migrations are only computed if withMigrations is set, because
+// they do not affect the decision's score and are expensive.
+func evalDecision(
+	c *model.ClusterState,
+	edgeNewPods []*model.Pod,
+	cloudNewPods []*model.Pod,
+	canMigrate bool,
+	withMigrations bool,
+) (model.DecisionForNewPods, bool) {
+	if !keepsPodGroups(edgeNewPods, cloudNewPods) {
+		return model.DecisionForNewPods{}, false
+	}
+
+	leastResourceNeeded := mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
+	for _, pod := range edgeNewPods {
+		if !canRunOnEdge(c.Edge.Config, pod.Deployment) {
+			return model.DecisionForNewPods{}, false
 		}
+		utils.SAddVec(leastResourceNeeded, pod.Deployment.ResourcesRequired)
+	}
+
+	// Pods of lower priorities never displace the ones of higher priorities.
+	maximumPriority := int32(math.MaxInt32)
+	for _, pod := range edgeNewPods {
+		maximumPriority = min(maximumPriority, pod.Deployment.Priority)
+	}
 
-		var freeEdgeSol model.FreeEdgeSolution
-		if canMigrate {
+	var freeEdgeSol model.FreeEdgeSolution
+	if canMigrate {
+		if withMigrations {
 			var err error
-			freeEdgeSol, err = CalcState(c, leastResourceNeeded)
+			freeEdgeSol, err = calcState(c, leastResourceNeeded, maximumPriority)
 			if err != nil {
-				continue
+				return model.DecisionForNewPods{}, false
 			}
 		} else {
-			edgeResourcesRem := utils.SubVec(c.Edge.Config.Resources, c.Edge.UsedResources)
-			if !utils.LEThan(leastResourceNeeded, edgeResourcesRem) {
-				continue
+			if utils.LThan(movableResources(c, maximumPriority), leastResourceNeeded) {
+				return model.DecisionForNewPods{}, false
 			}
+			freeEdgeSol.FreedPods = EvalFreePods(c, leastResourceNeeded, maximumPriority)
 		}
-
-		currentDecision := model.DecisionForNewPods{
-			EdgeToCloudOffloadingPods: freeEdgeSol.FreedPods,
-			ToEdgePods:                edgeNewPods,
-			ToCloudPods:               cloudNewPods,
-			Migrations:                freeEdgeSol.Migrations,
+	} else {
+		edgeResourcesRem := utils.SubVec(c.Edge.Config.Resources, c.Edge.UsedResources)
+		if !utils.LEThan(leastResourceNeeded, edgeResourcesRem) {
+			return model.DecisionForNewPods{}, false
 		}
+	}
 
-		newCloudPods := make([]*model.Pod, 0)
-		newCloudPods = append(newCloudPods, currentDecision.EdgeToCloudOffloadingPods...)
-		newCloudPods = append(newCloudPods, currentDecision.ToCloudPods...)
+	decision := model.DecisionForNewPods{
+		EdgeToCloudOffloadingPods: freeEdgeSol.FreedPods,
+		ToEdgePods:                edgeNewPods,
+		ToCloudPods:               cloudNewPods,
+		Migrations:                freeEdgeSol.Migrations,
+	}
 
-		qosResult, err := CalcNumberOfQosSatisfactions(
-			c.Edge.Config,
-			c.Cloud.Pods,
-			c.Edge.Pods,
-			newCloudPods,
-			currentDecision.ToEdgePods,
-		)
-		if err != nil {
-			log.Err(err).Send()
+	newCloudPods := make([]*model.Pod, 0)
+	newCloudPods = append(newCloudPods, decision.EdgeToCloudOffloadingPods...)
+	newCloudPods = append(newCloudPods, decision.ToCloudPods...)
+
+	qosResult, err := CalcNumberOfQosSatisfactions(
+		c.Edge.Config,
+		c.Cloud.Pods,
+		c.Edge.Pods,
+		newCloudPods,
+		decision.ToEdgePods,
+	)
+	if err != nil {
+		log.Err(err).Send()
+
+		return model.DecisionForNewPods{}, false
+	}
 
-			continue
-		}
+	decision.Score = qosResult.Score - cloudCost(c, newCloudPods)
 
-		currentDecision.Score = qosResult.Score
+	return decision, true
+}
 
-		// maxResources := c.Edge.Config.GetMaximumResources()
-		// nodeResourcesRemained := c.GetNodesResourcesRemained()
-		// var deFragmentation float64
-		// for _, resourcesRemained := range nodeResourcesRemained {
-		// 	deFragmentation += utils.CalcDeFragmentation(resourcesRemained, maxResources)
-		// }
+// Returns whether the deployment's pods are allowed on any edge node.
+func canRunOnEdge(edgeConfig *model.EdgeConfig, deployment *model.Deployment) bool {
+	for _, node := range edgeConfig.Nodes {
+		if deployment.CanRunOn(node) {
+			return true
+		}
+	}
 
-		// currentDecision.Score += FRAGMENTATION_DECISION_COEFFICIENT * deFragmentation
+	return false
+}
 
-		if currentDecision.Score > bestDecision.Score {
-			bestDecision = currentDecision
-		}
+// Returns the weighted cost of placing the pods on cloud,
+// which is subtracted from decisions' scores.
+func cloudCost(c *model.ClusterState, pods []*model.Pod) float64 {
+	weight := config.SchedulerGeneralConfig.CloudCostWeight
+	if weight == 0 {
+		return 0
 	}
 
-	if len(bestDecision.EdgeToCloudOffloadingPods) == 0 &&
-		len(bestDecision.ToCloudPods) == 0 &&
-		len(bestDecision.ToEdgePods) == 0 {
-		bestDecision.Migrations = nil
+	var cost float64
+	for _, pod := range pods {
+		cost += c.CloudCost(pod.Deployment)
 	}
 
-	return bestDecision
+	return weight * cost
 }
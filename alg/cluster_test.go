@@ -8,7 +8,7 @@ import (
 	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/model"
 	"github.com/amsen20/ecmus/internal/model/testing_tool"
-	"gopkg.in/yaml.v2"
+	"gonum.org/v1/gonum/mat"
 )
 
 func setUp() {
@@ -18,7 +18,7 @@ func setUp() {
 		os.Exit(1)
 	}
 
-	if err := yaml.UnmarshalStrict(yamlFile, &config.SchedulerGeneralConfig); err != nil {
+	if err := config.Load(yamlFile); err != nil {
 		log.Err(err).Msgf("could not load config")
 		os.Exit(1)
 	}
@@ -54,6 +54,66 @@ func TestMakeDecisionForNewPods(t *testing.T) {
 
 }
 
+func TestReservedResources(t *testing.T) {
+	setUp()
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {},
+		},
+		[]string{},
+	)
+	// Pods that the scheduler does not manage take half of the node.
+	clusterState.SetNodeReserved(clusterState.Edge.Config.Nodes[0].Id, mat.NewVecDense(2, []float64{1, 1}))
+
+	decision := MakeDecisionForNewPods(clusterState, builder.GetPods([]string{"A", "A"}), false)
+	if len(decision.ToEdgePods) != 1 {
+		t.Fatalf("got %d pods placed on edge, wanted only 1 fitting besides the reserved resources", len(decision.ToEdgePods))
+	}
+	TestingApplyDecision(clusterState, decision)
+
+	builder.Expect(
+		clusterState, map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {"A"},
+		},
+		[]string{"A"},
+	)
+}
+
+func TestReservedResourcesAreNotMovable(t *testing.T) {
+	setUp()
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 2, Memory: 2, EdgeShare: 1},
+	})
+
+	solvers := map[string]func(*model.ClusterState, []*model.Pod, bool) model.DecisionForNewPods{
+		config.EXHAUSTIVE_SOLVER:       ExhaustiveDecisionForNewPods,
+		config.BRANCH_AND_BOUND_SOLVER: BranchAndBoundDecisionForNewPods,
+	}
+	for name, solve := range solvers {
+		clusterState := builder.GetCluster(
+			map[*testing_tool.NodeDesc][]string{
+				{Cpu: 2, Memory: 2}: {"A"},
+			},
+			[]string{},
+		)
+		// Offloading A does not make room for B, since
+		// the reserved resources can not be freed.
+		clusterState.SetNodeReserved(clusterState.Edge.Config.Nodes[0].Id, mat.NewVecDense(2, []float64{1, 1}))
+
+		decision := solve(clusterState, builder.GetPods([]string{"B"}), true)
+		if len(decision.ToEdgePods) != 0 || len(decision.ToCloudPods) != 1 {
+			t.Fatalf("%s: got %d pods placed on edge, wanted B on cloud", name, len(decision.ToEdgePods))
+		}
+	}
+}
+
 func TestComprehensiveScenario(t *testing.T) {
 	setUp()
 	builder := testing_tool.New()
@@ -146,3 +206,38 @@ func TestComprehensiveScenario(t *testing.T) {
 		)
 	})
 }
+
+func TestExtendedResources(t *testing.T) {
+	setUp()
+	config.SchedulerGeneralConfig.Resources = append(
+		config.SchedulerGeneralConfig.Resources,
+		config.ResourceConfig{Name: "example.com/fpga", Scale: 1, IgnoreInFragmentation: true},
+	)
+	config.SchedulerGeneralConfig.ResourceCount = len(config.SchedulerGeneralConfig.Resources)
+	defer setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "F", Cpu: 1, Memory: 1, Others: map[string]float64{"example.com/fpga": 1}, EdgeShare: 1},
+	})
+
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {},
+			{Cpu: 1, Memory: 1, Others: map[string]float64{"example.com/fpga": 1}}: {},
+		},
+		[]string{},
+	)
+
+	decision := MakeDecisionForNewPods(clusterState, builder.GetPods([]string{"F", "F", "A"}), false)
+	TestingApplyDecision(clusterState, decision)
+
+	builder.Expect(
+		clusterState, map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {"A"},
+			{Cpu: 1, Memory: 1}: {"F"},
+		},
+		[]string{"F"},
+	)
+}
@@ -0,0 +1,75 @@
+package alg
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestPlacementConstraints(t *testing.T) {
+	setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	builder.Deployments["A"].Constraints.NodeSelector = map[string]string{"site": "north"}
+
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 2},
+		{Cpu: 2, Memory: 2},
+	})
+	south, north := clusterState.Edge.Config.Nodes[0], clusterState.Edge.Config.Nodes[1]
+	south.Labels = map[string]string{"site": "south"}
+	north.Labels = map[string]string{"site": "north"}
+
+	// A's pods fit on the south node first, but they are only allowed on the north one.
+	pods := builder.GetPods([]string{"A", "A", "B"})
+	mapping := MapPodToEdge(clusterState, pods, nil, nil).Mapping
+	for _, pod := range pods {
+		want := south
+		if pod.Deployment == builder.Deployments["A"] {
+			want = north
+		}
+		if mapping[pod.Id] != want {
+			t.Errorf("pod %d of deployment %d is not mapped to the node it is allowed on", pod.Id, pod.Deployment.Id)
+		}
+	}
+	for _, pod := range builder.GetPods([]string{"A"}) {
+		if node := firstFit(clusterState, []*model.Pod{pod})[pod.Id]; node != north {
+			t.Errorf("first fit mapped pod %d to a node it is not allowed on", pod.Id)
+		}
+	}
+
+	// Three A pods do not fit on the north node.
+	pods = builder.GetPods([]string{"A", "A", "A"})
+	if mapping := MapPodToEdge(clusterState, pods, nil, nil).Mapping; len(mapping) != 2 {
+		t.Errorf("got %d mapped pods, wanted 2", len(mapping))
+	}
+
+	// Cloud pods go to the cloud nodes they are allowed on.
+	northCloud := &model.Node{
+		Id:        100,
+		Resources: clusterState.Cloud.Nodes[0].Resources,
+		Labels:    map[string]string{"site": "north"},
+	}
+	clusterState.AddNode(northCloud, "cloud")
+	if node, fits := clusterState.PickCloudNode(builder.Deployments["A"]); node != northCloud || !fits {
+		t.Errorf("the cloud node is not the one the deployment is allowed on")
+	}
+
+	// Tainted nodes only take the pods tolerating their taints.
+	south.Taints = []model.Taint{{Key: "dedicated", Value: "B", Effect: model.NO_SCHEDULE_EFFECT}}
+	north.Taints = south.Taints
+	if decision := MakeDecisionForNewPods(clusterState, builder.GetPods([]string{"B"}), true); len(decision.ToEdgePods) != 0 {
+		t.Errorf("the pod is decided for edge without any node allowing it")
+	}
+	builder.Deployments["B"].Constraints.Tolerations = []model.Toleration{
+		{Key: "dedicated", Operator: model.EQUAL_OPERATOR, Value: "B", Effect: model.NO_SCHEDULE_EFFECT},
+	}
+	if decision := MakeDecisionForNewPods(clusterState, builder.GetPods([]string{"B"}), true); len(decision.ToEdgePods) != 1 {
+		t.Errorf("the pod tolerating the taints is not decided for edge")
+	}
+}
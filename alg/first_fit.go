@@ -0,0 +1,67 @@
+package alg
+
+import (
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+)
+
+// A baseline similar to kubernetes' default scheduler on edge,
+// each new pod goes to the first edge node it fits in,
+// and if there is none, to cloud.
+// It never migrates or offloads pods and never reorders.
+type firstFitAlgorithm struct{}
+
+func (*firstFitAlgorithm) DecideForNewPods(c *model.ClusterState, newPods []*model.Pod, canMigrate bool) model.DecisionForNewPods {
+	mapping := firstFit(c, newPods)
+	KeepPodGroupsTogether(newPods, mapping)
+
+	decision := model.DecisionForNewPods{
+		ToEdgePods:  make([]*model.Pod, 0),
+		ToCloudPods: make([]*model.Pod, 0),
+	}
+	for _, pod := range newPods {
+		if _, ok := mapping[pod.Id]; ok {
+			decision.ToEdgePods = append(decision.ToEdgePods, pod)
+		} else {
+			decision.ToCloudPods = append(decision.ToCloudPods, pod)
+		}
+	}
+
+	return decision
+}
+
+func (*firstFitAlgorithm) MapPodToEdge(c *model.ClusterState, pods []*model.Pod, freedPods []*model.Pod, migrations []*model.Migration) model.EdgePodMapping {
+	mapping := firstFit(c, pods)
+	KeepPodGroupsTogether(pods, mapping)
+
+	return model.EdgePodMapping{
+		Mapping:    mapping,
+		Violations: zoneSkewViolations(c.Edge.Config, withMapping(podsOnNodes(c), pods, mapping)),
+	}
+}
+
+func (*firstFitAlgorithm) SuggestReorder(c *model.ClusterState) model.ReorderSuggestion {
+	return model.ReorderSuggestion{}
+}
+
+// Maps the pods in order to the first edge node in which they fit and
+// are allowed by the spread rules, pods that fit nowhere are not mapped.
+func firstFit(c *model.ClusterState, pods []*model.Pod) map[int]*model.Node {
+	nodeResourcesRemained := c.GetNodesResourcesRemained()
+	nodePods := podsOnNodes(c)
+	mapping := make(map[int]*model.Node)
+
+	for _, pod := range pods {
+		for _, node := range c.Edge.Config.Nodes {
+			if pod.Deployment.CanRunOn(node) && canJoin(pod, nodePods[node.Id]) &&
+				utils.LEThan(pod.Deployment.ResourcesRequired, nodeResourcesRemained[node.Id]) {
+				utils.SSubVec(nodeResourcesRemained[node.Id], pod.Deployment.ResourcesRequired)
+				nodePods[node.Id] = append(nodePods[node.Id], pod)
+				mapping[pod.Id] = node
+				break
+			}
+		}
+	}
+
+	return mapping
+}
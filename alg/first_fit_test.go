@@ -0,0 +1,45 @@
+package alg
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestFirstFit(t *testing.T) {
+	setUp()
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1.5, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 2, EdgeShare: 1},
+	})
+
+	algorithm, err := Get(FIRST_FIT_ALGORITHM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 4}: {},
+		},
+		[]string{"A"},
+	)
+
+	decision := algorithm.DecideForNewPods(clusterState, builder.GetPods([]string{"A", "B", "A"}), true)
+	TestingApplyAlgorithmDecision(clusterState, algorithm, decision)
+	TestingApplyAlgorithmSuggestion(clusterState, algorithm, algorithm.SuggestReorder(clusterState))
+
+	builder.Expect(
+		clusterState, map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 4}: {"A", "B"},
+		},
+		[]string{"A", "A"},
+	)
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	if _, err := Get("unknown"); err == nil {
+		t.Fatalf("getting an unregistered algorithm should fail")
+	}
+}
@@ -0,0 +1,59 @@
+package alg
+
+import (
+	"sort"
+
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Returns whether the split keeps the pod groups of
+// the new pods together, all on edge or all on cloud.
+func keepsPodGroups(edgeNewPods []*model.Pod, cloudNewPods []*model.Pod) bool {
+	onEdge := make(map[string]bool)
+	for _, pod := range edgeNewPods {
+		if pod.Group != nil {
+			onEdge[pod.Group.Name] = true
+		}
+	}
+
+	for _, pod := range cloudNewPods {
+		if pod.Group != nil && onEdge[pod.Group.Name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Removes the pods of the groups that are not all mapped from the
+// mapping, so each group goes to edge or to cloud as a whole.
+// Returns the names of the groups that are removed.
+func KeepPodGroupsTogether(pods []*model.Pod, mapping map[int]*model.Node) []string {
+	var removed []string
+	for name, members := range model.PodsByGroup(pods) {
+		allMapped := true
+		for _, pod := range members {
+			if _, ok := mapping[pod.Id]; !ok {
+				allMapped = false
+				break
+			}
+		}
+		if allMapped {
+			continue
+		}
+
+		anyMapped := false
+		for _, pod := range members {
+			if _, ok := mapping[pod.Id]; ok {
+				delete(mapping, pod.Id)
+				anyMapped = true
+			}
+		}
+		if anyMapped {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+
+	return removed
+}
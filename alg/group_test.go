@@ -0,0 +1,71 @@
+package alg
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestPodGroups(t *testing.T) {
+	setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "frontend", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "cache", Cpu: 2, Memory: 2, EdgeShare: 1},
+	})
+
+	newPods := func() (*model.Pod, *model.Pod, *model.Pod) {
+		pods := builder.GetPods([]string{"frontend", "cache", "frontend"})
+		group := &model.PodGroup{Name: "shop", Size: 2}
+		pods[0].Group, pods[1].Group = group, group
+
+		return pods[0], pods[1], pods[2]
+	}
+
+	// The group does not fit on edge, only the other frontend pod goes to edge.
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 2},
+	})
+	frontend, cache, other := newPods()
+	pods := []*model.Pod{frontend, cache, other}
+	decision := ExhaustiveDecisionForNewPods(clusterState, pods, false)
+	if !sameIds(decision.ToEdgePods, []*model.Pod{other}) {
+		t.Errorf("got %d pods to edge, wanted only the pod without a group", len(decision.ToEdgePods))
+	}
+	expectSameDecision(t, clusterState, pods, false)
+
+	// The group fits on edge.
+	clusterState = builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 3, Memory: 3},
+	})
+	decision = ExhaustiveDecisionForNewPods(clusterState, pods, false)
+	if !sameIds(decision.ToEdgePods, []*model.Pod{frontend, cache}) {
+		t.Errorf("the group is not placed on edge as a whole")
+	}
+	expectSameDecision(t, clusterState, pods, false)
+
+	// A group that is not all mapped is removed from the mapping.
+	mapping := map[int]*model.Node{
+		frontend.Id: clusterState.Edge.Config.Nodes[0],
+		other.Id:    clusterState.Edge.Config.Nodes[0],
+	}
+	if removed := KeepPodGroupsTogether(pods, mapping); len(removed) != 1 || removed[0] != "shop" {
+		t.Errorf("got removed groups %v, wanted the shop group", removed)
+	}
+	if _, ok := mapping[frontend.Id]; ok || len(mapping) != 1 {
+		t.Errorf("the group's pod is still mapped")
+	}
+
+	// Pods of groups are never offloaded alone.
+	clusterState = builder.GetCluster(map[*testing_tool.NodeDesc][]string{
+		{Cpu: 2, Memory: 2}: {"frontend", "frontend"},
+	}, []string{"frontend"})
+	for _, pod := range clusterState.Edge.Pods {
+		pod.Group = &model.PodGroup{Name: "web"}
+	}
+	if freedPods := EvalFreePods(clusterState, cache.Deployment.ResourcesRequired, 0); len(freedPods) != 0 {
+		t.Errorf("freed %d pods of a group", len(freedPods))
+	}
+}
@@ -18,13 +18,15 @@ import (
 // 	return nil, nil
 // }
 
-func CalcState(c *model.ClusterState, neededResources *mat.VecDense) (model.FreeEdgeSolution, error) {
-	if utils.LThan(c.Edge.Config.Resources, neededResources) {
+// Frees and migrates edge pods with priorities up to the
+// maximum priority so the needed resources get free.
+func CalcState(c *model.ClusterState, neededResources *mat.VecDense, maximumPriority int32) (model.FreeEdgeSolution, error) {
+	if utils.LThan(movableResources(c, maximumPriority), neededResources) {
 		return model.FreeEdgeSolution{}, fmt.Errorf("resource request limit exceeded for %s", utils.ToString(neededResources))
 	}
 
-	freedPods := EvalFreePods(c, neededResources)
-	migrations := CalcMigrations(c, freedPods)
+	freedPods := EvalFreePods(c, neededResources, maximumPriority)
+	migrations := CalcMigrations(c, freedPods, maximumPriority)
 
 	return model.FreeEdgeSolution{
 		FreedPods:  freedPods,
@@ -32,8 +34,8 @@ func CalcState(c *model.ClusterState, neededResources *mat.VecDense) (model.Free
 	}, nil
 }
 
-func GetMaximumScore(c *model.ClusterState, neededResources *mat.VecDense) (model.FreeEdgeSolution, error) {
-	if utils.LThan(c.Edge.Config.Resources, neededResources) {
+func GetMaximumScore(c *model.ClusterState, neededResources *mat.VecDense, maximumPriority int32) (model.FreeEdgeSolution, error) {
+	if utils.LThan(movableResources(c, maximumPriority), neededResources) {
 		return model.FreeEdgeSolution{}, fmt.Errorf("resource request limit exceeded for %s", utils.ToString(neededResources))
 	}
 
@@ -44,7 +46,7 @@ func GetMaximumScore(c *model.ClusterState, neededResources *mat.VecDense) (mode
 	// }
 
 	// if len(candidates) == 0 {
-	feSol, err := CalcState(c, neededResources)
+	feSol, err := CalcState(c, neededResources, maximumPriority)
 	if err != nil {
 		return model.FreeEdgeSolution{}, err
 	}
@@ -67,6 +69,25 @@ func GetMaximumScore(c *model.ClusterState, neededResources *mat.VecDense) (mode
 	// return chosenCandidate.Solution, nil
 }
 
+// Returns the edge resources that are free or used by
+// the pods that can be freed, see isFreeable.
+func movableResources(c *model.ClusterState, maximumPriority int32) *mat.VecDense {
+	resources := utils.SubVec(c.Edge.Config.Resources, c.EdgeReservedResources())
+	for _, pod := range c.Edge.Pods {
+		if !isFreeable(pod, maximumPriority) {
+			utils.SSubVec(resources, pod.Deployment.ResourcesRequired)
+		}
+	}
+
+	return resources
+}
+
+// Returns whether the edge pod can be offloaded for pods with priorities
+// up to the maximum priority, pods of groups are never offloaded alone.
+func isFreeable(pod *model.Pod, maximumPriority int32) bool {
+	return pod.Deployment.Priority <= maximumPriority && pod.Group == nil
+}
+
 func isAllowedToMove(c *model.ClusterState, freedPods []*model.Pod, pods []*model.Pod) bool {
 	movingPods := make(map[int]int)
 	for _, pod := range freedPods {
@@ -106,7 +127,7 @@ func ChooseFromPods(pods []*model.Pod, cnt int, start int, cur []*model.Pod, cho
 }
 
 // brute force
-func GetPossiblePodChoices(c *model.ClusterState, freedPods []*model.Pod) [][]*model.Pod {
+func GetPossiblePodChoices(c *model.ClusterState, freedPods []*model.Pod, maximumPriority int32) [][]*model.Pod {
 	var podChoices [][]*model.Pod
 	freedPodIds := utils.SliceToMap(freedPods, func(pod *model.Pod) int { return pod.Id })
 
@@ -115,6 +136,9 @@ func GetPossiblePodChoices(c *model.ClusterState, freedPods []*model.Pod) [][]*m
 		if ok, isIn := freedPodIds[pod.Id]; ok && isIn {
 			continue
 		}
+		if pod.Deployment.Priority > maximumPriority {
+			continue
+		}
 		remainingPods = append(remainingPods, pod)
 	}
 
@@ -132,10 +156,14 @@ func GetPossiblePodChoices(c *model.ClusterState, freedPods []*model.Pod) [][]*m
 	return finalPodChoices
 }
 
+// Maps a prefix of the pods, as long as possible, to the edge nodes
+// in order, so the pods of each node are consecutive. The pods already
+// on the nodes are used to keep the spread rules of the deployments.
 func FitInEdge(
 	pods []*model.Pod,
 	edgeConfig *model.EdgeConfig,
 	nodeResourcesRemained map[int]*mat.VecDense,
+	nodePods map[int][]*model.Pod,
 ) (float64, map[int]*model.Node) {
 	n := len(edgeConfig.Nodes)
 	m := len(pods)
@@ -156,6 +184,7 @@ func FitInEdge(
 		node := edgeConfig.Nodes[i-1]
 		for j := 0; j < m+1; j++ {
 			resources := mat.NewVecDense(node.Resources.Len(), nil)
+			onNode := append([]*model.Pod(nil), nodePods[node.Id]...)
 			for k := j; k >= 0; k-- {
 				if utils.LEThan(resources, nodeResourcesRemained[node.Id]) {
 					currentDeFragmentation := utils.CalcDeFragmentation(
@@ -173,7 +202,12 @@ func FitInEdge(
 				}
 
 				if k > 0 {
+					if !pods[k-1].Deployment.CanRunOn(node) || !canJoin(pods[k-1], onNode) {
+						// the pods from k-1 to j-1 can not all go to the node
+						break
+					}
 					utils.SAddVec(resources, pods[k-1].Deployment.ResourcesRequired)
+					onNode = append(onNode, pods[k-1])
 				}
 			}
 		}
@@ -207,10 +241,12 @@ func FitInEdge(
 	return dp[n][m], ret
 }
 
-func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod) []*model.Migration {
+func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod, maximumPriority int32) []*model.Migration {
 	type migrations struct {
 		deFragmentation float64
-		migrations      []*model.Migration
+		// of the zone skews, the other spread rules are never broken
+		violations int
+		migrations []*model.Migration
 	}
 
 	nodeResourcesRemained := c.GetNodesResourcesRemained()
@@ -231,6 +267,7 @@ func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod) []*model.Migr
 
 	bestMigrations := migrations{
 		deFragmentation: currentDeFragmentation,
+		violations:      zoneSkewViolations(c.Edge.Config, podsOnNodes(c, freedPods)),
 		migrations:      nil,
 	}
 
@@ -239,7 +276,9 @@ func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod) []*model.Migr
 			utils.SAddVec(nodeResourcesRemained[pod.Node.Id], pod.Deployment.ResourcesRequired)
 		}
 
-		deFragmentation, mapping := FitInEdge(migratedPods, c.Edge.Config, nodeResourcesRemained)
+		stayingPods := podsOnNodes(c, freedPods, migratedPods)
+		deFragmentation, mapping := FitInEdge(migratedPods, c.Edge.Config, nodeResourcesRemained, stayingPods)
+		spreadOverZones(c.Edge.Config, nodeResourcesRemained, stayingPods, migratedPods, mapping)
 
 		for _, pod := range migratedPods {
 			utils.SSubVec(nodeResourcesRemained[pod.Node.Id], pod.Deployment.ResourcesRequired)
@@ -247,12 +286,14 @@ func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod) []*model.Migr
 
 		ret := migrations{
 			deFragmentation: deFragmentation,
+			violations:      math.MaxInt,
 			migrations:      nil,
 		}
 
 		if deFragmentation < 0 || len(mapping) != len(migratedPods) {
 			return ret
 		}
+		ret.violations = zoneSkewViolations(c.Edge.Config, withMapping(stayingPods, migratedPods, mapping))
 		for _, pod := range migratedPods {
 			node, ok := mapping[pod.Id]
 			if !ok {
@@ -268,11 +309,16 @@ func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod) []*model.Migr
 		return ret
 	}
 
-	possiblePodChoices := GetPossiblePodChoices(c, freedPods)
+	possiblePodChoices := GetPossiblePodChoices(c, freedPods, maximumPriority)
 	for _, possiblePodChoice := range possiblePodChoices {
 		for migratedPods := range utils.Permutations(possiblePodChoice) {
 			currentMigrations := calcMigrations(migratedPods)
-			if bestMigrations.deFragmentation < currentMigrations.deFragmentation {
+			// Less broken spread rules are better, then less fragmentation.
+			isBetter := currentMigrations.violations < bestMigrations.violations
+			if currentMigrations.violations == bestMigrations.violations {
+				isBetter = bestMigrations.deFragmentation < currentMigrations.deFragmentation
+			}
+			if isBetter {
 				bestMigrations = currentMigrations
 			}
 		}
@@ -281,7 +327,9 @@ func CalcMigrations(c *model.ClusterState, freedPods []*model.Pod) []*model.Migr
 	return bestMigrations.migrations
 }
 
-func EvalFreePods(c *model.ClusterState, leastResource *mat.VecDense) []*model.Pod {
+// Chooses edge pods that can be freed (offloaded to cloud) for
+// the maximum priority, so the least resource gets free.
+func EvalFreePods(c *model.ClusterState, leastResource *mat.VecDense, maximumPriority int32) []*model.Pod {
 	PodsOfNode := make(map[int][]*model.Pod)
 	for _, node := range c.Edge.Config.Nodes {
 		PodsOfNode[node.Id] = make([]*model.Pod, 0)
@@ -305,10 +353,11 @@ func EvalFreePods(c *model.ClusterState, leastResource *mat.VecDense) []*model.P
 		fragmentation := utils.CalcDeFragmentation(pod.Deployment.ResourcesRequired, maximumResources)
 		info := qosResult.DeploymentsQoS[pod.Deployment.Id]
 		score = QoS(
-			float64(info.NumberOfPodOnEdge-1)/float64(info.NumberOfPods), pod.Deployment.EdgeShare,
+			(info.EdgeProximity-Proximity(pod.Deployment, pod.Node))/float64(info.NumberOfPods), pod.Deployment.EdgeShare,
 		) - QoS(
-			float64(info.NumberOfPodOnEdge)/float64(info.NumberOfPods), pod.Deployment.EdgeShare,
+			info.EffectiveShare(), pod.Deployment.EdgeShare,
 		)
+		score *= PriorityWeight(pod.Deployment)
 		score /= fragmentation
 
 		return score
@@ -316,8 +365,12 @@ func EvalFreePods(c *model.ClusterState, leastResource *mat.VecDense) []*model.P
 
 	needToFreeResources := utils.SubVec(leastResource, utils.SubVec(c.Edge.Config.Resources, c.Edge.UsedResources))
 
-	edgePods := make([]*model.Pod, len(c.Edge.Pods))
-	copy(edgePods, c.Edge.Pods)
+	edgePods := make([]*model.Pod, 0, len(c.Edge.Pods))
+	for _, pod := range c.Edge.Pods {
+		if isFreeable(pod, maximumPriority) {
+			edgePods = append(edgePods, pod)
+		}
+	}
 
 	currentFreedResources := mat.NewVecDense(needToFreeResources.Len(), nil)
 	freedPods := make([]*model.Pod, 0)
@@ -336,6 +389,7 @@ func EvalFreePods(c *model.ClusterState, leastResource *mat.VecDense) []*model.P
 
 		utils.SAddVec(currentFreedResources, edgePods[i].Deployment.ResourcesRequired)
 		qosResult.DeploymentsQoS[edgePods[i].Deployment.Id].NumberOfPodOnEdge -= 1
+		qosResult.DeploymentsQoS[edgePods[i].Deployment.Id].EdgeProximity -= Proximity(edgePods[i].Deployment, edgePods[i].Node)
 		freedPods = append(freedPods, edgePods[i])
 
 		if edgePods[i].Status == model.RUNNING {
@@ -379,23 +433,47 @@ func MapPodToEdge(
 		addRes(pod.Deployment.ResourcesRequired, pod.Node)
 	}
 
+	migratedPods := make([]*model.Pod, 0, len(migrations))
+	migrationMapping := make(map[int]*model.Node)
 	for _, migration := range migrations {
 		addRes(migration.Pod.Deployment.ResourcesRequired, migration.Pod.Node)
 		subRes(migration.Pod.Deployment.ResourcesRequired, migration.Node)
+
+		migratedPods = append(migratedPods, migration.Pod)
+		migrationMapping[migration.Pod.Id] = migration.Node
 	}
+	nodePods := withMapping(podsOnNodes(clusterState, freedPods, migratedPods), migratedPods, migrationMapping)
 
 	ret := model.EdgePodMapping{
 		Mapping:         make(map[int]*model.Node),
 		DeFragmentation: math.Inf(-1),
+		Violations:      zoneSkewViolations(clusterState.Edge.Config, nodePods),
 	}
+	retProximity := math.Inf(-1)
 
+	// More mapped pods are better, then less broken spread rules,
+	// then the pods being closer to their users, then less fragmentation.
 	for orderedPods := range utils.Permutations(pods) {
-		deFragmentation, mapping := FitInEdge(orderedPods, clusterState.Edge.Config, nodeResourcesRemained)
-		if len(ret.Mapping) < len(mapping) || (len(ret.Mapping) == len(mapping) && ret.DeFragmentation < deFragmentation) {
+		deFragmentation, mapping := FitInEdge(orderedPods, clusterState.Edge.Config, nodeResourcesRemained, nodePods)
+		spreadOverZones(clusterState.Edge.Config, nodeResourcesRemained, nodePods, orderedPods, mapping)
+		violations := zoneSkewViolations(clusterState.Edge.Config, withMapping(nodePods, orderedPods, mapping))
+		proximity := mappingProximity(orderedPods, mapping)
+
+		isBetter := len(ret.Mapping) < len(mapping)
+		if len(ret.Mapping) == len(mapping) {
+			isBetter = violations < ret.Violations
+		}
+		if len(ret.Mapping) == len(mapping) && violations == ret.Violations {
+			isBetter = proximity > retProximity+SCORE_EPSILON ||
+				(math.Abs(proximity-retProximity) <= SCORE_EPSILON && ret.DeFragmentation < deFragmentation)
+		}
+		if isBetter {
 			ret = model.EdgePodMapping{
 				Mapping:         mapping,
 				DeFragmentation: deFragmentation,
+				Violations:      violations,
 			}
+			retProximity = proximity
 		}
 	}
 
@@ -0,0 +1,70 @@
+package alg
+
+import (
+	"math"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Returns how close the edge node is to the deployment's users,
+// from 0 (as far as cloud) to 1 (no latency), weighted by the
+// users of each zone. Users of zones whose latency to the node
+// is not measured are as far as cloud, except the node's own zone.
+// In the share QoS model every edge node is 1.
+func Proximity(deployment *model.Deployment, node *model.Node) float64 {
+	if config.SchedulerGeneralConfig.QoSModel != config.LATENCY_QOS_MODEL || len(deployment.UserZones) == 0 {
+		return 1
+	}
+
+	var proximity, users float64
+	for zone, share := range deployment.UserZones {
+		users += share
+
+		latency, ok := node.Latencies[zone]
+		if !ok {
+			if zone != node.Zone {
+				continue
+			}
+			latency = 0
+		}
+		proximity += share * math.Max(0, 1-latency/config.SchedulerGeneralConfig.CloudLatency)
+	}
+
+	if users == 0 {
+		return 1
+	}
+
+	return proximity / users
+}
+
+// Returns the proximity of the edge node closest to the deployment's
+// users among the nodes the deployment is allowed on, which is assumed
+// for the pods going to edge before they are mapped to nodes.
+// It is 1 if there is no edge node.
+func bestProximity(edgeConfig *model.EdgeConfig, deployment *model.Deployment) float64 {
+	if len(edgeConfig.Nodes) == 0 {
+		return 1
+	}
+
+	var best float64
+	for _, node := range edgeConfig.Nodes {
+		if deployment.CanRunOn(node) {
+			best = math.Max(best, Proximity(deployment, node))
+		}
+	}
+
+	return best
+}
+
+// Returns the sum of the proximities of the pods to their mapped nodes.
+func mappingProximity(pods []*model.Pod, mapping map[int]*model.Node) float64 {
+	var proximity float64
+	for _, pod := range pods {
+		if node, ok := mapping[pod.Id]; ok {
+			proximity += Proximity(pod.Deployment, node)
+		}
+	}
+
+	return proximity
+}
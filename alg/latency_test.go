@@ -0,0 +1,62 @@
+package alg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestLatencyQoS(t *testing.T) {
+	setUp()
+	config.SchedulerGeneralConfig.QoSModel = config.LATENCY_QOS_MODEL
+	config.SchedulerGeneralConfig.CloudLatency = 100
+	defer setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	builder.Deployments["A"].UserZones = map[string]float64{"zone-a": 1}
+
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 1, Memory: 1},
+		{Cpu: 1, Memory: 1},
+	})
+	far, near := clusterState.Edge.Config.Nodes[0], clusterState.Edge.Config.Nodes[1]
+	far.Zone, far.Latencies = "zone-b", map[string]float64{"zone-a": 75}
+	near.Zone = "zone-a"
+
+	if proximity := Proximity(builder.Deployments["A"], far); math.Abs(proximity-0.25) > SCORE_EPSILON {
+		t.Errorf("got proximity %f of the far node, wanted 0.25", proximity)
+	}
+	if proximity := Proximity(builder.Deployments["A"], near); proximity != 1 {
+		t.Errorf("got proximity %f of the near node, wanted 1", proximity)
+	}
+	if proximity := Proximity(builder.Deployments["B"], far); proximity != 1 {
+		t.Errorf("got proximity %f for a deployment without users, wanted 1", proximity)
+	}
+
+	// B's pod does not care where it goes, A's pod should be near its users.
+	pods := builder.GetPods([]string{"B", "A"})
+	mapping := MapPodToEdge(clusterState, pods, nil, nil).Mapping
+	if mapping[pods[1].Id] != near || mapping[pods[0].Id] != far {
+		t.Errorf("the pod with users in zone-a is not mapped to the node in zone-a")
+	}
+
+	// A's pod on the far node counts as a quarter of an edge pod.
+	if err := clusterState.DeployEdge(pods[1], far); err != nil {
+		t.Fatal(err)
+	}
+	qosResult, err := CalcNumberOfQosSatisfactions(clusterState.Edge.Config, clusterState.Cloud.Pods, clusterState.Edge.Pods, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if share := qosResult.DeploymentsQoS[builder.Deployments["A"].Id].EffectiveShare(); math.Abs(share-0.25) > SCORE_EPSILON {
+		t.Errorf("got effective share %f, wanted 0.25", share)
+	}
+
+	expectSameDecision(t, clusterState, builder.GetPods([]string{"A", "A", "B"}), true)
+}
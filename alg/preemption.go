@@ -0,0 +1,178 @@
+package alg
+
+import (
+	"sort"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Finds preemptions for the pods that are going to cloud, like
+// kube-scheduler: pods with higher priorities go first, and a pod
+// whose deployment has less than its promised edge share evicts
+// edge pods with lower priorities to cloud, if that makes it fit
+// in an edge node.
+// Pods of groups never preempt and are never preempted, as that
+// would split their groups between edge and cloud.
+// The pods should not be in the cluster state, and the cluster
+// state is not changed.
+func Preempt(c *model.ClusterState, pods []*model.Pod) []*model.Preemption {
+	imgState := c.Clone()
+
+	pending := make([]*model.Pod, len(pods))
+	copy(pending, pods)
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Deployment.Priority > pending[j].Deployment.Priority
+	})
+
+	ret := make([]*model.Preemption, 0)
+	for ind, pod := range pending {
+		if pod.Group != nil || !isBelowEdgeShare(imgState, pod.Deployment, pending[ind:]) {
+			continue
+		}
+
+		node, victims, ok := findVictims(imgState, pod)
+		if !ok {
+			continue
+		}
+
+		for _, victim := range victims {
+			imgState.RemovePod(victim)
+			imgState.DeployCloud(victim)
+		}
+		imgState.DeployEdge(&model.Pod{
+			Id:         pod.Id,
+			Deployment: pod.Deployment,
+			Status:     pod.Status,
+		}, node)
+
+		preemption := &model.Preemption{
+			Pod:  pod,
+			Node: node,
+		}
+		for _, victim := range victims {
+			// The victims are reported as the pods of the given cluster state.
+			if original, ok := c.PodsMap[victim.Id]; ok {
+				preemption.Victims = append(preemption.Victims, original)
+			}
+		}
+		ret = append(ret, preemption)
+	}
+
+	return ret
+}
+
+// Returns whether the deployment's share of pods on edge is less
+// than its promised share, the pending pods are counted as on cloud.
+func isBelowEdgeShare(c *model.ClusterState, deployment *model.Deployment, pending []*model.Pod) bool {
+	var onEdge, total int
+	for _, pod := range c.Edge.Pods {
+		if pod.Deployment.Id == deployment.Id {
+			onEdge++
+			total++
+		}
+	}
+	for _, pods := range [][]*model.Pod{c.Cloud.Pods, pending} {
+		for _, pod := range pods {
+			if pod.Deployment.Id == deployment.Id {
+				total++
+			}
+		}
+	}
+
+	return float64(onEdge) < deployment.EdgeShare*float64(total)-SCORE_EPSILON
+}
+
+// Finds the edge node in which the pod fits by evicting the fewest
+// pods with lower priorities. Between nodes, the one whose highest
+// priority victim has the lowest priority is chosen, then the one
+// with fewer victims.
+func findVictims(c *model.ClusterState, pod *model.Pod) (*model.Node, []*model.Pod, bool) {
+	var bestNode *model.Node
+	var bestVictims []*model.Pod
+	var bestPriority int32
+
+	nodesResourcesRemained := c.GetNodesResourcesRemained()
+	for _, node := range c.Edge.Config.Nodes {
+		if !pod.Deployment.CanRunOn(node) || !utils.LEThan(pod.Deployment.ResourcesRequired, node.Resources) {
+			continue
+		}
+
+		victims, ok := nodeVictims(c, pod, node, nodesResourcesRemained[node.Id])
+		if !ok || len(victims) == 0 {
+			continue
+		}
+		if !canJoin(pod, podsOnNodes(c, victims)[node.Id]) {
+			continue
+		}
+
+		// victims are sorted by their priorities
+		priority := victims[len(victims)-1].Deployment.Priority
+
+		if bestNode == nil ||
+			priority < bestPriority ||
+			(priority == bestPriority && len(victims) < len(bestVictims)) {
+			bestNode = node
+			bestVictims = victims
+			bestPriority = priority
+		}
+	}
+
+	return bestNode, bestVictims, bestNode != nil
+}
+
+// Returns the pods with lower priorities than the pod on the node
+// that should be evicted so the pod fits in the node, the lower
+// priorities are evicted first. A deployment's last running pod is
+// never evicted.
+func nodeVictims(c *model.ClusterState, pod *model.Pod, node *model.Node, remained *mat.VecDense) ([]*model.Pod, bool) {
+	var candidates []*model.Pod
+	for _, edgePod := range c.Edge.Pods {
+		if edgePod.Node.Id == node.Id && edgePod.Group == nil && edgePod.Deployment.Priority < pod.Deployment.Priority {
+			candidates = append(candidates, edgePod)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Deployment.Priority < candidates[j].Deployment.Priority
+	})
+
+	free := mat.VecDenseCopyOf(remained)
+	var victims []*model.Pod
+	for _, candidate := range candidates {
+		if utils.LEThan(pod.Deployment.ResourcesRequired, free) {
+			break
+		}
+		if !isAllowedToMove(c, victims, []*model.Pod{candidate}) {
+			continue
+		}
+
+		victims = append(victims, candidate)
+		utils.SAddVec(free, candidate.Deployment.ResourcesRequired)
+	}
+	if !utils.LEThan(pod.Deployment.ResourcesRequired, free) {
+		return nil, false
+	}
+
+	// Like kube-scheduler, the victims with higher priorities
+	// are spared if the pod still fits without them.
+	var evicted []*model.Pod
+	for ind := len(victims) - 1; ind >= 0; ind-- {
+		victim := victims[ind]
+		utils.SSubVec(free, victim.Deployment.ResourcesRequired)
+		if utils.LEThan(pod.Deployment.ResourcesRequired, free) {
+			continue
+		}
+
+		utils.SAddVec(free, victim.Deployment.ResourcesRequired)
+		evicted = append(evicted, victim)
+	}
+
+	// evicted has the victims in the reverse order
+	ret := make([]*model.Pod, 0, len(evicted))
+	for ind := len(evicted) - 1; ind >= 0; ind-- {
+		ret = append(ret, evicted[ind])
+	}
+
+	return ret, true
+}
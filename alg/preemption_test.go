@@ -0,0 +1,64 @@
+package alg
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestPreemption(t *testing.T) {
+	setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "low", Cpu: 1, Memory: 1, EdgeShare: 1, Priority: 0},
+		{Name: "high", Cpu: 2, Memory: 2, EdgeShare: 1, Priority: 1000},
+	})
+	low := builder.Deployments["low"]
+
+	clusterState := builder.GetCluster(map[*testing_tool.NodeDesc][]string{
+		{Cpu: 2, Memory: 2}: {"low", "low"},
+	}, []string{"low"})
+
+	pods := builder.GetPods([]string{"high"})
+	preemptions := Preempt(clusterState, pods)
+	if len(preemptions) != 1 {
+		t.Fatalf("got %d preemptions, wanted 1", len(preemptions))
+	}
+	if preemptions[0].Pod != pods[0] || preemptions[0].Node != clusterState.Edge.Config.Nodes[0] {
+		t.Errorf("the high priority pod is not placed on the edge node")
+	}
+	if len(preemptions[0].Victims) != 2 {
+		t.Errorf("got %d victims, wanted both low priority pods", len(preemptions[0].Victims))
+	}
+	for _, victim := range preemptions[0].Victims {
+		if victim.Deployment != low || clusterState.PodsMap[victim.Id] != victim {
+			t.Errorf("pod %d is not a low priority pod of the cluster state", victim.Id)
+		}
+	}
+	if len(clusterState.Edge.Pods) != 2 {
+		t.Errorf("the cluster state has changed")
+	}
+
+	// The last running pod of a deployment is never a victim.
+	clusterState.Cloud.Pods[0].Status = model.SCHEDULED
+	if preemptions := Preempt(clusterState, builder.GetPods([]string{"high"})); len(preemptions) != 0 {
+		t.Errorf("preempted all the running pods of a deployment")
+	}
+
+	// Lower priorities never displace higher ones.
+	clusterState = builder.GetCluster(map[*testing_tool.NodeDesc][]string{
+		{Cpu: 2, Memory: 2}: {"high"},
+	}, []string{"high"})
+
+	pods = builder.GetPods([]string{"low"})
+	if preemptions := Preempt(clusterState, pods); len(preemptions) != 0 {
+		t.Errorf("the low priority pod preempted a high priority pod")
+	}
+
+	decision := MakeDecisionForNewPods(clusterState, pods, true)
+	if len(decision.ToEdgePods) != 0 || len(decision.EdgeToCloudOffloadingPods) != 0 {
+		t.Errorf("the low priority pod freed a high priority pod from edge")
+	}
+}
@@ -0,0 +1,185 @@
+package alg
+
+import (
+	"math"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Returns the edge pods on each edge node,
+// without the pods that are leaving their nodes.
+func podsOnNodes(c *model.ClusterState, leavingPods ...[]*model.Pod) map[int][]*model.Pod {
+	leaving := make(map[int]bool)
+	for _, pods := range leavingPods {
+		for _, pod := range pods {
+			leaving[pod.Id] = true
+		}
+	}
+
+	nodePods := make(map[int][]*model.Pod)
+	for _, pod := range c.Edge.Pods {
+		if !leaving[pod.Id] {
+			nodePods[pod.Node.Id] = append(nodePods[pod.Node.Id], pod)
+		}
+	}
+
+	return nodePods
+}
+
+// Returns a copy of the pods on nodes with the mapped pods added.
+func withMapping(nodePods map[int][]*model.Pod, pods []*model.Pod, mapping map[int]*model.Node) map[int][]*model.Pod {
+	ret := make(map[int][]*model.Pod)
+	for nodeId, podsOnNode := range nodePods {
+		ret[nodeId] = append([]*model.Pod(nil), podsOnNode...)
+	}
+	for _, pod := range pods {
+		if node, ok := mapping[pod.Id]; ok {
+			ret[node.Id] = append(ret[node.Id], pod)
+		}
+	}
+
+	return ret
+}
+
+// Returns whether the pod can join the pods on a node without
+// breaking the maximum pods per node or the anti-affinity rules.
+func canJoin(pod *model.Pod, nodePods []*model.Pod) bool {
+	var count int
+	for _, other := range nodePods {
+		if other.Deployment.Id == pod.Deployment.Id {
+			count++
+		}
+		if model.IsAntiAffine(pod.Deployment, other.Deployment) {
+			return false
+		}
+	}
+
+	maxPerNode := pod.Deployment.Spread.MaxPerNode
+	return maxPerNode == 0 || count < maxPerNode
+}
+
+// Returns how many pods the deployments are over their maximum
+// zone skews, summed over the deployments, given the edge pods
+// on each node. The zones are the ones of the edge nodes that
+// each deployment is allowed on.
+func zoneSkewViolations(edgeConfig *model.EdgeConfig, nodePods map[int][]*model.Pod) int {
+	deployments := make(map[int]*model.Deployment)
+	zoneCounts := make(map[int]map[string]int)
+	for _, node := range edgeConfig.Nodes {
+		for _, pod := range nodePods[node.Id] {
+			if pod.Deployment.Spread.MaxZoneSkew == 0 {
+				continue
+			}
+
+			if _, ok := zoneCounts[pod.Deployment.Id]; !ok {
+				deployments[pod.Deployment.Id] = pod.Deployment
+				zoneCounts[pod.Deployment.Id] = make(map[string]int)
+			}
+			zoneCounts[pod.Deployment.Id][node.Zone]++
+		}
+	}
+
+	var violations int
+	for deploymentId, counts := range zoneCounts {
+		deployment := deployments[deploymentId]
+
+		minCount, maxCount := math.MaxInt, 0
+		for _, node := range edgeConfig.Nodes {
+			if deployment.CanRunOn(node) {
+				minCount = min(minCount, counts[node.Zone])
+				maxCount = max(maxCount, counts[node.Zone])
+			}
+		}
+
+		violations += max(0, maxCount-minCount-deployment.Spread.MaxZoneSkew)
+	}
+
+	return violations
+}
+
+// Moves the mapped pods to the nodes of other zones, one at a time,
+// as long as the zone skew violations get lower. FitInEdge keeps the
+// other spread rules, but it can not see the zones.
+// The resources remained are of the nodes before the mapping.
+func spreadOverZones(
+	edgeConfig *model.EdgeConfig,
+	nodeResourcesRemained map[int]*mat.VecDense,
+	nodePods map[int][]*model.Pod,
+	pods []*model.Pod,
+	mapping map[int]*model.Node,
+) {
+	remained := make(map[int]*mat.VecDense)
+	for nodeId, resources := range nodeResourcesRemained {
+		remained[nodeId] = mat.VecDenseCopyOf(resources)
+	}
+	for _, pod := range pods {
+		if node, ok := mapping[pod.Id]; ok {
+			utils.SSubVec(remained[node.Id], pod.Deployment.ResourcesRequired)
+		}
+	}
+
+	moveOne := func(violations int) bool {
+		current := withMapping(nodePods, pods, mapping)
+		for _, pod := range pods {
+			from, ok := mapping[pod.Id]
+			if !ok || pod.Deployment.Spread.MaxZoneSkew == 0 {
+				continue
+			}
+
+			for _, node := range edgeConfig.Nodes {
+				if node.Zone == from.Zone || !pod.Deployment.CanRunOn(node) || !canJoin(pod, current[node.Id]) ||
+					!utils.LEThan(pod.Deployment.ResourcesRequired, remained[node.Id]) {
+					continue
+				}
+
+				mapping[pod.Id] = node
+				if zoneSkewViolations(edgeConfig, withMapping(nodePods, pods, mapping)) < violations {
+					utils.SAddVec(remained[from.Id], pod.Deployment.ResourcesRequired)
+					utils.SSubVec(remained[node.Id], pod.Deployment.ResourcesRequired)
+					return true
+				}
+				mapping[pod.Id] = from
+			}
+		}
+
+		return false
+	}
+
+	for {
+		violations := zoneSkewViolations(edgeConfig, withMapping(nodePods, pods, mapping))
+		if violations == 0 || !moveOne(violations) {
+			return
+		}
+	}
+}
+
+// Returns how many pods break the spread rules in the cluster, which is
+// the sum of the pods over the maximum pods per node, the pods sharing a
+// node with an anti-affine pod and the pods over the maximum zone skews.
+// Mappings only break the zone skews, when they can not be kept, the
+// other rules are broken by the pods placed before the rules changed.
+func SpreadViolations(c *model.ClusterState) int {
+	nodePods := podsOnNodes(c)
+
+	var violations int
+	for _, pods := range nodePods {
+		counts := make(map[int]int)
+		for _, pod := range pods {
+			counts[pod.Deployment.Id]++
+			if maxPerNode := pod.Deployment.Spread.MaxPerNode; maxPerNode > 0 && counts[pod.Deployment.Id] > maxPerNode {
+				violations++
+			}
+
+			for _, other := range pods {
+				if other.Id != pod.Id && model.IsAntiAffine(pod.Deployment, other.Deployment) {
+					violations++
+					break
+				}
+			}
+		}
+	}
+
+	return violations + zoneSkewViolations(c.Edge.Config, nodePods)
+}
@@ -0,0 +1,79 @@
+package alg
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestSpread(t *testing.T) {
+	setUp()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "C", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	a, b, c := builder.Deployments["A"], builder.Deployments["B"], builder.Deployments["C"]
+	a.Labels = map[string]string{"app": "a"}
+	a.Spread.MaxPerNode = 1
+	b.Spread.AntiAffinity = []model.LabelSelector{{{Key: "app", Operator: model.IN_OPERATOR, Values: []string{"a"}}}}
+	c.Spread.MaxZoneSkew = 1
+
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 4, Memory: 4},
+		{Cpu: 4, Memory: 4},
+		{Cpu: 4, Memory: 4},
+	})
+	nodes := clusterState.Edge.Config.Nodes
+	nodes[0].Zone, nodes[1].Zone, nodes[2].Zone = "north", "north", "south"
+
+	// At most one A pod on each node.
+	pods := builder.GetPods([]string{"A", "A", "A", "A"})
+	mapping := MapPodToEdge(clusterState, pods, nil, nil)
+	if len(mapping.Mapping) != 3 {
+		t.Fatalf("got %d mapped A pods, wanted 3", len(mapping.Mapping))
+	}
+	for _, pod := range pods {
+		if node, ok := mapping.Mapping[pod.Id]; ok {
+			if err := clusterState.DeployEdge(pod, node); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if violations := SpreadViolations(clusterState); violations != 0 {
+		t.Errorf("got %d violations, wanted 0", violations)
+	}
+
+	// B pods do not share a node with A pods.
+	if mapping := MapPodToEdge(clusterState, builder.GetPods([]string{"B"}), nil, nil); len(mapping.Mapping) != 0 {
+		t.Errorf("the B pod is mapped to a node with an A pod")
+	}
+	for _, pod := range clusterState.Edge.Pods[:] {
+		if pod.Node == nodes[0] {
+			clusterState.RemovePod(pod)
+		}
+	}
+	if mapping := firstFit(clusterState, builder.GetPods([]string{"B"})); len(mapping) != 1 {
+		t.Errorf("the B pod is not mapped to the node without A pods")
+	}
+
+	// C pods are spread over the zones.
+	pods = builder.GetPods([]string{"C", "C"})
+	mapping = MapPodToEdge(clusterState, pods, nil, nil)
+	if mapping.Violations != 0 || mapping.Mapping[pods[0].Id].Zone == mapping.Mapping[pods[1].Id].Zone {
+		t.Errorf("the C pods are not spread over the zones")
+	}
+
+	// Pods placed before the rules are counted as violations.
+	for _, pod := range pods {
+		if err := clusterState.DeployEdge(pod, nodes[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if violations := SpreadViolations(clusterState); violations != 1 {
+		t.Errorf("got %d violations, wanted 1", violations)
+	}
+}
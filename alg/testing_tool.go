@@ -10,6 +10,15 @@ import (
 )
 
 func TestingApplyDecision(clusterState *model.ClusterState, decision model.DecisionForNewPods) {
+	TestingApplyAlgorithmDecision(clusterState, &qosAlgorithm{}, decision)
+}
+
+func TestingApplySuggestion(clusterState *model.ClusterState, suggestion model.ReorderSuggestion) {
+	TestingApplyAlgorithmSuggestion(clusterState, &qosAlgorithm{}, suggestion)
+}
+
+// Applies the decision, mapping edge pods to nodes with the algorithm.
+func TestingApplyAlgorithmDecision(clusterState *model.ClusterState, algorithm Algorithm, decision model.DecisionForNewPods) {
 	for _, pod := range decision.EdgeToCloudOffloadingPods {
 		if ok := clusterState.RemovePod(pod); !ok {
 			panic(fmt.Sprintf("pod %d was not on edge, but tried to be removed", pod.Id))
@@ -32,7 +41,7 @@ func TestingApplyDecision(clusterState *model.ClusterState, decision model.Decis
 		clusterState.DeployCloud(pod)
 	}
 
-	edgeMapping := MapPodToEdge(clusterState, decision.ToEdgePods, nil, nil).Mapping
+	edgeMapping := algorithm.MapPodToEdge(clusterState, decision.ToEdgePods, nil, nil).Mapping
 
 	for _, pod := range decision.ToEdgePods {
 		if node, ok := edgeMapping[pod.Id]; ok {
@@ -46,11 +55,11 @@ func TestingApplyDecision(clusterState *model.ClusterState, decision model.Decis
 	}
 }
 
-func TestingApplySuggestion(clusterState *model.ClusterState, suggestion model.ReorderSuggestion) {
+func TestingApplyAlgorithmSuggestion(clusterState *model.ClusterState, algorithm Algorithm, suggestion model.ReorderSuggestion) {
 	for _, pod := range suggestion.CloudToEdgePods {
 		if !clusterState.RemovePod(pod) {
 			panic(fmt.Sprintf("could not remove pod %d", pod.Id))
 		}
 	}
-	TestingApplyDecision(clusterState, suggestion.Decision)
+	TestingApplyAlgorithmDecision(clusterState, algorithm, suggestion.Decision)
 }
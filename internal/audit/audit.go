@@ -0,0 +1,195 @@
+// Audit log of the scheduler's decisions, each decision is kept
+// as a structured record explaining why each pod is placed where
+// it is, so it can be queried by pod later.
+// Records are kept in memory and, if a path is given,
+// appended to a file as JSON lines.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/amsen20/ecmus/logging"
+)
+
+var log = logging.Get()
+
+// Maximum number of records kept in memory,
+// the oldest ones are forgotten first.
+const MEMORY_LIMIT = 1000
+
+// Record types:
+const (
+	PLACING    = "placing"
+	REORDERING = "reordering"
+)
+
+type PodRecord struct {
+	Id         int   `json:"id"`
+	Deployment int   `json:"deployment"`
+	Priority   int32 `json:"priority"`
+	// -1 if the pod has no node
+	Node int `json:"node"`
+	// name of the pod's group, if it has one
+	Group string `json:"group,omitempty"`
+}
+
+type SplitRecord struct {
+	EdgePods []int   `json:"edge_pods"`
+	Score    float64 `json:"score"`
+}
+
+type MigrationRecord struct {
+	Pod  int `json:"pod"`
+	Node int `json:"node"`
+}
+
+type PreemptionRecord struct {
+	Pod     int   `json:"pod"`
+	Node    int   `json:"node"`
+	Victims []int `json:"victims"`
+}
+
+type Record struct {
+	Id        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Algorithm string    `json:"algorithm"`
+
+	// Inputs of the decision:
+	// the pods that are decided about
+	NewPods []PodRecord `json:"new_pods"`
+	// free resources of each edge node by resource name
+	FreeResources map[int]map[string]float64 `json:"free_resources"`
+
+	// Splits of the new pods that the algorithm considered.
+	ConsideredSplits int           `json:"considered_splits"`
+	BestSplits       []SplitRecord `json:"best_splits"`
+
+	// The decision:
+	// null if the algorithm found no possible decision
+	Score       *float64          `json:"score"`
+	ToEdgePods  []int             `json:"to_edge_pods"`
+	ToCloudPods []int             `json:"to_cloud_pods"`
+	FreedPods   []int             `json:"freed_pods"`
+	Migrations  []MigrationRecord `json:"migrations"`
+	// Pods evicting lower priority pods from edge to cloud.
+	Preemptions []PreemptionRecord `json:"preemptions"`
+	// Final node of each pod, from mapping pods to edge nodes.
+	Mapping map[int]int `json:"mapping"`
+
+	// Why each pod involved in the decision is placed where it is.
+	Reasons map[int]string `json:"reasons"`
+}
+
+// Whether the pod is involved in the record's decision.
+func (record *Record) Involves(podId int) bool {
+	_, ok := record.Reasons[podId]
+	return ok
+}
+
+type Log struct {
+	records []*Record
+	nextId  int
+	file    *os.File
+
+	mutex sync.Mutex
+}
+
+// Returns an audit log, which also appends its
+// records to the file at path if it is not empty.
+func New(path string) (*Log, error) {
+	ret := &Log{}
+	if path == "" {
+		return ret, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not open the audit log file")
+	}
+	ret.file = file
+
+	return ret, nil
+}
+
+// Adds the record to the log and sets its id.
+func (l *Log) Add(record *Record) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record.Id = l.nextId
+	l.nextId++
+
+	l.records = append(l.records, record)
+	if len(l.records) > MEMORY_LIMIT {
+		l.records = l.records[len(l.records)-MEMORY_LIMIT:]
+	}
+
+	if l.file == nil {
+		return
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		log.Err(err).Msg("couldn't marshal the audit record")
+		return
+	}
+	if _, err := l.file.Write(append(content, '\n')); err != nil {
+		log.Err(err).Msg("couldn't write the audit record")
+	}
+}
+
+// Closes the log's file, the records added after
+// that are only kept in memory.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		log.Err(err).Send()
+
+		return fmt.Errorf("could not close the audit log file")
+	}
+
+	return nil
+}
+
+// Returns the records that involve the pod, the newest first.
+func (l *Log) ForPod(podId int) []*Record {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ret := make([]*Record, 0)
+	for ind := len(l.records) - 1; ind >= 0; ind-- {
+		if l.records[ind].Involves(podId) {
+			ret = append(ret, l.records[ind])
+		}
+	}
+
+	return ret
+}
+
+// Returns at most limit of the newest records, the newest first.
+func (l *Log) Latest(limit int) []*Record {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ret := make([]*Record, 0)
+	for ind := len(l.records) - 1; ind >= 0 && len(ret) < limit; ind-- {
+		ret = append(ret, l.records[ind])
+	}
+
+	return ret
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score := 1.5
+	auditLog.Add(&Record{Type: PLACING, Score: &score, Reasons: map[int]string{1: "on edge", 2: "on cloud"}})
+	auditLog.Add(&Record{Type: REORDERING, Reasons: map[int]string{2: "moved to edge"}})
+
+	records := auditLog.ForPod(2)
+	if len(records) != 2 || records[0].Type != REORDERING || records[1].Id != 0 {
+		t.Fatalf("got %d records for pod 2, wanted the two records, the newest first", len(records))
+	}
+	if len(auditLog.ForPod(3)) != 0 {
+		t.Fatalf("pod 3 is not involved in any record")
+	}
+	if len(auditLog.Latest(1)) != 1 {
+		t.Fatalf("latest records should be limited")
+	}
+
+	// Records added after closing are only kept in memory.
+	if err := auditLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	auditLog.Add(&Record{Type: PLACING, Reasons: map[int]string{2: "on cloud"}})
+	if len(auditLog.ForPod(2)) != 3 {
+		t.Fatalf("the record added after closing is not kept")
+	}
+	if err := auditLog.Close(); err != nil {
+		t.Fatalf("closing twice failed: %s", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var persisted []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		persisted = append(persisted, record)
+	}
+	if len(persisted) != 2 || *persisted[0].Score != score || persisted[1].Score != nil {
+		t.Fatalf("records are not persisted as JSON lines")
+	}
+}
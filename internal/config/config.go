@@ -1,5 +1,12 @@
 package config
 
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
 // Scheduler's general config, which is a
 // singleton object read at the first of execution
 // from a yaml file and used all across the code.
@@ -8,18 +15,80 @@ type generalConfig struct {
 	Name string `yaml:"name"`
 	// Scheduler's namespace of events, important for the connector
 	Namespace string `yaml:"namespace"`
+	// Namespaces of the workloads the scheduler manages, the
+	// scheduler's namespace if empty and all namespaces if it has "*".
+	Namespaces []string `yaml:"namespaces"`
+	// Label selector of the workloads (deployments and stateful sets)
+	// the scheduler manages, all of them if empty.
+	// Pods are managed if their workload is.
+	WorkloadSelector string `yaml:"workload_selector"`
+	// In dry run (shadow) mode the scheduler makes its decisions
+	// but never binds or deletes pods, it only records what it
+	// would have done, so it can be compared with another scheduler.
+	DryRun bool `yaml:"dry_run"`
+	// Name of the scheduler whose pods are watched, equal to
+	// the scheduler's name if empty. In dry run mode it should be
+	// the scheduler that actually binds the pods, like default-scheduler.
+	WatchedSchedulerName string `yaml:"watched_scheduler_name"`
 	// Number of resources of each node provides and
 	// the scheduler should care, for tests and current evaluation
 	// it is 2 (CPU and memory).
+	// It is optional and always equal to the length of Resources.
 	ResourceCount int `yaml:"resource_count"`
+	// The resources each node provides and the scheduler
+	// should care, in the same order as the entries of
+	// all resource vectors. CPU and memory if empty.
+	Resources []ResourceConfig `yaml:"resources"`
+	// Which resources of the containers size the pods, either
+	// "limits" (default) or "requests". Resources without a limit
+	// are sized by their request, like kube-scheduler does.
+	ResourceSizing string `yaml:"resource_sizing"`
 	// The connector's name that the scheduler should connect to
-	// For now it is either kubernetes or const
+	// For now it is either kubernetes, kubernetes_cached (which
+	// reads the cluster from informers' caches) or const
 	ConnectorKind string `yaml:"connector"`
 	// The connector config path.
 	ConnectorConfigPath string `yaml:"connector_config"`
+	// How the connector should load its config, for kubernetes it is
+	// either "in-cluster", "kubeconfig" (the file at connector config path)
+	// or "auto" (default) which tries in-cluster config first and
+	// falls back to the kubeconfig file.
+	ConnectorConfigMode string `yaml:"connector_config_mode"`
+	// The context of the connector config to use,
+	// if empty the config's current context is used.
+	ConnectorContext string `yaml:"connector_context"`
+	// Name of the scheduling algorithm, either "qos" (default),
+	// "first_fit" or any algorithm registered in the alg package.
+	Algorithm string `yaml:"algorithm"`
+	// The solver used by the qos algorithm for deciding which
+	// new pods go to edge, either "exhaustive" (default) which
+	// tries all subsets of the new pods, or "branch_and_bound"
+	// which makes the same decisions and scales to larger batches.
+	DecisionSolver string `yaml:"decision_solver"`
+	// The QoS model the algorithms optimize, either "share" (default)
+	// where a pod counts fully if it is on any edge node, or "latency"
+	// where it counts as much as its node is closer than cloud to
+	// the users of its deployment.
+	QoSModel string `yaml:"qos_model"`
+	// Round trip time (ms) of the users to cloud, the latency
+	// QoS model counts nodes this far from the users as cloud.
+	CloudLatency float64 `yaml:"cloud_latency"`
+	// Weight of the cost of the pods placed on cloud in the
+	// decisions' scores, placing pods on cloud is free if it is 0.
+	CloudCostWeight float64 `yaml:"cloud_cost_weight"`
+	// Path of the file that the scheduler's decisions are
+	// appended to for auditing, they are only kept in memory if empty.
+	AuditLogPath string `yaml:"audit_log_path"`
 	// Maximum number of migrations in a single decision of the scheduler,
 	// It is important to keep this number low.
 	MaximumMigrations int `yaml:"maximum_migrations"`
+	// Migrations (pods deleted by reorders to be moved) are rate
+	// limited in windows of this duration, no limit if it is 0.
+	MigrationWindowDuration int `yaml:"migration_window_duration"` // ms
+	// Maximum number of migrations in a window, of the whole
+	// cluster and of each deployment, no limit if it is 0.
+	MaximumWindowMigrations           int `yaml:"maximum_window_migrations"`
+	MaximumDeploymentWindowMigrations int `yaml:"maximum_deployment_window_migrations"`
 	// Maximum number of pods that can be chosen from cloud to
 	// be moved to edge in a single cloud suggestion of the scheduler.
 	MaximumCloudOffload int `yaml:"maximum_cloud_offload"`
@@ -42,8 +111,178 @@ type generalConfig struct {
 	BatchSize int `yaml:"batch_size"`
 }
 
+// A single kind of resource that nodes provide and pods require.
+type ResourceConfig struct {
+	// Kubernetes' resource name, like cpu, memory,
+	// ephemeral-storage, pods or example.com/fpga.
+	Name string `yaml:"name"`
+	// Quantities of the resource are divided by the scale,
+	// for example memory is counted in MBs. 1 if not set.
+	Scale float64 `yaml:"scale"`
+	// Amount of the resource (after scaling) that is kept free on
+	// each node for the processes that are not pods, the requests of
+	// the pods that the scheduler does not manage are reserved apart.
+	Reserved float64 `yaml:"reserved"`
+	// Resources that most of the pods do not require, like
+	// extended resources, should be ignored when computing
+	// fragmentation, otherwise they dominate it.
+	IgnoreInFragmentation bool `yaml:"ignore_in_fragmentation"`
+}
+
 // Shared scheduler's general config object.
 var SchedulerGeneralConfig generalConfig
 
 // General constants:
 const MB = 1e6
+
+// Ways of sizing the pods, see generalConfig.ResourceSizing.
+const (
+	LIMITS_SIZING   = "limits"
+	REQUESTS_SIZING = "requests"
+)
+
+// QoS models, see generalConfig.QoSModel.
+const (
+	SHARE_QOS_MODEL   = "share"
+	LATENCY_QOS_MODEL = "latency"
+)
+
+// Ways of loading the connector's config, see generalConfig.ConnectorConfigMode.
+const (
+	IN_CLUSTER_CONFIG_MODE = "in-cluster"
+	KUBECONFIG_CONFIG_MODE = "kubeconfig"
+	AUTO_CONFIG_MODE       = "auto"
+)
+
+// Solvers of the decision for new pods, see generalConfig.DecisionSolver.
+const (
+	EXHAUSTIVE_SOLVER       = "exhaustive"
+	BRANCH_AND_BOUND_SOLVER = "branch_and_bound"
+)
+
+// Round trip time (ms) of the users to cloud if it is not configured.
+const DEFAULT_CLOUD_LATENCY = 100
+
+// Resources that are used if no resource is configured.
+var defaultResources = []ResourceConfig{
+	{Name: "cpu", Scale: 1, Reserved: 1},
+	{Name: "memory", Scale: MB, Reserved: 1000},
+}
+
+// Reads the config from the yaml content to the shared config object,
+// fills the defaults and validates it.
+func Load(content []byte) error {
+	SchedulerGeneralConfig = generalConfig{}
+	if err := yaml.UnmarshalStrict(content, &SchedulerGeneralConfig); err != nil {
+		return err
+	}
+
+	return SchedulerGeneralConfig.normalize()
+}
+
+func (c *generalConfig) normalize() error {
+	if len(c.Resources) == 0 {
+		c.Resources = append(c.Resources, defaultResources...)
+	}
+
+	if c.ResourceCount != 0 && c.ResourceCount != len(c.Resources) {
+		return fmt.Errorf("resource count is %d but %d resources are configured", c.ResourceCount, len(c.Resources))
+	}
+	c.ResourceCount = len(c.Resources)
+
+	if c.WatchedSchedulerName == "" {
+		c.WatchedSchedulerName = c.Name
+	}
+
+	if len(c.Namespaces) == 0 {
+		c.Namespaces = []string{c.Namespace}
+	}
+	for _, namespace := range c.Namespaces {
+		if namespace == "*" {
+			// The empty namespace is all namespaces for kubernetes.
+			c.Namespaces = []string{""}
+			break
+		}
+	}
+
+	if _, err := labels.Parse(c.WorkloadSelector); err != nil {
+		return fmt.Errorf("workload selector %q is not valid: %s", c.WorkloadSelector, err)
+	}
+
+	switch c.ResourceSizing {
+	case "":
+		c.ResourceSizing = LIMITS_SIZING
+	case LIMITS_SIZING, REQUESTS_SIZING:
+	default:
+		return fmt.Errorf("resource sizing %q is not recognized", c.ResourceSizing)
+	}
+
+	switch c.QoSModel {
+	case "":
+		c.QoSModel = SHARE_QOS_MODEL
+	case SHARE_QOS_MODEL, LATENCY_QOS_MODEL:
+	default:
+		return fmt.Errorf("qos model %q is not recognized", c.QoSModel)
+	}
+
+	if c.CloudLatency == 0 {
+		c.CloudLatency = DEFAULT_CLOUD_LATENCY
+	}
+	if c.CloudLatency < 0 {
+		return fmt.Errorf("cloud latency %f is negative", c.CloudLatency)
+	}
+
+	if c.CloudCostWeight < 0 {
+		return fmt.Errorf("cloud cost weight %f is negative", c.CloudCostWeight)
+	}
+
+	if c.MigrationWindowDuration < 0 || c.MaximumWindowMigrations < 0 || c.MaximumDeploymentWindowMigrations < 0 {
+		return fmt.Errorf("migration window and its maximum migrations should not be negative")
+	}
+
+	switch c.ConnectorConfigMode {
+	case "":
+		c.ConnectorConfigMode = AUTO_CONFIG_MODE
+	case IN_CLUSTER_CONFIG_MODE, KUBECONFIG_CONFIG_MODE, AUTO_CONFIG_MODE:
+	default:
+		return fmt.Errorf("connector config mode %q is not recognized", c.ConnectorConfigMode)
+	}
+
+	switch c.DecisionSolver {
+	case "":
+		c.DecisionSolver = EXHAUSTIVE_SOLVER
+	case EXHAUSTIVE_SOLVER, BRANCH_AND_BOUND_SOLVER:
+	default:
+		return fmt.Errorf("decision solver %q is not recognized", c.DecisionSolver)
+	}
+
+	seen := make(map[string]bool)
+	for ind := range c.Resources {
+		resource := &c.Resources[ind]
+		if resource.Name == "" {
+			return fmt.Errorf("resource %d has no name", ind)
+		}
+		if seen[resource.Name] {
+			return fmt.Errorf("resource %s is configured more than once", resource.Name)
+		}
+		seen[resource.Name] = true
+
+		if resource.Scale == 0 {
+			resource.Scale = 1
+		}
+	}
+
+	return nil
+}
+
+// Returns the index of the resource in resource vectors,
+// or -1 if the resource is not configured.
+func ResourceIndex(name string) int {
+	for ind, resource := range SchedulerGeneralConfig.Resources {
+		if resource.Name == name {
+			return ind
+		}
+	}
+
+	return -1
+}
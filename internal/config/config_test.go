@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestLoadValidatesNames(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		content     string
+		wantedError bool
+	}{
+		{"defaults", "name: ecmus", false},
+		{"connector config mode", "connector_config_mode: kubeconfig", false},
+		{"unknown connector config mode", "connector_config_mode: kube-config", true},
+		{"decision solver", "decision_solver: branch_and_bound", false},
+		{"unknown decision solver", "decision_solver: greedy", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := Load([]byte(test.content))
+			if (err != nil) != test.wantedError {
+				t.Fatalf("got error %v, wanted an error: %v", err, test.wantedError)
+			}
+		})
+	}
+
+	if err := Load([]byte("name: ecmus")); err != nil {
+		t.Fatal(err)
+	}
+	if SchedulerGeneralConfig.ConnectorConfigMode != AUTO_CONFIG_MODE || SchedulerGeneralConfig.DecisionSolver != EXHAUSTIVE_SOLVER {
+		t.Errorf(
+			"got connector config mode %q and decision solver %q, wanted the defaults",
+			SchedulerGeneralConfig.ConnectorConfigMode,
+			SchedulerGeneralConfig.DecisionSolver,
+		)
+	}
+}
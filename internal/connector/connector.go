@@ -3,6 +3,7 @@ package connector
 import (
 	"github.com/amsen20/ecmus/internal/model"
 	"github.com/amsen20/ecmus/logging"
+	"gonum.org/v1/gonum/mat"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,16 +33,43 @@ type Connector interface {
 	DeletePod(pod *model.Pod) (bool, error)
 
 	// Method which channel all events related
-	// to the scheduler.
+	// to the scheduler, including changes of
+	// the cluster's nodes and deployments
+	// after they are found.
 	WatchSchedulingEvents() (<-chan *Event, error)
 }
 
+// Connectors whose watch can be closed on demand, like a
+// watch that is timed out by the API-server. After closing,
+// events are lost until the events are watched again.
+type WatchCloser interface {
+	CloseWatch()
+}
+
+// Connectors that keep running in the background, like
+// informers, which should be stopped with the scheduler.
+type Stopper interface {
+	Stop()
+}
+
 type EventType int64
 
 const (
 	POD_CREATED EventType = iota
 	POD_CHANGED
 	POD_DELETED
+	NODE_ADDED
+	NODE_CHANGED
+	NODE_REMOVED
+	DEPLOYMENT_ADDED
+	DEPLOYMENT_CHANGED
+	DEPLOYMENT_DELETED
+	// events may have been missed, the scheduler
+	// should resync its view of the cluster
+	RESYNC_REQUIRED
+	// resources of an edge node used by the pods
+	// that the scheduler does not manage have changed
+	NODE_RESERVED_CHANGED
 )
 
 // All connectors regardless of what kind of
@@ -52,11 +80,30 @@ const (
 // is related to, the node which may be related
 // to the event and the status of the pod AFTER
 // the event occurred.
+// Node and deployment events carry no pod, only the
+// node (and whether it is on "edge" or "cloud") or
+// the deployment AFTER the event occurred.
+// Node reserved events carry the node's reserved resources.
 type Event struct {
-	EventType EventType       `yaml:"event_type"`
-	Pod       *model.Pod      `yaml:"pod"`
-	Node      *model.Node     `yaml:"node"`
-	Status    model.PodStatus `yaml:"status"`
+	EventType  EventType         `yaml:"event_type"`
+	Pod        *model.Pod        `yaml:"pod"`
+	Node       *model.Node       `yaml:"node"`
+	Status     model.PodStatus   `yaml:"status"`
+	NodeType   string            `yaml:"node_type"`
+	Deployment *model.Deployment `yaml:"deployment"`
+	Reserved   *mat.VecDense     `yaml:"reserved"`
+}
+
+// Returns whether the event is about the cluster's
+// topology (nodes and deployments) rather than a pod.
+func (event *Event) IsTopologyEvent() bool {
+	switch event.EventType {
+	case NODE_ADDED, NODE_CHANGED, NODE_REMOVED, NODE_RESERVED_CHANGED,
+		DEPLOYMENT_ADDED, DEPLOYMENT_CHANGED, DEPLOYMENT_DELETED:
+		return true
+	}
+
+	return false
 }
 
 func (event *Event) String() string {
@@ -0,0 +1,217 @@
+package connector
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+)
+
+type FaultKind int
+
+// Faults that can be injected:
+const (
+	// deploying returns an error
+	BIND_ERROR FaultKind = iota
+	// deleting a pod returns an error
+	DELETE_ERROR
+	// pods are deployed on another node than the asked one
+	UNWANTED_NODE
+	// the event stream is closed (at the fault's start)
+	WATCH_CLOSED
+	// listing pods returns an error
+	LIST_ERROR
+)
+
+// A fault injected while the clock is in [From, Until).
+type Fault struct {
+	Kind  FaultKind
+	From  time.Duration
+	Until time.Duration
+}
+
+func (kind FaultKind) String() string {
+	switch kind {
+	case BIND_ERROR:
+		return "bind error"
+	case DELETE_ERROR:
+		return "delete error"
+	case UNWANTED_NODE:
+		return "unwanted node"
+	case WATCH_CLOSED:
+		return "watch closed"
+	case LIST_ERROR:
+		return "list error"
+	}
+
+	return "unknown"
+}
+
+// This connector wraps another connector and injects
+// faults in it on a schedule of the virtual clock,
+// for testing how the scheduler tolerates them.
+type FaultyConnector struct {
+	Connector
+
+	clock  *VirtualClock
+	faults []Fault
+	// number of times each fault is injected
+	injected map[FaultKind]int
+
+	// closes the forwarded event stream, only
+	// for connectors that are not WatchCloser
+	closeWatch chan struct{}
+}
+
+func NewFaultyConnector(connector Connector, clock *VirtualClock, faults []Fault) *FaultyConnector {
+	fc := &FaultyConnector{
+		Connector: connector,
+		clock:     clock,
+		faults:    faults,
+		injected:  make(map[FaultKind]int),
+	}
+
+	for _, fault := range faults {
+		if fault.Kind == WATCH_CLOSED {
+			clock.AfterFunc(fault.From-clock.Now(), fc.closeWatchNow)
+		}
+	}
+
+	return fc
+}
+
+// Returns how many times the fault is injected so far.
+func (fc *FaultyConnector) Injected(kind FaultKind) int {
+	return fc.injected[kind]
+}
+
+// Returns whether the fault should be injected now, and counts it if so.
+func (fc *FaultyConnector) inject(kind FaultKind) bool {
+	now := fc.clock.Now()
+	for _, fault := range fc.faults {
+		if fault.Kind == kind && fault.From <= now && now < fault.Until {
+			log.Warn().Msgf("injecting fault: %s", kind)
+			fc.injected[kind]++
+			return true
+		}
+	}
+
+	return false
+}
+
+func (fc *FaultyConnector) closeWatchNow() {
+	log.Warn().Msgf("injecting fault: %s", WATCH_CLOSED)
+	fc.injected[WATCH_CLOSED]++
+
+	if watchCloser, ok := fc.Connector.(WatchCloser); ok {
+		watchCloser.CloseWatch()
+		return
+	}
+
+	if fc.closeWatch != nil {
+		close(fc.closeWatch)
+		fc.closeWatch = nil
+	}
+}
+
+func (fc *FaultyConnector) GetPendingPods() ([]*model.Pod, error) {
+	if fc.inject(LIST_ERROR) {
+		return nil, fmt.Errorf("injected list error")
+	}
+
+	return fc.Connector.GetPendingPods()
+}
+
+func (fc *FaultyConnector) SyncPods() ([]*model.Pod, error) {
+	if fc.inject(LIST_ERROR) {
+		return nil, fmt.Errorf("injected list error")
+	}
+
+	return fc.Connector.SyncPods()
+}
+
+func (fc *FaultyConnector) Deploy(pod *model.Pod, node *model.Node) error {
+	if fc.inject(BIND_ERROR) {
+		return fmt.Errorf("injected bind error")
+	}
+
+	if fc.inject(UNWANTED_NODE) {
+		if other, ok := fc.otherNode(pod, node); ok {
+			return fc.Connector.Deploy(pod, other)
+		}
+	}
+
+	return fc.Connector.Deploy(pod, node)
+}
+
+// Returns a node other than the given one that the pod fits in,
+// cloud for edge nodes and the first fitting edge node for cloud.
+func (fc *FaultyConnector) otherNode(pod *model.Pod, node *model.Node) (*model.Node, bool) {
+	clusterState := fc.GetClusterState()
+
+	if !clusterState.IsCloudNode(node.Id) {
+		if len(clusterState.Cloud.Nodes) == 0 {
+			return nil, false
+		}
+		return clusterState.Cloud.Nodes[0], true
+	}
+
+	remained := clusterState.GetNodesResourcesRemained()
+	nodes := append([]*model.Node{}, clusterState.Edge.Config.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Id < nodes[j].Id
+	})
+	for _, edgeNode := range nodes {
+		if utils.LEThan(pod.Deployment.ResourcesRequired, remained[edgeNode.Id]) {
+			return edgeNode, true
+		}
+	}
+
+	return nil, false
+}
+
+func (fc *FaultyConnector) DeletePod(pod *model.Pod) (bool, error) {
+	if fc.inject(DELETE_ERROR) {
+		return true, fmt.Errorf("injected delete error")
+	}
+
+	return fc.Connector.DeletePod(pod)
+}
+
+func (fc *FaultyConnector) WatchSchedulingEvents() (<-chan *Event, error) {
+	innerStream, err := fc.Connector.WatchSchedulingEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := fc.Connector.(WatchCloser); ok {
+		return innerStream, nil
+	}
+
+	// The inner stream can not be closed, so events
+	// are forwarded through a stream that can be.
+	eventStream := make(chan *Event)
+	closeWatch := make(chan struct{})
+	fc.closeWatch = closeWatch
+
+	go func() {
+		defer close(eventStream)
+
+		for {
+			select {
+			case <-closeWatch:
+				return
+			case event := <-innerStream:
+				select {
+				case eventStream <- event:
+				case <-closeWatch:
+					return
+				}
+			}
+		}
+	}()
+
+	return eventStream, nil
+}
@@ -5,22 +5,27 @@ package connector
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/model"
 	"github.com/amsen20/ecmus/internal/utils"
-	"gonum.org/v1/gonum/mat"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type KubeConnector struct {
 	// Kubernetes official library client for
 	// contacting API-server.
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
 
 	// The shared cluster state.
 	clusterState *model.ClusterState
@@ -28,34 +33,115 @@ type KubeConnector struct {
 	// Mappings for getting pod, node,
 	// and deployments names easily.
 	nodeIdToName       map[int]string
-	podIdToName        map[int]string
+	podIdToName        map[int]types.NamespacedName
 	deploymentIdToName map[int]string
+
+	// The nodes and deployments given to the scheduler by their ids,
+	// see KubeConnector.indexedNode. They and the mappings above
+	// are guarded by the index lock.
+	nodes       map[int]indexedNode
+	deployments map[int]*model.Deployment
+	indexLock   sync.Mutex
+
+	// The pods that the scheduler does not manage on
+	// edge nodes, their requests are reserved on the nodes.
+	// They are synced by the scheduler and changed by the watch.
+	reservingPods     map[types.NamespacedName]reservingPod
+	reservingPodsLock sync.Mutex
+
+	// Values of the priority classes that are seen.
+	priorityClasses map[string]int32
+
+	// The managed workloads and the disruption budgets of the managed
+	// namespaces by their keys, the workloads are translated again
+	// when the budgets change. They, the priority classes and the
+	// deployments owning the replica sets, empty if none does, are
+	// guarded by the workloads lock.
+	workloads         map[string]*workload
+	disruptionBudgets map[string]*policyv1.PodDisruptionBudget
+	replicaSetOwners  map[string]string
+	workloadsLock     sync.Mutex
+
+	// Gets a replica set by its namespace and name.
+	getReplicaSet func(namespace string, name string) (*appsv1.ReplicaSet, error)
+
+	// Stops the watches of the last WatchSchedulingEvents
+	// and the translation of their events.
+	unwatch     func()
+	unwatchLock sync.Mutex
 }
 
 func NewKubeConnector(clusterState *model.ClusterState) (*KubeConnector, error) {
-	config, err := rest.InClusterConfig()
+	restConfig, err := loadRestConfig()
 	if err != nil {
 		log.Err(err).Send()
 
 		return nil, fmt.Errorf("can't connect to kubernetes cluster")
 	}
 
-	clientSet, err := kubernetes.NewForConfig(config)
+	clientSet, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		log.Err(err).Send()
 
 		return nil, fmt.Errorf("could not init clients")
 	}
 
-	kc := &KubeConnector{
-		clientset:          clientSet,
+	return newKubeConnector(clusterState, clientSet), nil
+}
+
+func newKubeConnector(clusterState *model.ClusterState, clientset kubernetes.Interface) *KubeConnector {
+	return &KubeConnector{
+		clientset:          clientset,
 		clusterState:       clusterState,
 		nodeIdToName:       make(map[int]string),
-		podIdToName:        make(map[int]string),
+		podIdToName:        make(map[int]types.NamespacedName),
 		deploymentIdToName: make(map[int]string),
+		nodes:              make(map[int]indexedNode),
+		deployments:        make(map[int]*model.Deployment),
+		reservingPods:      make(map[types.NamespacedName]reservingPod),
+		priorityClasses:    make(map[string]int32),
+		workloads:          make(map[string]*workload),
+		disruptionBudgets:  make(map[string]*policyv1.PodDisruptionBudget),
+		replicaSetOwners:   make(map[string]string),
+		getReplicaSet:      replicaSetGetterOf(clientset),
 	}
+}
+
+// Loads the config for contacting API-server based on
+// the connector config mode, see config.ConnectorConfigMode.
+func loadRestConfig() (*rest.Config, error) {
+	loadKubeConfig := func() (*rest.Config, error) {
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = config.SchedulerGeneralConfig.ConnectorConfigPath
+
+		overrides := &clientcmd.ConfigOverrides{
+			CurrentContext: config.SchedulerGeneralConfig.ConnectorContext,
+		}
+
+		log.Info().Msgf(
+			"using kubeconfig %s with context %q",
+			config.SchedulerGeneralConfig.ConnectorConfigPath,
+			config.SchedulerGeneralConfig.ConnectorContext,
+		)
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	}
+
+	switch config.SchedulerGeneralConfig.ConnectorConfigMode {
+	case config.IN_CLUSTER_CONFIG_MODE:
+		return rest.InClusterConfig()
+	case config.KUBECONFIG_CONFIG_MODE:
+		return loadKubeConfig()
+	case config.AUTO_CONFIG_MODE:
+		restConfig, err := rest.InClusterConfig()
+		if err == nil {
+			return restConfig, nil
+		}
 
-	return kc, nil
+		log.Info().Msgf("not running in cluster (%s), falling back to kubeconfig", err)
+		return loadKubeConfig()
+	default:
+		return nil, fmt.Errorf("connector config mode %q is not recognized", config.SchedulerGeneralConfig.ConnectorConfigMode)
+	}
 }
 
 func (kc *KubeConnector) FindNodes() error {
@@ -70,68 +156,74 @@ func (kc *KubeConnector) FindNodes() error {
 		return fmt.Errorf("could not list nodes")
 	}
 
-	for _, node := range nodeList.Items {
-		nodeName := node.GetObjectMeta().GetName()
-
-		modelNode := &model.Node{
-			Id: utils.Hash(nodeName),
-			Resources: mat.NewVecDense(2, []float64{
-				// Removing 1 core and 1 Gig from CPU and memory
-				// of each node so background processes and not visible
-				// pods to scheduler won't cause "Out Of Resource" error
-				// during scheduler execution.
-				// TODO Scheduler should be robust to OOR errors.
-				// FIXME Scheduler can approximate nodes used resources
-				// FIXME in better ways like htop or trial and error.
-				node.Status.Allocatable.Cpu().AsApproximateFloat64() - 1,
-				node.Status.Allocatable.Memory().AsApproximateFloat64()/config.MB - 1000,
-			}),
-		}
+	kc.addNodes(pointersOf(nodeList.Items))
+	log.Info().Msg("nodes found")
+
+	return nil
+}
 
-		// "nodetype" label categorize that the node is either
-		// cloud, edge or non.
-		// This label should be in object/meta.
-		clusterType, ok := node.GetObjectMeta().GetLabels()["nodetype"]
-		if !ok || clusterType == "ignore" {
+func (kc *KubeConnector) addNodes(nodes []*v1.Node) {
+	for _, node := range nodes {
+		modelNode, clusterType, ok := toModelNode(node)
+		if !ok {
 			continue
 		}
 
 		log.Info().Msgf("found node %s", node.GetObjectMeta().GetName())
 		kc.clusterState.AddNode(modelNode, clusterType)
-		kc.nodeIdToName[modelNode.Id] = nodeName
+		kc.indexNode(modelNode, clusterType, node.GetObjectMeta().GetName())
 	}
-
-	log.Info().Msg("nodes found")
-
-	return nil
 }
 
 func (kc *KubeConnector) GetPendingPods() ([]*model.Pod, error) {
-	pendingPods := make([]*model.Pod, 0)
-
-	// getting the pod list from scheduler's namespace.
-	ctx := context.Background()
-	podList, err := kc.clientset.CoreV1().Pods(config.SchedulerGeneralConfig.Namespace).List(ctx, metav1.ListOptions{})
+	pods, err := kc.listPods()
 	if err != nil {
 		log.Err(err).Send()
 
 		return nil, fmt.Errorf("could not get pods list")
 	}
 
-	// checking all pods for pending pods
-	for _, pod := range podList.Items {
-		deploymentName, ok := pod.ObjectMeta.Labels["app"]
-		if !ok {
-			continue
+	return kc.pendingPods(pods), nil
+}
+
+// Lists the pods of the managed namespaces.
+func (kc *KubeConnector) listPods() ([]*v1.Pod, error) {
+	ctx := context.Background()
+
+	var pods []*v1.Pod
+	for _, namespace := range config.SchedulerGeneralConfig.Namespaces {
+		podList, err := kc.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
+		pods = append(pods, pointersOf(podList.Items)...)
+	}
 
-		id := utils.Hash(pod.Name)
-		deploymentId := utils.Hash(deploymentName)
+	return pods, nil
+}
+
+// Returns the deployment of the workload that owns the pod,
+// false if the pod is not owned by a managed workload.
+func (kc *KubeConnector) podDeployment(pod *v1.Pod) (*model.Deployment, bool) {
+	key, ok := kc.podWorkloadKey(pod)
+	if !ok {
+		return nil, false
+	}
+
+	return kc.indexedDeployment(utils.Hash(key))
+}
+
+// Returns the pods of the known deployments which are waiting to be scheduled.
+func (kc *KubeConnector) pendingPods(pods []*v1.Pod) []*model.Pod {
+	pendingPods := make([]*model.Pod, 0)
 
-		deployment, ok := kc.clusterState.Edge.Config.DeploymentIdToDeployment[deploymentId]
+	// checking all pods for pending pods
+	for _, pod := range pods {
+		deployment, ok := kc.podDeployment(pod)
 		if !ok {
 			continue
 		}
+		id := podId(pod)
 
 		if pod.Status.Phase == v1.PodPending && pod.Spec.NodeName == "" {
 			pendingPods = append(pendingPods, &model.Pod{
@@ -139,14 +231,40 @@ func (kc *KubeConnector) GetPendingPods() ([]*model.Pod, error) {
 				Deployment: deployment,
 				Node:       nil,
 				Status:     model.SCHEDULED,
+				Group:      podGroup(pod),
 			})
 		}
 	}
 
-	return pendingPods, nil
+	return pendingPods
 }
 
 func (kc *KubeConnector) SyncPods() ([]*model.Pod, error) {
+	// getting the pod list from the managed namespaces.
+	pods, err := kc.listPods()
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not get pods list")
+	}
+
+	// other pods on the nodes, in all namespaces, take resources too.
+	assignedPods, err := kc.listAssignedPods()
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not get assigned pods list")
+	}
+
+	pendingPods := kc.syncPods(pods)
+	kc.syncReserved(assignedPods)
+
+	return pendingPods, nil
+}
+
+// Forgets all the pods and deploys the given pods where they
+// belong to, returns the pending pods among them.
+func (kc *KubeConnector) syncPods(pods []*v1.Pod) []*model.Pod {
 	// getting all pods that exists in the cluster state
 	allPods := make([]*model.Pod, len(kc.clusterState.PodsMap))
 	for _, pod := range kc.clusterState.PodsMap {
@@ -162,31 +280,14 @@ func (kc *KubeConnector) SyncPods() ([]*model.Pod, error) {
 
 	pendingPods := make([]*model.Pod, 0)
 
-	// getting the pod list from scheduler's namespace.
-	ctx := context.Background()
-	// TODO check running other pods in other namespaces do not allocate memory
-	podList, err := kc.clientset.CoreV1().Pods(config.SchedulerGeneralConfig.Namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Err(err).Send()
-
-		return nil, fmt.Errorf("could not get pods list")
-	}
-
 	// checking each pod and deploying it where it belongs to.
-	for _, pod := range podList.Items {
-		// TODO check other running pods do not allocate memory
-		deploymentName, ok := pod.ObjectMeta.Labels["app"]
-		if !ok {
-			continue
-		}
-
-		id := utils.Hash(pod.Name)
-		deploymentId := utils.Hash(deploymentName)
-
-		deployment, ok := kc.clusterState.Edge.Config.DeploymentIdToDeployment[deploymentId]
+	for _, pod := range pods {
+		deployment, ok := kc.podDeployment(pod)
 		if !ok {
 			continue
 		}
+		id := podId(pod)
+		deploymentId := deployment.Id
 
 		isRunning := (pod.Status.Phase == v1.PodPending && pod.Spec.NodeName != "")
 		isRunning = (isRunning || pod.Status.Phase == v1.PodRunning)
@@ -197,6 +298,7 @@ func (kc *KubeConnector) SyncPods() ([]*model.Pod, error) {
 				Deployment: deployment,
 				Node:       nil,
 				Status:     model.SCHEDULED,
+				Group:      podGroup(pod),
 			})
 
 			continue
@@ -222,6 +324,7 @@ func (kc *KubeConnector) SyncPods() ([]*model.Pod, error) {
 				Deployment: deployment,
 				Node:       nil,
 				Status:     model.RUNNING,
+				Group:      podGroup(pod),
 			}, node)
 			kc.clusterState.NumberOfRunningPods[deploymentId] += 1
 
@@ -238,9 +341,10 @@ func (kc *KubeConnector) SyncPods() ([]*model.Pod, error) {
 			}
 			kc.clusterState.DeployCloud(&model.Pod{
 				Id:         id,
-				Deployment: kc.clusterState.Edge.Config.DeploymentIdToDeployment[deploymentId],
-				Node:       nil,
+				Deployment: deployment,
+				Node:       node,
 				Status:     model.RUNNING,
+				Group:      podGroup(pod),
 			})
 			kc.clusterState.NumberOfRunningPods[deploymentId] += 1
 
@@ -256,54 +360,29 @@ func (kc *KubeConnector) SyncPods() ([]*model.Pod, error) {
 			continue
 		}
 
-		kc.podIdToName[id] = pod.Name
+		kc.indexPod(id, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
 	}
 
-	return pendingPods, nil
+	return pendingPods
 }
 
 func (kc *KubeConnector) FindDeployments() error {
 	log.Info().Msg("finding deployments...")
 
-	ctx := context.Background()
-	// the list of deployments in the namespace
-	deploymentList, err := kc.clientset.AppsV1().Deployments(config.SchedulerGeneralConfig.Namespace).List(ctx, metav1.ListOptions{})
+	workloads, err := kc.listWorkloads()
 	if err != nil {
 		log.Err(err).Send()
 
 		return fmt.Errorf("could not list deployments")
 	}
 
-	for _, deployment := range deploymentList.Items {
-		resourceList := deployment.Spec.Template.Spec.Containers[0].Resources.Limits
-
-		// deployment name should be stored in a label in object/meta with key "app"
-		deploymentName := deployment.GetObjectMeta().GetLabels()["app"]
-		schedulerNameLen := len(config.SchedulerGeneralConfig.Name)
-		if len(deploymentName) >= schedulerNameLen && deploymentName[:schedulerNameLen] == config.SchedulerGeneralConfig.Name {
-			// ignore your pod
-			// WARN scheduler should not be a node which scheduler can scheduler a pod on!
-			continue
-		}
-
-		modelDeployment := &model.Deployment{
-			Id: utils.Hash(deploymentName),
-			ResourcesRequired: mat.NewVecDense(2, []float64{
-				resourceList.Cpu().AsApproximateFloat64(),
-				resourceList.Memory().AsApproximateFloat64() / config.MB,
-			}),
-			EdgeShare: 1, // TODO parse it from deployment's labels
-		}
-		// FIXME manual edge share:
-		// if strings.Contains(strings.ToLower(deploymentName), "d") {
-		// 	modelDeployment.EdgeShare = 0.5
-		// }
-
-		log.Info().Msgf("found deployment %s", deploymentName)
-		kc.clusterState.Edge.Config.AddDeployment(modelDeployment)
-		kc.deploymentIdToName[modelDeployment.Id] = deploymentName
+	budgets, err := kc.listDisruptionBudgets()
+	if err != nil {
+		log.Err(err).Msg("could not list the disruption budgets, ignoring them")
 	}
+	kc.setDisruptionBudgets(budgets)
 
+	kc.addDeployments(workloads)
 	log.Info().Msg("deployments found")
 
 	// After finding deployments, it's time for searching for pods.
@@ -316,24 +395,73 @@ func (kc *KubeConnector) FindDeployments() error {
 	return nil
 }
 
+// Lists the deployments and stateful sets of the managed
+// namespaces that are selected by the workload selector.
+func (kc *KubeConnector) listWorkloads() ([]*workload, error) {
+	ctx := context.Background()
+	options := metav1.ListOptions{
+		LabelSelector: config.SchedulerGeneralConfig.WorkloadSelector,
+	}
+
+	var workloads []*workload
+	for _, namespace := range config.SchedulerGeneralConfig.Namespaces {
+		deploymentList, err := kc.clientset.AppsV1().Deployments(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, deployment := range pointersOf(deploymentList.Items) {
+			w, _ := workloadOf(deployment)
+			workloads = append(workloads, w)
+		}
+
+		statefulSetList, err := kc.clientset.AppsV1().StatefulSets(namespace).List(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, statefulSet := range pointersOf(statefulSetList.Items) {
+			w, _ := workloadOf(statefulSet)
+			workloads = append(workloads, w)
+		}
+	}
+
+	return workloads, nil
+}
+
+func (kc *KubeConnector) addDeployments(workloads []*workload) {
+	for _, w := range workloads {
+		modelDeployment, deploymentName, ok := toModelDeployment(w, model.DEFAULT_EDGE_SHARE)
+		if !ok {
+			continue
+		}
+		modelDeployment.Priority = kc.templatePriority(w.template)
+		modelDeployment.DisruptionBudget = kc.workloadDisruptionBudget(w)
+
+		log.Info().Msgf("found deployment %s", deploymentName)
+		kc.clusterState.Edge.Config.AddDeployment(modelDeployment)
+		kc.indexDeployment(modelDeployment, deploymentName)
+		kc.indexWorkload(w)
+	}
+}
+
 func (kc *KubeConnector) GetClusterState() *model.ClusterState {
 	return kc.clusterState
 }
 
 func (kc *KubeConnector) DeletePod(pod *model.Pod) (bool, error) {
-	podName, ok := kc.podIdToName[pod.Id]
+	podName, ok := kc.podName(pod.Id)
 	if !ok {
 		return false, nil
 	}
-	delete(kc.podIdToName, pod.Id)
 
 	// k8s delete API
-	err := kc.clientset.CoreV1().Pods(config.SchedulerGeneralConfig.Namespace).Delete(
-		context.Background(), podName, *metav1.NewDeleteOptions(0),
+	err := kc.clientset.CoreV1().Pods(podName.Namespace).Delete(
+		context.Background(), podName.Name, *metav1.NewDeleteOptions(0),
 	)
-	if err != nil {
+	// The pod is still known if it is not deleted, so deleting it can be retried.
+	if err != nil && !apierrors.IsNotFound(err) {
 		return true, err
 	}
+	kc.unindexPod(pod.Id)
 
 	return true, nil
 }
@@ -349,16 +477,12 @@ func (kc *KubeConnector) Deploy(pod *model.Pod, node *model.Node) error {
 		node.Id,
 	)
 
-	if node == nil {
-		return fmt.Errorf("the pod is not allocated to any node")
-	}
-
-	nodeName, ok := kc.nodeIdToName[node.Id]
+	nodeName, ok := kc.nodeName(node.Id)
 	if !ok {
 		return fmt.Errorf("the pod's node is not mapped to a known node")
 	}
 
-	podName, ok := kc.podIdToName[pod.Id]
+	podName, ok := kc.podName(pod.Id)
 	if !ok {
 		return fmt.Errorf("the pod is not known")
 	}
@@ -370,8 +494,8 @@ func (kc *KubeConnector) Deploy(pod *model.Pod, node *model.Node) error {
 	}
 
 	objectMeta := metav1.ObjectMeta{
-		Name:      podName,
-		Namespace: config.SchedulerGeneralConfig.Namespace,
+		Name:      podName.Name,
+		Namespace: podName.Namespace,
 	}
 
 	binding := &v1.Binding{
@@ -380,7 +504,7 @@ func (kc *KubeConnector) Deploy(pod *model.Pod, node *model.Node) error {
 	}
 
 	// A k8s binding is created for deploying a pod on a node.
-	err := kc.clientset.CoreV1().Pods(config.SchedulerGeneralConfig.Namespace).Bind(
+	err := kc.clientset.CoreV1().Pods(podName.Namespace).Bind(
 		context.Background(),
 		binding,
 		metav1.CreateOptions{},
@@ -392,109 +516,229 @@ func (kc *KubeConnector) Deploy(pod *model.Pod, node *model.Node) error {
 	return nil
 }
 
+// Stops the last watch, if any, and keeps unwatch to stop the next one.
+func (kc *KubeConnector) replaceWatch(unwatch func()) {
+	kc.unwatchLock.Lock()
+	defer kc.unwatchLock.Unlock()
+
+	if kc.unwatch != nil {
+		kc.unwatch()
+	}
+	kc.unwatch = unwatch
+}
+
+// Stops the watches and the translation of their events.
+func (kc *KubeConnector) Stop() {
+	kc.replaceWatch(nil)
+}
+
 func (kc *KubeConnector) WatchSchedulingEvents() (<-chan *Event, error) {
-	// k8s API for watching events of a namespace:
-	watcher, err := kc.clientset.CoreV1().Pods(config.SchedulerGeneralConfig.Namespace).Watch(
-		context.Background(),
-		metav1.ListOptions{
-			FieldSelector: fmt.Sprintf("spec.schedulerName=%s", config.SchedulerGeneralConfig.Name),
-		},
+	// Nobody reads the last watch's events anymore.
+	kc.replaceWatch(nil)
+
+	eventStream := make(chan *Event)
+	translator := newTranslator(eventStream)
+	// Events may have been missed when a watch is
+	// listed again, so the scheduler should resync.
+	resync := translator.resync
+
+	var watches []*resumableWatch
+	unwatch := func() {
+		for _, rw := range watches {
+			rw.stop()
+		}
+		translator.stop()
+	}
+	start := func(rw *resumableWatch) error {
+		if err := rw.start(); err != nil {
+			unwatch()
+			return err
+		}
+
+		watches = append(watches, rw)
+		return nil
+	}
+
+	workloadOptions := metav1.ListOptions{
+		LabelSelector: config.SchedulerGeneralConfig.WorkloadSelector,
+	}
+	for _, namespace := range config.SchedulerGeneralConfig.Namespaces {
+		pods := kc.clientset.CoreV1().Pods(namespace)
+		podWatch := newResumableWatch(
+			"pods",
+			metav1.ListOptions{
+				FieldSelector: fmt.Sprintf("spec.schedulerName=%s", config.SchedulerGeneralConfig.WatchedSchedulerName),
+			},
+			listOf(pods.List),
+			watchOf(pods.Watch),
+			translator.handler(kc.translatePodEvent),
+			resync,
+		)
+		if err := start(podWatch); err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching cluster events")
+		}
+
+		deployments := kc.clientset.AppsV1().Deployments(namespace)
+		deploymentWatch := newResumableWatch(
+			"deployments",
+			workloadOptions,
+			listOf(deployments.List),
+			watchOf(deployments.Watch),
+			translator.handler(kc.translateDeploymentEvent),
+			resync,
+		)
+		if err := start(deploymentWatch); err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching deployment events")
+		}
+
+		statefulSets := kc.clientset.AppsV1().StatefulSets(namespace)
+		statefulSetWatch := newResumableWatch(
+			"stateful sets",
+			workloadOptions,
+			listOf(statefulSets.List),
+			watchOf(statefulSets.Watch),
+			translator.handler(kc.translateDeploymentEvent),
+			resync,
+		)
+		if err := start(statefulSetWatch); err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching stateful set events")
+		}
+
+		budgets := kc.clientset.PolicyV1().PodDisruptionBudgets(namespace)
+		budgetWatch := newResumableWatch(
+			"disruption budgets",
+			metav1.ListOptions{},
+			listOf(budgets.List),
+			watchOf(budgets.Watch),
+			translator.handler(kc.translateDisruptionBudgetEvent),
+			resync,
+		)
+		if err := start(budgetWatch); err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching disruption budget events")
+		}
+	}
+
+	allPods := kc.clientset.CoreV1().Pods(metav1.NamespaceAll)
+	reservingPodWatch := newResumableWatch(
+		"assigned pods",
+		metav1.ListOptions{FieldSelector: ASSIGNED_PODS_SELECTOR},
+		listOf(allPods.List),
+		watchOf(allPods.Watch),
+		translator.handler(kc.translateReservingPodEvent),
+		resync,
 	)
-	if err != nil {
+	if err := start(reservingPodWatch); err != nil {
 		log.Err(err).Send()
 
-		return nil, fmt.Errorf("could not start watching cluster events")
+		return nil, fmt.Errorf("could not start watching assigned pod events")
 	}
 
-	eventStream := make(chan *Event)
-	// The goroutine duty is to translate all k8s events
-	// to an internal event and send it through eventStream.
-	go func() {
-		for event := range watcher.ResultChan() {
-			v1Pod, ok := event.Object.(*v1.Pod)
-			if !ok {
-				// the event is not about a pod
-				// TODO maybe check for
-				continue
-			}
+	nodes := kc.clientset.CoreV1().Nodes()
+	nodeWatch := newResumableWatch(
+		"nodes",
+		metav1.ListOptions{},
+		listOf(nodes.List),
+		watchOf(nodes.Watch),
+		translator.handler(kc.translateNodeEvent),
+		resync,
+	)
+	if err := start(nodeWatch); err != nil {
+		log.Err(err).Send()
 
-			deploymentName, ok := v1Pod.ObjectMeta.Labels["app"]
-			if !ok {
-				log.Warn().Msgf("event's pod has no deployment.")
+		return nil, fmt.Errorf("could not start watching node events")
+	}
+	kc.replaceWatch(unwatch)
 
-				continue
-			}
+	return eventStream, nil
+}
 
-			deploymentId := utils.Hash(deploymentName)
-			deployment, ok := kc.clusterState.Edge.Config.DeploymentIdToDeployment[deploymentId]
-			if !ok {
-				log.Info().Msgf("some pod event has happened for deployment %s not related to scheduler.", deploymentName)
+// Translates a k8s pod event to an internal event.
+func (kc *KubeConnector) translatePodEvent(event watch.Event, eventStream chan<- *Event) {
+	v1Pod, ok := event.Object.(*v1.Pod)
+	if !ok {
+		// the event is not about a pod
+		// TODO maybe check for
+		return
+	}
 
-				continue
-			}
+	deployment, ok := kc.podDeployment(v1Pod)
+	if !ok {
+		log.Info().Msgf("some pod event has happened for pod %s/%s not owned by a managed workload.", v1Pod.Namespace, v1Pod.Name)
 
-			id := utils.Hash(v1Pod.Name)
-			pod, isOldPod := kc.clusterState.PodsMap[id]
-			if !isOldPod {
-				log.Info().Msgf("got an event about not registered pod, creating it.")
-				kc.podIdToName[id] = v1Pod.Name
-				pod = &model.Pod{
-					Id:         id,
-					Deployment: deployment,
-				}
-				kc.clusterState.PodsMap[pod.Id] = pod
-			}
+		return
+	}
 
-			nodeName := v1Pod.Spec.NodeName
-			var node *model.Node
-			if nodeName == "" {
-				node = nil
-			} else {
-				nodeId := utils.Hash(nodeName)
-				node, ok = kc.clusterState.GetNodeIdToNode()[nodeId]
-				if !ok {
-					log.Warn().Msgf("pod's node (%s) is not registered, ignoring the event.", nodeName)
-
-					return
-				}
-			}
+	// The scheduler uses its own pod if it knows the pod already.
+	id := podId(v1Pod)
+	pod := &model.Pod{
+		Id:         id,
+		Deployment: deployment,
+		Group:      podGroup(v1Pod),
+	}
+	if event.Type != watch.Deleted {
+		kc.indexPod(id, types.NamespacedName{Namespace: v1Pod.Namespace, Name: v1Pod.Name})
+	}
 
-			var newPodStatus model.PodStatus
-			log.Info().Msgf("pod's kubernetes status: %s", v1Pod.Status.Phase)
-
-			// translating the pod status:
-			switch v1Pod.Status.Phase {
-			case v1.PodPending, v1.PodUnknown:
-				newPodStatus = model.SCHEDULED
-			case v1.PodRunning:
-				newPodStatus = model.RUNNING
-			case v1.PodSucceeded, v1.PodFailed:
-				newPodStatus = model.FINISHED
-			}
+	nodeName := v1Pod.Spec.NodeName
+	var node *model.Node
+	if nodeName == "" {
+		node = nil
+	} else {
+		indexed, ok := kc.indexedNode(utils.Hash(nodeName))
+		node = indexed.node
+		if !ok {
+			log.Warn().Msgf("pod's node (%s) is not registered, ignoring the event.", nodeName)
 
-			// inferring event type:
-			var eventType EventType
-			switch event.Type {
-			case watch.Added:
-				eventType = POD_CREATED
-			case watch.Modified:
-				eventType = POD_CHANGED
-			case watch.Deleted:
-				eventType = POD_DELETED
-			}
+			return
+		}
+	}
 
-			if eventType == POD_CREATED && isOldPod {
-				continue
-			}
+	var newPodStatus model.PodStatus
+	log.Info().Msgf("pod's kubernetes status: %s", v1Pod.Status.Phase)
+
+	// translating the pod status:
+	switch v1Pod.Status.Phase {
+	case v1.PodPending, v1.PodUnknown:
+		newPodStatus = model.SCHEDULED
+	case v1.PodRunning:
+		newPodStatus = model.RUNNING
+	case v1.PodSucceeded, v1.PodFailed:
+		newPodStatus = model.FINISHED
+	}
 
-			eventStream <- &Event{
-				EventType: eventType,
-				Pod:       pod,
-				Node:      node,
-				Status:    newPodStatus,
-			}
-		}
-	}()
+	// inferring event type:
+	var eventType EventType
+	switch event.Type {
+	case watch.Added:
+		eventType = POD_CREATED
+	case watch.Modified:
+		eventType = POD_CHANGED
+	case watch.Deleted:
+		eventType = POD_DELETED
+	}
 
-	return eventStream, nil
+	eventStream <- &Event{
+		EventType: eventType,
+		Pod:       pod,
+		Node:      node,
+		Status:    newPodStatus,
+	}
+}
+
+// Returns pointers to the items of a k8s list.
+func pointersOf[T any](items []T) []*T {
+	pointers := make([]*T, len(items))
+	for ind := range items {
+		pointers[ind] = &items[ind]
+	}
+
+	return pointers
 }
@@ -0,0 +1,321 @@
+package connector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	policyinformers "k8s.io/client-go/informers/policy/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The time waited for the informers' caches to be filled.
+const CACHE_SYNC_TIMEOUT = time.Minute
+
+// A kubernetes connector that reads pods, nodes and deployments
+// from local caches kept up to date by informers, and
+// gets its events from the same informers, instead of asking
+// the API-server on every read.
+// Binding and deleting pods are the same as KubeConnector.
+type CachedKubeConnector struct {
+	*KubeConnector
+
+	// informers of each managed namespace
+	podInformers      []cache.SharedIndexInformer
+	workloadInformers []cache.SharedIndexInformer
+	// replica sets find the deployments owning the pods
+	replicaSetInformers []cache.SharedIndexInformer
+	budgetInformers     []cache.SharedIndexInformer
+	nodeInformer        cache.SharedIndexInformer
+	// informer of the pods on nodes in all namespaces
+	assignedPodInformer cache.SharedIndexInformer
+
+	// closed to stop the informers
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  sync.WaitGroup
+}
+
+func NewCachedKubeConnector(clusterState *model.ClusterState) (*CachedKubeConnector, error) {
+	restConfig, err := loadRestConfig()
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("can't connect to kubernetes cluster")
+	}
+
+	clientSet, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not init clients")
+	}
+
+	return newCachedKubeConnector(clusterState, clientSet)
+}
+
+func newCachedKubeConnector(clusterState *model.ClusterState, clientset kubernetes.Interface) (*CachedKubeConnector, error) {
+	c := &CachedKubeConnector{
+		KubeConnector: newKubeConnector(clusterState, clientset),
+		nodeInformer:  coreinformers.NewNodeInformer(clientset, 0, cache.Indexers{}),
+		assignedPodInformer: coreinformers.NewFilteredPodInformer(
+			clientset, metav1.NamespaceAll, 0, cache.Indexers{},
+			func(options *metav1.ListOptions) { options.FieldSelector = ASSIGNED_PODS_SELECTOR },
+		),
+		stop: make(chan struct{}),
+	}
+
+	selectWorkloads := func(options *metav1.ListOptions) {
+		options.LabelSelector = config.SchedulerGeneralConfig.WorkloadSelector
+	}
+	for _, namespace := range config.SchedulerGeneralConfig.Namespaces {
+		c.podInformers = append(c.podInformers, coreinformers.NewPodInformer(clientset, namespace, 0, cache.Indexers{}))
+		c.workloadInformers = append(
+			c.workloadInformers,
+			appsinformers.NewFilteredDeploymentInformer(clientset, namespace, 0, cache.Indexers{}, selectWorkloads),
+			appsinformers.NewFilteredStatefulSetInformer(clientset, namespace, 0, cache.Indexers{}, selectWorkloads),
+		)
+		c.budgetInformers = append(c.budgetInformers, policyinformers.NewPodDisruptionBudgetInformer(clientset, namespace, 0, cache.Indexers{}))
+		c.replicaSetInformers = append(c.replicaSetInformers, appsinformers.NewReplicaSetInformer(clientset, namespace, 0, cache.Indexers{}))
+	}
+	c.getReplicaSet = c.cachedReplicaSet
+
+	allInformers := append([]cache.SharedIndexInformer{c.nodeInformer, c.assignedPodInformer}, c.podInformers...)
+	allInformers = append(allInformers, c.workloadInformers...)
+	allInformers = append(allInformers, c.budgetInformers...)
+	allInformers = append(allInformers, c.replicaSetInformers...)
+
+	// The informers live until the connector is stopped.
+	var synced []cache.InformerSynced
+	for _, informer := range allInformers {
+		c.running.Add(1)
+		go func(informer cache.SharedIndexInformer) {
+			defer c.running.Done()
+			informer.Run(c.stop)
+		}(informer)
+		synced = append(synced, informer.HasSynced)
+	}
+
+	timeout := make(chan struct{})
+	timer := time.AfterFunc(CACHE_SYNC_TIMEOUT, func() { close(timeout) })
+	defer timer.Stop()
+
+	log.Info().Msg("filling the caches...")
+	if !cache.WaitForCacheSync(timeout, synced...) {
+		c.Stop()
+		return nil, fmt.Errorf("could not fill the caches")
+	}
+	log.Info().Msg("caches are filled")
+
+	return c, nil
+}
+
+// Stops the informers and the translation of their events,
+// then waits for them to return.
+func (c *CachedKubeConnector) Stop() {
+	c.stopOnce.Do(func() {
+		c.replaceWatch(nil)
+		close(c.stop)
+		c.running.Wait()
+	})
+}
+
+// Returns the replica set from the informers' caches.
+func (c *CachedKubeConnector) cachedReplicaSet(namespace string, name string) (*appsv1.ReplicaSet, error) {
+	for _, informer := range c.replicaSetInformers {
+		object, exists, err := informer.GetIndexer().GetByKey(namespace + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return object.(*appsv1.ReplicaSet), nil
+		}
+	}
+
+	return nil, apierrors.NewNotFound(appsv1.Resource("replicasets"), name)
+}
+
+// Returns the cached objects of the informers.
+func cachedObjects[T any](informers ...cache.SharedIndexInformer) []T {
+	var objects []T
+	for _, informer := range informers {
+		for _, object := range informer.GetStore().List() {
+			if object, ok := object.(T); ok {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	return objects
+}
+
+func (c *CachedKubeConnector) FindNodes() error {
+	log.Info().Msg("finding nodes...")
+
+	c.addNodes(cachedObjects[*v1.Node](c.nodeInformer))
+	log.Info().Msg("nodes found")
+
+	return nil
+}
+
+func (c *CachedKubeConnector) FindDeployments() error {
+	log.Info().Msg("finding deployments...")
+
+	var workloads []*workload
+	for _, object := range cachedObjects[runtime.Object](c.workloadInformers...) {
+		if w, ok := workloadOf(object); ok {
+			workloads = append(workloads, w)
+		}
+	}
+
+	c.setDisruptionBudgets(cachedObjects[*policyv1.PodDisruptionBudget](c.budgetInformers...))
+	c.addDeployments(workloads)
+	log.Info().Msg("deployments found")
+
+	// After finding deployments, it's time for searching for pods.
+	if _, err := c.SyncPods(); err != nil {
+		log.Err(err).Send()
+
+		return fmt.Errorf("couldn't sync pods")
+	}
+
+	return nil
+}
+
+func (c *CachedKubeConnector) GetPendingPods() ([]*model.Pod, error) {
+	return c.pendingPods(cachedObjects[*v1.Pod](c.podInformers...)), nil
+}
+
+func (c *CachedKubeConnector) SyncPods() ([]*model.Pod, error) {
+	pendingPods := c.syncPods(cachedObjects[*v1.Pod](c.podInformers...))
+	c.syncReserved(cachedObjects[*v1.Pod](c.assignedPodInformer))
+
+	return pendingPods, nil
+}
+
+func (c *CachedKubeConnector) WatchSchedulingEvents() (<-chan *Event, error) {
+	// Nobody reads the last watch's events anymore.
+	c.replaceWatch(nil)
+
+	eventStream := make(chan *Event)
+	// Each informer calls its handlers on its own goroutine.
+	translator := newTranslator(eventStream)
+
+	var registrations []func()
+	unwatch := func() {
+		for _, unregister := range registrations {
+			unregister()
+		}
+		translator.stop()
+	}
+	// The informers are synced, so each of them replays an add
+	// notification of every cached object to the new handler. The
+	// replayed objects are known already, the connector's index
+	// drops the unchanged nodes and workloads, and the scheduler's
+	// handleEvent ignores the creation of the pods it knows.
+	register := func(informer cache.SharedIndexInformer, handle func(watch.Event)) error {
+		registration, err := informer.AddEventHandler(eventHandler(handle))
+		if err != nil {
+			unwatch()
+			return err
+		}
+
+		registrations = append(registrations, func() {
+			if err := informer.RemoveEventHandler(registration); err != nil {
+				log.Err(err).Send()
+			}
+		})
+		return nil
+	}
+	for _, podInformer := range c.podInformers {
+		err := register(podInformer, translator.handler(func(event watch.Event, eventStream chan<- *Event) {
+			// The cache has all the pods of the namespace, but
+			// only the watched scheduler's pods make events.
+			if pod, ok := event.Object.(*v1.Pod); ok && pod.Spec.SchedulerName != config.SchedulerGeneralConfig.WatchedSchedulerName {
+				return
+			}
+			c.translatePodEvent(event, eventStream)
+		}))
+		if err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching cluster events")
+		}
+	}
+
+	for _, workloadInformer := range c.workloadInformers {
+		if err := register(workloadInformer, translator.handler(c.translateDeploymentEvent)); err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching deployment events")
+		}
+	}
+
+	for _, budgetInformer := range c.budgetInformers {
+		if err := register(budgetInformer, translator.handler(c.translateDisruptionBudgetEvent)); err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not start watching disruption budget events")
+		}
+	}
+
+	if err := register(c.assignedPodInformer, translator.handler(c.translateReservingPodEvent)); err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not start watching assigned pod events")
+	}
+
+	if err := register(c.nodeInformer, translator.handler(c.translateNodeEvent)); err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not start watching node events")
+	}
+	c.replaceWatch(unwatch)
+
+	return eventStream, nil
+}
+
+// Returns an informer event handler that passes
+// the informer's notifications as k8s watch events.
+func eventHandler(handle func(watch.Event)) cache.ResourceEventHandler {
+	notify := func(eventType watch.EventType, obj interface{}) {
+		if unknown, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			// The deletion is noticed by listing again.
+			obj = unknown.Obj
+		}
+
+		object, ok := obj.(runtime.Object)
+		if !ok {
+			return
+		}
+
+		handle(watch.Event{
+			Type:   eventType,
+			Object: object,
+		})
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			notify(watch.Added, obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			notify(watch.Modified, obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			notify(watch.Deleted, obj)
+		},
+	}
+}
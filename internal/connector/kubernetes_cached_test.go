@@ -0,0 +1,311 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func setUpConfig(t *testing.T) {
+	yamlFile, err := os.ReadFile("../../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(yamlFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newKubeNode(name string, nodeType string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"nodetype": nodeType},
+		},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("4"),
+				v1.ResourceMemory: resource.MustParse("4G"),
+			},
+		},
+	}
+}
+
+// Returns a pod in the namespace owned by the workload,
+// it is pending if it is not on any node.
+func newKubePod(namespace string, name string, ownerKind string, ownerName string, nodeName string) *v1.Pod {
+	phase := v1.PodRunning
+	if nodeName == "" {
+		phase = v1.PodPending
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{},
+		},
+		Spec: v1.PodSpec{
+			SchedulerName: config.SchedulerGeneralConfig.WatchedSchedulerName,
+			NodeName:      nodeName,
+		},
+		Status: v1.PodStatus{
+			Phase: phase,
+		},
+	}
+
+	if ownerKind == DEPLOYMENT_KIND {
+		// Deployments own their pods through a replica set.
+		ownerKind = "ReplicaSet"
+		ownerName = ownerName + "-5d9c"
+		pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "5d9c"
+	}
+	isController := true
+	pod.OwnerReferences = []metav1.OwnerReference{{
+		Kind:       ownerKind,
+		Name:       ownerName,
+		Controller: &isController,
+	}}
+
+	return pod
+}
+
+func newKubePodTemplate() v1.PodTemplateSpec {
+	return v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{
+					Limits: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("1"),
+						v1.ResourceMemory: resource.MustParse("1G"),
+					},
+				},
+			}},
+		},
+	}
+}
+
+func TestCachedKubeConnector(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.Namespaces = []string{"team-a", "team-b"}
+	config.SchedulerGeneralConfig.WorkloadSelector = "ecmus/managed=true"
+
+	managed := map[string]string{"ecmus/managed": "true"}
+	newDeployment := func(namespace string, name string, labels map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Template: newKubePodTemplate()},
+		}
+	}
+	clientset := fake.NewSimpleClientset([]runtime.Object{
+		newKubeNode("edge", "edge"),
+		newKubeNode("cloud", "cloud"),
+		newDeployment("team-a", "a", managed),
+		// not selected:
+		newDeployment("team-a", "b", nil),
+		// not in a managed namespace:
+		newDeployment("other", "a", managed),
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "team-b", Labels: managed},
+			Spec:       appsv1.StatefulSetSpec{Template: newKubePodTemplate()},
+		},
+		newKubePod("team-a", "p", DEPLOYMENT_KIND, "a", "edge"),
+		newKubePod("team-a", "q", DEPLOYMENT_KIND, "b", ""),
+		newKubePod("team-b", "p", STATEFUL_SET_KIND, "a", ""),
+		newKubePod("other", "p", DEPLOYMENT_KIND, "a", ""),
+	}...)
+
+	clusterState := model.NewClusterState()
+	c, err := newCachedKubeConnector(clusterState, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	if err := c.FindNodes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FindDeployments(); err != nil {
+		t.Fatal(err)
+	}
+
+	deployments := clusterState.Edge.Config.DeploymentIdToDeployment
+	if len(deployments) != 2 {
+		t.Errorf("got %d deployments, wanted 2", len(deployments))
+	}
+	for _, key := range []string{"team-a/Deployment/a", "team-b/StatefulSet/a"} {
+		if _, ok := deployments[utils.Hash(key)]; !ok {
+			t.Errorf("workload %s is not found", key)
+		}
+	}
+
+	pod, ok := clusterState.PodsMap[utils.Hash("team-a/p")]
+	if !ok || pod.Node == nil || pod.Node.Id != utils.Hash("edge") {
+		t.Errorf("the running pod is not found on its node")
+	} else if pod.Deployment.Id != utils.Hash("team-a/Deployment/a") {
+		t.Errorf("the running pod is not of its deployment")
+	}
+
+	// Reading the cluster again should not ask the API-server.
+	actions := len(clientset.Actions())
+	for i := 0; i < 3; i++ {
+		pendingPods, err := c.GetPendingPods()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pendingPods) != 1 || pendingPods[0].Id != utils.Hash("team-b/p") {
+			t.Errorf("got %d pending pods, wanted only team-b/p", len(pendingPods))
+		}
+		if _, err := c.SyncPods(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clientset.Actions()) != actions {
+		t.Errorf("the API-server is asked %d times while reading the cluster", len(clientset.Actions())-actions)
+	}
+
+	eventStream, err := c.WatchSchedulingEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPod := newKubePod("team-b", "b", STATEFUL_SET_KIND, "a", "")
+	otherPod.Spec.SchedulerName = "other-scheduler"
+	for _, newPod := range []*v1.Pod{otherPod, newKubePod("team-b", "c", STATEFUL_SET_KIND, "a", "")} {
+		_, err := clientset.CoreV1().Pods(newPod.Namespace).Create(context.Background(), newPod, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-eventStream:
+			if event.Pod == nil {
+				continue
+			}
+			if event.Pod.Id == utils.Hash("team-b/b") {
+				t.Fatal("got an event of a pod which is not watched")
+			}
+			if event.Pod.Id == utils.Hash("team-b/c") && event.EventType == POD_CREATED {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the new pod's event is not received")
+		}
+	}
+}
+
+func TestReservedResources(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.Resources = append(
+		config.SchedulerGeneralConfig.Resources,
+		config.ResourceConfig{Name: string(v1.ResourcePods), Scale: 1, IgnoreInFragmentation: true},
+	)
+	config.SchedulerGeneralConfig.ResourceCount = len(config.SchedulerGeneralConfig.Resources)
+	podsInd := config.ResourceIndex(string(v1.ResourcePods))
+
+	newDaemonPod := func(name string, nodeName string, cpu string) *v1.Pod {
+		pod := newKubePod("kube-system", name, "DaemonSet", "d", nodeName)
+		pod.Spec.Containers = []v1.Container{{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)},
+			},
+		}}
+		return pod
+	}
+	clientset := fake.NewSimpleClientset([]runtime.Object{
+		newKubeNode("edge", "edge"),
+		newKubeNode("cloud", "cloud"),
+		newDaemonPod("on-edge", "edge", "500m"),
+		newDaemonPod("on-cloud", "cloud", "1"),
+	}...)
+
+	clusterState := model.NewClusterState()
+	c, err := newCachedKubeConnector(clusterState, clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	if err := c.FindNodes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FindDeployments(); err != nil {
+		t.Fatal(err)
+	}
+
+	edgeId := utils.Hash("edge")
+	if cpu := clusterState.NodeResourcesReserved[edgeId].AtVec(0); cpu != 0.5 {
+		t.Errorf("got %f cpu reserved on the edge node, wanted 0.5", cpu)
+	}
+	if cpu := clusterState.NodeResourcesUsed[edgeId].AtVec(0); cpu != 0.5 {
+		t.Errorf("got %f cpu used on the edge node, wanted 0.5", cpu)
+	}
+	if pods := clusterState.NodeResourcesReserved[edgeId].AtVec(podsInd); pods != 1 {
+		t.Errorf("got %f pod slots reserved on the edge node, wanted 1", pods)
+	}
+
+	eventStream, err := c.WatchSchedulingEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newPod := newDaemonPod("another", "edge", "1")
+	if _, err := clientset.CoreV1().Pods(newPod.Namespace).Create(context.Background(), newPod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+received:
+	for {
+		select {
+		case event := <-eventStream:
+			if event.EventType != NODE_RESERVED_CHANGED {
+				continue
+			}
+			if event.Node.Id != edgeId {
+				t.Fatalf("got a reserved event of node %d, wanted the edge node", event.Node.Id)
+			}
+			if cpu := event.Reserved.AtVec(0); cpu != 1.5 {
+				t.Fatalf("got %f cpu reserved after the new pod, wanted 1.5", cpu)
+			}
+			break received
+		case <-timeout:
+			t.Fatal("the reserved event is not received")
+		}
+	}
+
+	// The scheduler syncs the reserving pods while the watch changes them.
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		for i := 0; i < 10; i++ {
+			if _, err := c.SyncPods(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		for range eventStream {
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		newPod := newDaemonPod(fmt.Sprintf("more-%d", i), "edge", "100m")
+		if _, err := clientset.CoreV1().Pods(newPod.Namespace).Create(context.Background(), newPod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-synced
+}
@@ -0,0 +1,192 @@
+package connector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotation (or label) of workloads having the maximum
+// number of their pods on an edge node.
+const MAX_PER_NODE_KEY = "ecmus/max-per-node"
+
+// Reads the placement constraints of the pod spec, which are the
+// node selector, the required node affinity and the tolerations.
+// Preferred affinities are only preferences and are ignored.
+func podSpecConstraints(spec *v1.PodSpec) model.Constraints {
+	var constraints model.Constraints
+
+	if len(spec.NodeSelector) > 0 {
+		constraints.NodeSelector = make(map[string]string)
+		for key, value := range spec.NodeSelector {
+			constraints.NodeSelector[key] = value
+		}
+	}
+
+	if spec.Affinity != nil && spec.Affinity.NodeAffinity != nil &&
+		spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			modelTerm := make(model.NodeSelectorTerm, 0, len(term.MatchExpressions)+len(term.MatchFields))
+			for _, requirement := range term.MatchExpressions {
+				modelTerm = append(modelTerm, model.LabelRequirement{
+					Key:      requirement.Key,
+					Operator: string(requirement.Operator),
+					Values:   requirement.Values,
+				})
+			}
+			for _, requirement := range term.MatchFields {
+				if modelRequirement, ok := toNodeIdRequirement(requirement); ok {
+					modelTerm = append(modelTerm, modelRequirement)
+				}
+			}
+
+			if len(modelTerm) == 0 && len(term.MatchFields) > 0 {
+				// The term only had ignored fields, so any node matches it.
+				constraints.RequiredAffinity = nil
+				break
+			}
+			constraints.RequiredAffinity = append(constraints.RequiredAffinity, modelTerm)
+		}
+	}
+
+	for _, toleration := range spec.Tolerations {
+		constraints.Tolerations = append(constraints.Tolerations, model.Toleration{
+			Key:      toleration.Key,
+			Operator: string(toleration.Operator),
+			Value:    toleration.Value,
+			Effect:   string(toleration.Effect),
+		})
+	}
+
+	return constraints
+}
+
+// Translates a node selector requirement of the node's name to
+// its id, the only field of nodes that kube-scheduler supports.
+func toNodeIdRequirement(requirement v1.NodeSelectorRequirement) (model.LabelRequirement, bool) {
+	if requirement.Key != "metadata.name" ||
+		(requirement.Operator != v1.NodeSelectorOpIn && requirement.Operator != v1.NodeSelectorOpNotIn) {
+		log.Warn().Msgf("node selector field %s with operator %s is not supported, ignoring it", requirement.Key, requirement.Operator)
+
+		return model.LabelRequirement{}, false
+	}
+
+	nodeIds := make([]string, 0, len(requirement.Values))
+	for _, name := range requirement.Values {
+		nodeIds = append(nodeIds, strconv.Itoa(utils.Hash(name)))
+	}
+
+	return model.LabelRequirement{
+		Key:      model.NODE_ID_KEY,
+		Operator: string(requirement.Operator),
+		Values:   nodeIds,
+	}, true
+}
+
+// Reads the node's taints that keep pods off the node.
+func nodeTaints(node *v1.Node) []model.Taint {
+	var taints []model.Taint
+	for _, taint := range node.Spec.Taints {
+		taints = append(taints, model.Taint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: string(taint.Effect),
+		})
+	}
+
+	return taints
+}
+
+// Reads the spread rules of the pod template, the maximum zone skew
+// from the topology spread constraints over zones and the anti-affinities
+// from the required pod anti-affinity terms over nodes.
+// Rules over other topologies are not supported and are ignored.
+// The maximum pods per node is read from the workload, see parseMaxPerNode.
+func templateSpread(template *v1.PodTemplateSpec) model.Spread {
+	var spread model.Spread
+
+	spec := &template.Spec
+	for _, constraint := range spec.TopologySpreadConstraints {
+		if constraint.TopologyKey != v1.LabelTopologyZone {
+			log.Warn().Msgf("topology spread over %s is not supported, ignoring it", constraint.TopologyKey)
+			continue
+		}
+
+		maxSkew := int(constraint.MaxSkew)
+		if spread.MaxZoneSkew == 0 || maxSkew < spread.MaxZoneSkew {
+			spread.MaxZoneSkew = maxSkew
+		}
+	}
+
+	if spec.Affinity != nil && spec.Affinity.PodAntiAffinity != nil {
+		for _, term := range spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if term.TopologyKey != v1.LabelHostname {
+				log.Warn().Msgf("pod anti-affinity over %s is not supported, ignoring it", term.TopologyKey)
+				continue
+			}
+
+			spread.AntiAffinity = append(spread.AntiAffinity, toLabelSelector(term.LabelSelector))
+		}
+	}
+
+	return spread
+}
+
+// Translates the k8s label selector, the match labels are sorted
+// so the same selectors are translated the same.
+func toLabelSelector(selector *metav1.LabelSelector) model.LabelSelector {
+	if selector == nil {
+		// A nil selector selects no pods in k8s,
+		// like an In requirement without any value.
+		return model.LabelSelector{{Operator: model.IN_OPERATOR}}
+	}
+
+	keys := make([]string, 0, len(selector.MatchLabels))
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ret := make(model.LabelSelector, 0, len(selector.MatchLabels)+len(selector.MatchExpressions))
+	for _, key := range keys {
+		ret = append(ret, model.LabelRequirement{
+			Key:      key,
+			Operator: model.IN_OPERATOR,
+			Values:   []string{selector.MatchLabels[key]},
+		})
+	}
+	for _, requirement := range selector.MatchExpressions {
+		ret = append(ret, model.LabelRequirement{
+			Key:      requirement.Key,
+			Operator: string(requirement.Operator),
+			Values:   requirement.Values,
+		})
+	}
+
+	return ret
+}
+
+// Reads the maximum number of pods per node from the "ecmus/max-per-node"
+// annotation, or label if there is no such annotation, 0 if there is none.
+func parseMaxPerNode(objectMeta metav1.Object) (int, error) {
+	value, ok := objectMeta.GetAnnotations()[MAX_PER_NODE_KEY]
+	if !ok {
+		value, ok = objectMeta.GetLabels()[MAX_PER_NODE_KEY]
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	maxPerNode, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || maxPerNode < 1 {
+		return 0, fmt.Errorf("maximum pods per node %q is not a positive number", value)
+	}
+
+	return maxPerNode, nil
+}
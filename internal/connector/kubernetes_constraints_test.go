@@ -0,0 +1,204 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodSpecConstraints(t *testing.T) {
+	spec := &v1.PodSpec{
+		NodeSelector: map[string]string{"disk": "ssd"},
+		Affinity: &v1.Affinity{
+			NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: "gpu", Operator: v1.NodeSelectorOpExists},
+						}},
+						{MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: "cores", Operator: v1.NodeSelectorOpGt, Values: []string{"4"}},
+						}},
+					},
+				},
+			},
+		},
+		Tolerations: []v1.Toleration{
+			{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "ml", Effect: v1.TaintEffectNoSchedule},
+		},
+	}
+	deployment := &model.Deployment{Constraints: podSpecConstraints(spec)}
+
+	newNode := func(labels map[string]string, taints ...v1.Taint) *model.Node {
+		return &model.Node{
+			Labels: labels,
+			Taints: nodeTaints(&v1.Node{Spec: v1.NodeSpec{Taints: taints}}),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		node    *model.Node
+		allowed bool
+	}{
+		{
+			name:    "matches the selector and the first term",
+			node:    newNode(map[string]string{"disk": "ssd", "gpu": ""}),
+			allowed: true,
+		},
+		{
+			name:    "matches the selector and the second term",
+			node:    newNode(map[string]string{"disk": "ssd", "cores": "8"}),
+			allowed: true,
+		},
+		{
+			name:    "matches no term",
+			node:    newNode(map[string]string{"disk": "ssd", "cores": "2"}),
+			allowed: false,
+		},
+		{
+			name:    "does not match the selector",
+			node:    newNode(map[string]string{"disk": "hdd", "gpu": ""}),
+			allowed: false,
+		},
+		{
+			name: "tolerated taint",
+			node: newNode(map[string]string{"disk": "ssd", "gpu": ""},
+				v1.Taint{Key: "dedicated", Value: "ml", Effect: v1.TaintEffectNoSchedule}),
+			allowed: true,
+		},
+		{
+			name: "not tolerated taint",
+			node: newNode(map[string]string{"disk": "ssd", "gpu": ""},
+				v1.Taint{Key: "dedicated", Value: "web", Effect: v1.TaintEffectNoSchedule}),
+			allowed: false,
+		},
+		{
+			name: "prefer no schedule taint is ignored",
+			node: newNode(map[string]string{"disk": "ssd", "gpu": ""},
+				v1.Taint{Key: "spot", Effect: v1.TaintEffectPreferNoSchedule}),
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if allowed := deployment.CanRunOn(test.node); allowed != test.allowed {
+				t.Errorf("got allowed %v, wanted %v", allowed, test.allowed)
+			}
+		})
+	}
+}
+
+func TestNodeNameConstraints(t *testing.T) {
+	newSpec := func(terms ...v1.NodeSelectorTerm) *v1.PodSpec {
+		return &v1.PodSpec{
+			Affinity: &v1.Affinity{
+				NodeAffinity: &v1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+						NodeSelectorTerms: terms,
+					},
+				},
+			},
+		}
+	}
+	nameIn := v1.NodeSelectorTerm{MatchFields: []v1.NodeSelectorRequirement{
+		{Key: "metadata.name", Operator: v1.NodeSelectorOpIn, Values: []string{"edge-1"}},
+	}}
+	nameNotIn := v1.NodeSelectorTerm{MatchFields: []v1.NodeSelectorRequirement{
+		{Key: "metadata.name", Operator: v1.NodeSelectorOpNotIn, Values: []string{"edge-1"}},
+	}}
+	unsupported := v1.NodeSelectorTerm{MatchFields: []v1.NodeSelectorRequirement{
+		{Key: "metadata.uid", Operator: v1.NodeSelectorOpIn, Values: []string{"x"}},
+	}}
+	gpu := v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{
+		{Key: "gpu", Operator: v1.NodeSelectorOpExists},
+	}}
+
+	edge1 := &model.Node{Id: utils.Hash("edge-1")}
+	edge2 := &model.Node{Id: utils.Hash("edge-2")}
+
+	tests := []struct {
+		name    string
+		spec    *v1.PodSpec
+		allowed []bool
+	}{
+		{"name in", newSpec(nameIn), []bool{true, false}},
+		{"name not in", newSpec(nameNotIn), []bool{false, true}},
+		{"unsupported field is ignored", newSpec(unsupported), []bool{true, true}},
+		{"unsupported field with another term", newSpec(gpu, unsupported), []bool{true, true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &model.Deployment{Constraints: podSpecConstraints(test.spec)}
+			for ind, node := range []*model.Node{edge1, edge2} {
+				if allowed := deployment.CanRunOn(node); allowed != test.allowed[ind] {
+					t.Errorf("got allowed %v on node %d, wanted %v", allowed, ind+1, test.allowed[ind])
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateSpread(t *testing.T) {
+	template := &v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			TopologySpreadConstraints: []v1.TopologySpreadConstraint{
+				{MaxSkew: 2, TopologyKey: v1.LabelTopologyZone},
+				{MaxSkew: 1, TopologyKey: v1.LabelTopologyZone},
+				{MaxSkew: 1, TopologyKey: "rack"},
+			},
+			Affinity: &v1.Affinity{
+				PodAntiAffinity: &v1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+						{
+							TopologyKey: v1.LabelHostname,
+							LabelSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{"tier": "db", "app": "store"},
+							},
+						},
+						{
+							TopologyKey:   v1.LabelTopologyZone,
+							LabelSelector: &metav1.LabelSelector{},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	spread := templateSpread(template)
+	if spread.MaxZoneSkew != 1 {
+		t.Errorf("got max zone skew %d, wanted 1", spread.MaxZoneSkew)
+	}
+	if len(spread.AntiAffinity) != 1 {
+		t.Fatalf("got %d anti-affinities, wanted only the one over nodes", len(spread.AntiAffinity))
+	}
+
+	selector := spread.AntiAffinity[0]
+	if len(selector) != 2 || selector[0].Key != "app" || selector[1].Key != "tier" {
+		t.Errorf("the match labels are not translated in order: %v", selector)
+	}
+	if !selector.Matches(map[string]string{"app": "store", "tier": "db", "zone": "a"}) {
+		t.Errorf("the selector does not match its labels")
+	}
+	if selector.Matches(map[string]string{"app": "store"}) {
+		t.Errorf("the selector matches a part of its labels")
+	}
+	if toLabelSelector(nil).Matches(map[string]string{}) {
+		t.Errorf("a nil selector matches pods")
+	}
+
+	meta := &metav1.ObjectMeta{Annotations: map[string]string{MAX_PER_NODE_KEY: "2"}}
+	if maxPerNode, err := parseMaxPerNode(meta); err != nil || maxPerNode != 2 {
+		t.Errorf("got max per node %d (%v), wanted 2", maxPerNode, err)
+	}
+	meta.Annotations[MAX_PER_NODE_KEY] = "0"
+	if _, err := parseMaxPerNode(meta); err == nil {
+		t.Errorf("a non-positive max per node is accepted")
+	}
+}
@@ -0,0 +1,129 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Returns the disruption budget of the workload's pods from the
+// PodDisruptionBudgets in its namespace that select its pod template,
+// nil if there is none.
+func (kc *KubeConnector) workloadDisruptionBudget(w *workload) *model.DisruptionBudget {
+	return templateDisruptionBudget(w, kc.indexedDisruptionBudgets(w.meta.GetNamespace()))
+}
+
+// Lists the disruption budgets of the managed namespaces.
+func (kc *KubeConnector) listDisruptionBudgets() ([]*policyv1.PodDisruptionBudget, error) {
+	var budgets []*policyv1.PodDisruptionBudget
+	for _, namespace := range config.SchedulerGeneralConfig.Namespaces {
+		budgetList, err := kc.clientset.PolicyV1().PodDisruptionBudgets(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, pointersOf(budgetList.Items)...)
+	}
+
+	return budgets, nil
+}
+
+// Translates a k8s disruption budget event to deployment changed
+// events of the workloads in its namespace whose budgets have changed.
+func (kc *KubeConnector) translateDisruptionBudgetEvent(event watch.Event, eventStream chan<- *Event) {
+	budget, ok := event.Object.(*policyv1.PodDisruptionBudget)
+	if !ok {
+		return
+	}
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		kc.indexDisruptionBudget(budget)
+	case watch.Deleted:
+		kc.unindexDisruptionBudget(budget)
+	default:
+		return
+	}
+
+	// Only the workloads whose budgets have changed make events.
+	for _, w := range kc.indexedWorkloads(budget.Namespace) {
+		kc.translateDeploymentEvent(watch.Event{
+			Type:   watch.Modified,
+			Object: w.object,
+		}, eventStream)
+	}
+}
+
+// Returns the disruption budget of the budgets selecting the workload's
+// pod template. With more than one, no pod can be disrupted, like
+// the eviction API which refuses to evict such pods.
+func templateDisruptionBudget(w *workload, budgets []*policyv1.PodDisruptionBudget) *model.DisruptionBudget {
+	var matched []*policyv1.PodDisruptionBudget
+	for _, budget := range budgets {
+		selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err != nil {
+			log.Err(err).Msgf("invalid selector of disruption budget %s, ignoring it", budget.Name)
+			continue
+		}
+
+		if selector.Matches(labels.Set(w.template.Labels)) {
+			matched = append(matched, budget)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil
+	case 1:
+	default:
+		log.Warn().Msgf("workload %s has %d disruption budgets, none of its pods can be disrupted", w.key(), len(matched))
+
+		return &model.DisruptionBudget{
+			MaxUnavailable: &model.PodCount{Value: 0},
+		}
+	}
+
+	budget := matched[0]
+	minAvailable, err := toPodCount(budget.Spec.MinAvailable)
+	if err != nil {
+		log.Err(err).Msgf("invalid minimum available pods of disruption budget %s, ignoring it", budget.Name)
+	}
+	maxUnavailable, err := toPodCount(budget.Spec.MaxUnavailable)
+	if err != nil {
+		log.Err(err).Msgf("invalid maximum unavailable pods of disruption budget %s, ignoring it", budget.Name)
+	}
+	if minAvailable == nil && maxUnavailable == nil {
+		return nil
+	}
+
+	return &model.DisruptionBudget{
+		MinAvailable:   minAvailable,
+		MaxUnavailable: maxUnavailable,
+	}
+}
+
+// Translates a number or a percentage of pods, nil if it is not set.
+func toPodCount(value *intstr.IntOrString) (*model.PodCount, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if value.Type == intstr.Int {
+		return &model.PodCount{Value: value.IntValue()}, nil
+	}
+
+	percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	if err != nil || !strings.HasSuffix(value.StrVal, "%") || percent < 0 {
+		return nil, fmt.Errorf("%q is not a number or a percentage", value.StrVal)
+	}
+
+	return &model.PodCount{Value: percent, IsPercent: true}, nil
+}
@@ -0,0 +1,127 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTemplateDisruptionBudget(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"},
+	}
+	deployment.Spec.Template.Labels = map[string]string{"app": "web"}
+	w, _ := workloadOf(deployment)
+
+	newBudget := func(name string, selector map[string]string, minAvailable, maxUnavailable *intstr.IntOrString) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector:       &metav1.LabelSelector{MatchLabels: selector},
+				MinAvailable:   minAvailable,
+				MaxUnavailable: maxUnavailable,
+			},
+		}
+	}
+	half := intstr.FromString("50%")
+	one := intstr.FromInt32(1)
+	web := newBudget("web", map[string]string{"app": "web"}, &half, nil)
+	other := newBudget("other", map[string]string{"app": "db"}, nil, &one)
+
+	budget := templateDisruptionBudget(w, []*policyv1.PodDisruptionBudget{web, other})
+	if budget == nil || budget.MinAvailable == nil || *budget.MinAvailable != (model.PodCount{Value: 50, IsPercent: true}) {
+		t.Fatalf("got budget %v, wanted at least 50%% available", budget)
+	}
+	// 50% of 3 pods is rounded up.
+	if allowed := budget.AllowedDisruptions(3, 3); allowed != 1 {
+		t.Errorf("got %d allowed disruptions, wanted 1", allowed)
+	}
+
+	if budget := templateDisruptionBudget(w, []*policyv1.PodDisruptionBudget{other}); budget != nil {
+		t.Errorf("got budget %v of another workload's pods", budget)
+	}
+
+	// With more than one budget no pod can be disrupted.
+	all := newBudget("all", nil, nil, &one)
+	budget = templateDisruptionBudget(w, []*policyv1.PodDisruptionBudget{web, all})
+	if budget == nil || budget.AllowedDisruptions(3, 3) != 0 {
+		t.Errorf("pods selected by two budgets can be disrupted")
+	}
+
+	if _, err := toPodCount(&intstr.IntOrString{Type: intstr.String, StrVal: "half"}); err == nil {
+		t.Errorf("an invalid percentage is accepted")
+	}
+}
+
+func TestWatchDisruptionBudgets(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.Namespaces = []string{"team-a"}
+	config.SchedulerGeneralConfig.WorkloadSelector = ""
+
+	newDeployment := func(name string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec:       appsv1.DeploymentSpec{Template: newKubePodTemplate()},
+		}
+		deployment.Spec.Template.Labels = map[string]string{"app": name}
+		return deployment
+	}
+	clientset := fake.NewSimpleClientset([]runtime.Object{
+		newKubeNode("edge", "edge"),
+		newKubeNode("cloud", "cloud"),
+		newDeployment("web"),
+		newDeployment("db"),
+	}...)
+
+	c, err := newCachedKubeConnector(model.NewClusterState(), clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	if err := c.FindNodes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FindDeployments(); err != nil {
+		t.Fatal(err)
+	}
+	eventStream, err := c.WatchSchedulingEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A budget created after the deployments changes the one it selects.
+	one := intstr.FromInt32(1)
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			MaxUnavailable: &one,
+		},
+	}
+	budgets := clientset.PolicyV1().PodDisruptionBudgets("team-a")
+	if _, err := budgets.Create(context.Background(), budget, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-eventStream:
+		if event.EventType != DEPLOYMENT_CHANGED || event.Deployment.Id != utils.Hash("team-a/Deployment/web") {
+			t.Fatalf("got event %v, wanted the web deployment to change", event)
+		}
+		if event.Deployment.DisruptionBudget == nil || *event.Deployment.DisruptionBudget.MaxUnavailable != (model.PodCount{Value: 1}) {
+			t.Fatalf("got budget %v, wanted at most 1 unavailable pod", event.Deployment.DisruptionBudget)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the deployment changed event is not received")
+	}
+}
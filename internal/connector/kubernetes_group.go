@@ -0,0 +1,58 @@
+package connector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amsen20/ecmus/internal/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Label of pods having the name of their group, pods of a group
+// are placed together, all on edge or all on cloud.
+const POD_GROUP_KEY = "ecmus/pod-group"
+
+// Annotation (or label) of pods having the number of their group's pods.
+const POD_GROUP_SIZE_KEY = "ecmus/pod-group-size"
+
+// Returns the group of the pod from its labels, nil if it has none.
+// Groups are in the pods' namespaces, like the pods.
+func podGroup(pod *v1.Pod) *model.PodGroup {
+	name := pod.Labels[POD_GROUP_KEY]
+	if name == "" {
+		return nil
+	}
+
+	group := &model.PodGroup{
+		Name: pod.Namespace + "/" + name,
+	}
+
+	size, err := parsePodGroupSize(pod)
+	if err != nil {
+		log.Err(err).Msgf("could not read the size of group %s, placing it with its pending pods", group.Name)
+	}
+	group.Size = size
+
+	return group
+}
+
+// Reads the number of the group's pods from the "ecmus/pod-group-size"
+// annotation, or label if there is no such annotation, 0 if there is none.
+func parsePodGroupSize(objectMeta metav1.Object) (int, error) {
+	value, ok := objectMeta.GetAnnotations()[POD_GROUP_SIZE_KEY]
+	if !ok {
+		value, ok = objectMeta.GetLabels()[POD_GROUP_SIZE_KEY]
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	size, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("pod group size %q is not a positive number", value)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,33 @@
+package connector
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodGroup(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "team-a",
+			Labels:      map[string]string{POD_GROUP_KEY: "shop"},
+			Annotations: map[string]string{POD_GROUP_SIZE_KEY: "2"},
+		},
+	}
+
+	group := podGroup(pod)
+	if group == nil || group.Name != "team-a/shop" || group.Size != 2 {
+		t.Fatalf("got group %v, wanted team-a/shop of 2 pods", group)
+	}
+
+	pod.Annotations[POD_GROUP_SIZE_KEY] = "two"
+	if group := podGroup(pod); group == nil || group.Size != 0 {
+		t.Errorf("a group with an invalid size is not placed with its pending pods")
+	}
+
+	delete(pod.Labels, POD_GROUP_KEY)
+	if group := podGroup(pod); group != nil {
+		t.Errorf("got group %v for a pod without a group", group)
+	}
+}
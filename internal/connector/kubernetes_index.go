@@ -0,0 +1,218 @@
+package connector
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/amsen20/ecmus/internal/model"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// A node that the connector has given to the scheduler,
+// on either "edge" or "cloud".
+type indexedNode struct {
+	node        *model.Node
+	clusterType string
+}
+
+// Following methods read and change the connector's index of the
+// nodes, deployments and pods it has given to the scheduler.
+// The events are translated off the scheduler's goroutine, so
+// the translations look things up in the index instead of the
+// cluster state, which only the scheduler's goroutine touches.
+// Each method holds the index lock only while it runs, the lock
+// must not be held while sending events to the scheduler.
+
+func (kc *KubeConnector) indexedNode(nodeId int) (indexedNode, bool) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	node, ok := kc.nodes[nodeId]
+	return node, ok
+}
+
+func (kc *KubeConnector) indexNode(node *model.Node, clusterType string, name string) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	kc.nodes[node.Id] = indexedNode{node: node, clusterType: clusterType}
+	kc.nodeIdToName[node.Id] = name
+}
+
+func (kc *KubeConnector) unindexNode(nodeId int) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	delete(kc.nodes, nodeId)
+}
+
+func (kc *KubeConnector) nodeName(nodeId int) (string, bool) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	name, ok := kc.nodeIdToName[nodeId]
+	return name, ok
+}
+
+func (kc *KubeConnector) indexedDeployment(deploymentId int) (*model.Deployment, bool) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	deployment, ok := kc.deployments[deploymentId]
+	return deployment, ok
+}
+
+func (kc *KubeConnector) indexDeployment(deployment *model.Deployment, name string) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	kc.deployments[deployment.Id] = deployment
+	kc.deploymentIdToName[deployment.Id] = name
+}
+
+func (kc *KubeConnector) unindexDeployment(deploymentId int) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	delete(kc.deployments, deploymentId)
+}
+
+func (kc *KubeConnector) podName(podId int) (types.NamespacedName, bool) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	name, ok := kc.podIdToName[podId]
+	return name, ok
+}
+
+func (kc *KubeConnector) indexPod(podId int, name types.NamespacedName) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	kc.podIdToName[podId] = name
+}
+
+func (kc *KubeConnector) unindexPod(podId int) (types.NamespacedName, bool) {
+	kc.indexLock.Lock()
+	defer kc.indexLock.Unlock()
+
+	name, ok := kc.podIdToName[podId]
+	delete(kc.podIdToName, podId)
+	return name, ok
+}
+
+// Following methods read and change the workloads, disruption budgets
+// and priority classes that the connector has seen, both the scheduler's
+// goroutine and the translations use them, under the workloads lock.
+
+func (kc *KubeConnector) indexWorkload(w *workload) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	kc.workloads[w.key()] = w
+}
+
+// Forgets the workload and the replica sets it owns.
+func (kc *KubeConnector) unindexWorkload(w *workload) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	delete(kc.workloads, w.key())
+	if w.kind != DEPLOYMENT_KIND {
+		return
+	}
+	for key, deploymentName := range kc.replicaSetOwners {
+		if deploymentName == w.meta.GetName() && strings.HasPrefix(key, w.meta.GetNamespace()+"/") {
+			delete(kc.replicaSetOwners, key)
+		}
+	}
+}
+
+// Returns the workloads of the namespace, sorted by their keys.
+func (kc *KubeConnector) indexedWorkloads(namespace string) []*workload {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	var workloads []*workload
+	for _, w := range kc.workloads {
+		if w.meta.GetNamespace() == namespace {
+			workloads = append(workloads, w)
+		}
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		return workloads[i].key() < workloads[j].key()
+	})
+
+	return workloads
+}
+
+// Returns the disruption budgets of the namespace.
+func (kc *KubeConnector) indexedDisruptionBudgets(namespace string) []*policyv1.PodDisruptionBudget {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	var budgets []*policyv1.PodDisruptionBudget
+	for _, budget := range kc.disruptionBudgets {
+		if budget.Namespace == namespace {
+			budgets = append(budgets, budget)
+		}
+	}
+
+	return budgets
+}
+
+// Forgets the known disruption budgets and keeps the given ones.
+func (kc *KubeConnector) setDisruptionBudgets(budgets []*policyv1.PodDisruptionBudget) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	kc.disruptionBudgets = make(map[string]*policyv1.PodDisruptionBudget)
+	for _, budget := range budgets {
+		kc.disruptionBudgets[budget.Namespace+"/"+budget.Name] = budget
+	}
+}
+
+func (kc *KubeConnector) indexDisruptionBudget(budget *policyv1.PodDisruptionBudget) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	kc.disruptionBudgets[budget.Namespace+"/"+budget.Name] = budget
+}
+
+func (kc *KubeConnector) unindexDisruptionBudget(budget *policyv1.PodDisruptionBudget) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	delete(kc.disruptionBudgets, budget.Namespace+"/"+budget.Name)
+}
+
+func (kc *KubeConnector) indexedPriorityClass(name string) (int32, bool) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	priority, ok := kc.priorityClasses[name]
+	return priority, ok
+}
+
+func (kc *KubeConnector) indexPriorityClass(name string, priority int32) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	kc.priorityClasses[name] = priority
+}
+
+func (kc *KubeConnector) indexedReplicaSetOwner(key string) (string, bool) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	deploymentName, ok := kc.replicaSetOwners[key]
+	return deploymentName, ok
+}
+
+func (kc *KubeConnector) indexReplicaSetOwner(key string, deploymentName string) {
+	kc.workloadsLock.Lock()
+	defer kc.workloadsLock.Unlock()
+
+	kc.replicaSetOwners[key] = deploymentName
+}
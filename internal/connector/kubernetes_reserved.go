@@ -0,0 +1,161 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Selects the pods that are on some node.
+const ASSIGNED_PODS_SELECTOR = "spec.nodeName!="
+
+// A pod that the scheduler does not manage (daemon sets,
+// kube-system pods, other schedulers' pods) on an edge node.
+type reservingPod struct {
+	nodeId   int
+	requests *mat.VecDense
+}
+
+// Returns whether the pod is on a node and takes its resources,
+// finished pods do not take any resources.
+func isReserving(pod *v1.Pod) bool {
+	return pod.Spec.NodeName != "" &&
+		pod.Status.Phase != v1.PodSucceeded &&
+		pod.Status.Phase != v1.PodFailed
+}
+
+// Lists the pods of all namespaces that are on some node.
+func (kc *KubeConnector) listAssignedPods() ([]*v1.Pod, error) {
+	podList, err := kc.clientset.CoreV1().Pods(metav1.NamespaceAll).List(
+		context.Background(),
+		metav1.ListOptions{FieldSelector: ASSIGNED_PODS_SELECTOR},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pointersOf(podList.Items), nil
+}
+
+// Returns the pod as a reserving pod, false if the scheduler
+// manages it or it does not take any edge node's resources.
+func (kc *KubeConnector) reservingPodOf(pod *v1.Pod) (reservingPod, bool) {
+	if !isReserving(pod) {
+		return reservingPod{}, false
+	}
+	if _, ok := kc.podDeployment(pod); ok {
+		return reservingPod{}, false
+	}
+
+	nodeId := utils.Hash(pod.Spec.NodeName)
+	if node, ok := kc.indexedNode(nodeId); !ok || node.clusterType != "edge" {
+		return reservingPod{}, false
+	}
+
+	requests := podRequests(pod)
+	// Each pod takes exactly one of the node's pod slots.
+	if ind := config.ResourceIndex(string(v1.ResourcePods)); ind != -1 {
+		requests.SetVec(ind, 1)
+	}
+
+	return reservingPod{
+		nodeId:   nodeId,
+		requests: requests,
+	}, true
+}
+
+// Returns the sum of the requests of the reserving pods on the node,
+// the reserving pods lock must be held.
+func (kc *KubeConnector) nodeReserved(nodeId int) *mat.VecDense {
+	reserved := toResourceVector(v1.ResourceList{})
+	for _, pod := range kc.reservingPods {
+		if pod.nodeId == nodeId {
+			utils.SAddVec(reserved, pod.requests)
+		}
+	}
+
+	return reserved
+}
+
+// Forgets all the reserving pods and finds them again
+// among the given pods, then updates every edge node's
+// reserved resources.
+func (kc *KubeConnector) syncReserved(pods []*v1.Pod) {
+	kc.reservingPodsLock.Lock()
+	defer kc.reservingPodsLock.Unlock()
+
+	kc.reservingPods = make(map[types.NamespacedName]reservingPod)
+	for _, pod := range pods {
+		if reserving, ok := kc.reservingPodOf(pod); ok {
+			kc.reservingPods[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}] = reserving
+		}
+	}
+
+	for _, node := range kc.clusterState.Edge.Config.Nodes {
+		kc.clusterState.SetNodeReserved(node.Id, kc.nodeReserved(node.Id))
+	}
+}
+
+// Translates a k8s event of a pod on some node to node reserved
+// events of the edge nodes whose reserved resources have changed.
+func (kc *KubeConnector) translateReservingPodEvent(event watch.Event, eventStream chan<- *Event) {
+	v1Pod, ok := event.Object.(*v1.Pod)
+	if !ok {
+		return
+	}
+
+	kc.reservingPodsLock.Lock()
+	name := types.NamespacedName{Namespace: v1Pod.Namespace, Name: v1Pod.Name}
+	oldPod, wasReserving := kc.reservingPods[name]
+	newPod, isReserving := kc.reservingPodOf(v1Pod)
+	if event.Type == watch.Deleted {
+		// deleted pods do not take any resources
+		isReserving = false
+	}
+
+	if wasReserving && isReserving &&
+		oldPod.nodeId == newPod.nodeId && mat.Equal(oldPod.requests, newPod.requests) {
+		// most of the events are status changes
+		kc.reservingPodsLock.Unlock()
+		return
+	}
+
+	changedNodes := make([]int, 0)
+	if wasReserving {
+		delete(kc.reservingPods, name)
+		changedNodes = append(changedNodes, oldPod.nodeId)
+	}
+	if isReserving {
+		kc.reservingPods[name] = newPod
+		if !wasReserving || oldPod.nodeId != newPod.nodeId {
+			changedNodes = append(changedNodes, newPod.nodeId)
+		}
+	}
+
+	reserved := make([]*mat.VecDense, len(changedNodes))
+	for ind, nodeId := range changedNodes {
+		reserved[ind] = kc.nodeReserved(nodeId)
+	}
+	// The events are sent without the lock, the scheduler
+	// may be syncing before reading them.
+	kc.reservingPodsLock.Unlock()
+
+	for ind, nodeId := range changedNodes {
+		indexed, ok := kc.indexedNode(nodeId)
+		if !ok {
+			continue
+		}
+
+		eventStream <- &Event{
+			EventType: NODE_RESERVED_CHANGED,
+			Node:      indexed.node,
+			Reserved:  reserved[ind],
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package connector
+
+import (
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Returns the resources that the pod requests,
+// which kube-scheduler reserves for it on its node.
+func podRequests(pod *v1.Pod) *mat.VecDense {
+	return podSpecResources(&pod.Spec, config.REQUESTS_SIZING)
+}
+
+// Returns the resources of a pod with the spec the way kube-scheduler
+// computes them: the containers and the sidecars (restartable init
+// containers) run together, each other init container runs alone
+// with the sidecars started before it, and the overhead is added.
+func podSpecResources(spec *v1.PodSpec, sizing string) *mat.VecDense {
+	resources := toResourceVector(v1.ResourceList{})
+	for ind := range spec.Containers {
+		utils.SAddVec(resources, containerResources(&spec.Containers[ind], sizing))
+	}
+
+	sidecars := toResourceVector(v1.ResourceList{})
+	// the most that any init container needs while it runs
+	initPeak := toResourceVector(v1.ResourceList{})
+	for ind := range spec.InitContainers {
+		initContainer := &spec.InitContainers[ind]
+		initResources := containerResources(initContainer, sizing)
+
+		if initContainer.RestartPolicy != nil && *initContainer.RestartPolicy == v1.ContainerRestartPolicyAlways {
+			utils.SAddVec(resources, initResources)
+			utils.SAddVec(sidecars, initResources)
+			initResources = mat.VecDenseCopyOf(sidecars)
+		} else {
+			utils.SAddVec(initResources, sidecars)
+		}
+
+		utils.SMaxVec(initPeak, initResources)
+	}
+	utils.SMaxVec(resources, initPeak)
+
+	utils.SAddVec(resources, toResourceVector(spec.Overhead))
+
+	return resources
+}
+
+// Returns the container's limits or requests based on the sizing,
+// a resource missing from one of them is read from the other.
+func containerResources(container *v1.Container, sizing string) *mat.VecDense {
+	primary, secondary := container.Resources.Limits, container.Resources.Requests
+	if sizing == config.REQUESTS_SIZING {
+		primary, secondary = secondary, primary
+	}
+
+	resourceList := v1.ResourceList{}
+	for name, quantity := range secondary {
+		resourceList[name] = quantity
+	}
+	for name, quantity := range primary {
+		resourceList[name] = quantity
+	}
+
+	return toResourceVector(resourceList)
+}
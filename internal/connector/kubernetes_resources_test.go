@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPodSpecResources(t *testing.T) {
+	setUpConfig(t)
+
+	newContainer := func(cpuRequest string, cpuLimit string) v1.Container {
+		container := v1.Container{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{},
+				Limits:   v1.ResourceList{},
+			},
+		}
+		if cpuRequest != "" {
+			container.Resources.Requests[v1.ResourceCPU] = resource.MustParse(cpuRequest)
+		}
+		if cpuLimit != "" {
+			container.Resources.Limits[v1.ResourceCPU] = resource.MustParse(cpuLimit)
+		}
+		return container
+	}
+	sidecar := func(container v1.Container) v1.Container {
+		restartPolicy := v1.ContainerRestartPolicyAlways
+		container.RestartPolicy = &restartPolicy
+		return container
+	}
+
+	tests := []struct {
+		name   string
+		spec   v1.PodSpec
+		sizing string
+		cpu    float64
+	}{
+		{
+			name:   "containers are summed",
+			spec:   v1.PodSpec{Containers: []v1.Container{newContainer("1", "2"), newContainer("1", "2")}},
+			sizing: config.LIMITS_SIZING,
+			cpu:    4,
+		},
+		{
+			name:   "requests",
+			spec:   v1.PodSpec{Containers: []v1.Container{newContainer("1", "2"), newContainer("1", "2")}},
+			sizing: config.REQUESTS_SIZING,
+			cpu:    2,
+		},
+		{
+			name:   "missing limit is read from request",
+			spec:   v1.PodSpec{Containers: []v1.Container{newContainer("1", ""), newContainer("", "2")}},
+			sizing: config.LIMITS_SIZING,
+			cpu:    3,
+		},
+		{
+			name: "large init container",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{newContainer("3", "")},
+				Containers:     []v1.Container{newContainer("1", "")},
+			},
+			sizing: config.REQUESTS_SIZING,
+			cpu:    3,
+		},
+		{
+			name: "sidecars run with the containers and later init containers",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{sidecar(newContainer("1", "")), newContainer("2", "")},
+				Containers:     []v1.Container{newContainer("1", "")},
+			},
+			sizing: config.REQUESTS_SIZING,
+			cpu:    3,
+		},
+		{
+			name: "sidecars after an init container do not run with it",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{newContainer("10", ""), sidecar(newContainer("1", ""))},
+				Containers:     []v1.Container{newContainer("1", "")},
+			},
+			sizing: config.REQUESTS_SIZING,
+			cpu:    10,
+		},
+		{
+			name: "overhead",
+			spec: v1.PodSpec{
+				Containers: []v1.Container{newContainer("1", "")},
+				Overhead:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")},
+			},
+			sizing: config.REQUESTS_SIZING,
+			cpu:    1.25,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cpu := podSpecResources(&test.spec, test.sizing).AtVec(0); cpu != test.cpu {
+				t.Errorf("got %f cpu, wanted %f", cpu, test.cpu)
+			}
+		})
+	}
+}
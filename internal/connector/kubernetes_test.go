@@ -0,0 +1,53 @@
+package connector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDeletePod(t *testing.T) {
+	setUpConfig(t)
+
+	clientset := fake.NewSimpleClientset(newKubePod("team-a", "web-1", DEPLOYMENT_KIND, "web", "edge"))
+	// The first deletion fails.
+	failed := false
+	clientset.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failed {
+			return false, nil, nil
+		}
+		failed = true
+		return true, nil, fmt.Errorf("the API-server is not available")
+	})
+
+	kc := newKubeConnector(model.NewClusterState(), clientset)
+	kc.indexPod(1, types.NamespacedName{Namespace: "team-a", Name: "web-1"})
+	kc.indexPod(2, types.NamespacedName{Namespace: "team-a", Name: "web-2"})
+
+	if isKnown, err := kc.DeletePod(&model.Pod{Id: 1}); !isKnown || err == nil {
+		t.Fatalf("got known %v and error %v, wanted the failed deletion", isKnown, err)
+	}
+	if _, ok := kc.podName(1); !ok {
+		t.Fatal("the pod is forgotten while it is not deleted")
+	}
+
+	if isKnown, err := kc.DeletePod(&model.Pod{Id: 1}); !isKnown || err != nil {
+		t.Fatalf("got known %v and error %v, wanted the pod deleted", isKnown, err)
+	}
+	if isKnown, _ := kc.DeletePod(&model.Pod{Id: 1}); isKnown {
+		t.Error("the deleted pod is still known")
+	}
+
+	// A pod that is deleted already is forgotten.
+	if isKnown, err := kc.DeletePod(&model.Pod{Id: 2}); !isKnown || err != nil {
+		t.Fatalf("got known %v and error %v, wanted the missing pod forgotten", isKnown, err)
+	}
+	if _, ok := kc.podName(2); ok {
+		t.Error("the missing pod is still known")
+	}
+}
@@ -0,0 +1,401 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// The annotation (or label) key of deployments' edge share.
+const EDGE_SHARE_KEY = "ecmus/edge-share"
+
+// The annotation (or label) key of cloud nodes' cost.
+const NODE_COST_KEY = "ecmus/cost"
+
+// The annotation key of nodes' measured latencies from the users
+// of each zone, like "zone-a=5,zone-b=40" in ms.
+const NODE_LATENCIES_KEY = "ecmus/latencies"
+
+// The annotation key of the share of deployments' users
+// in each zone, like "zone-a=0.7,zone-b=0.3".
+const USER_ZONES_KEY = "ecmus/user-zones"
+
+// Translates a k8s node to the scheduler's node and
+// the cluster the node belongs to (either "edge" or "cloud").
+// Returns false if the scheduler should ignore the node.
+func toModelNode(node *v1.Node) (*model.Node, string, bool) {
+	// "nodetype" label categorize that the node is either
+	// cloud, edge or non.
+	// This label should be in object/meta.
+	clusterType, ok := node.GetObjectMeta().GetLabels()["nodetype"]
+	if !ok || clusterType == "ignore" {
+		return nil, "", false
+	}
+
+	resources := toResourceVector(node.Status.Allocatable)
+	for ind, resource := range config.SchedulerGeneralConfig.Resources {
+		// Removing the reserved amount of each resource of each
+		// node so background processes that are not pods won't
+		// cause "Out Of Resource" error during scheduler execution.
+		// The pods that the scheduler does not manage are reserved
+		// separately, see KubeConnector.syncReserved.
+		// TODO Scheduler should be robust to OOR errors.
+		resources.SetVec(ind, resources.AtVec(ind)-resource.Reserved)
+	}
+
+	cost, err := parseNodeCost(node)
+	if err != nil {
+		log.Err(err).Msgf("invalid cost for node %s, using 0", node.Name)
+	}
+
+	latencies, err := parseZoneValues(node, NODE_LATENCIES_KEY)
+	if err != nil {
+		log.Err(err).Msgf("invalid latencies for node %s, ignoring them", node.Name)
+	}
+
+	modelNode := &model.Node{
+		Id:        utils.Hash(node.GetObjectMeta().GetName()),
+		Resources: resources,
+		Zone:      node.Labels[v1.LabelTopologyZone],
+		Latencies: latencies,
+		Cost:      cost,
+		Labels:    maps.Clone(node.Labels),
+		Taints:    nodeTaints(node),
+	}
+
+	return modelNode, clusterType, true
+}
+
+// Returns whether the workload is the scheduler's own deployment,
+// named or labeled with the scheduler's name exactly, so the
+// workloads whose names only start with it are still scheduled.
+func isSchedulerWorkload(meta metav1.Object) bool {
+	name := config.SchedulerGeneralConfig.Name
+	return meta.GetName() == name || meta.GetLabels()["app"] == name
+}
+
+// Translates a k8s workload to the scheduler's deployment
+// and returns its key.
+// The fallback edge share is used if the workload's edge share is invalid.
+// Returns false if the scheduler should ignore the workload.
+func toModelDeployment(w *workload, fallbackEdgeShare float64) (*model.Deployment, string, bool) {
+	key := w.key()
+	if isSchedulerWorkload(w.meta) {
+		// ignore your pod
+		// WARN scheduler should not be a node which scheduler can scheduler a pod on!
+		return nil, "", false
+	}
+
+	if len(w.template.Spec.Containers) == 0 {
+		log.Warn().Msgf("workload %s has no containers, ignoring it", key)
+		return nil, "", false
+	}
+
+	edgeShare, err := parseEdgeShare(w.meta)
+	if err != nil {
+		log.Err(err).Msgf("invalid edge share for workload %s, using %f", key, fallbackEdgeShare)
+		edgeShare = fallbackEdgeShare
+	}
+
+	userZones, err := parseZoneValues(w.meta, USER_ZONES_KEY)
+	if err != nil {
+		log.Err(err).Msgf("invalid user zones for workload %s, ignoring them", key)
+	}
+
+	spread := templateSpread(w.template)
+	spread.MaxPerNode, err = parseMaxPerNode(w.meta)
+	if err != nil {
+		log.Err(err).Msgf("invalid maximum pods per node for workload %s, ignoring it", key)
+	}
+
+	modelDeployment := &model.Deployment{
+		Id:                utils.Hash(key),
+		UserZones:         userZones,
+		ResourcesRequired: podSpecResources(&w.template.Spec, config.SchedulerGeneralConfig.ResourceSizing),
+		EdgeShare:         edgeShare,
+		Constraints:       podSpecConstraints(&w.template.Spec),
+		Labels:            maps.Clone(w.template.Labels),
+		Spread:            spread,
+	}
+
+	// Each pod takes exactly one of the node's pod slots.
+	if ind := config.ResourceIndex(string(v1.ResourcePods)); ind != -1 {
+		modelDeployment.ResourcesRequired.SetVec(ind, 1)
+	}
+
+	return modelDeployment, key, true
+}
+
+// Reads the configured resources from a k8s resource list,
+// the resources which are not in the list are zero.
+func toResourceVector(resourceList v1.ResourceList) *mat.VecDense {
+	resources := config.SchedulerGeneralConfig.Resources
+
+	ret := mat.NewVecDense(len(resources), nil)
+	for ind, resource := range resources {
+		quantity, ok := resourceList[v1.ResourceName(resource.Name)]
+		if !ok {
+			continue
+		}
+
+		ret.SetVec(ind, quantity.AsApproximateFloat64()/resource.Scale)
+	}
+
+	return ret
+}
+
+// Translates a k8s deployment or stateful set event to internal events.
+func (kc *KubeConnector) translateDeploymentEvent(event watch.Event, eventStream chan<- *Event) {
+	w, ok := workloadOf(event.Object)
+	if !ok {
+		return
+	}
+
+	deploymentId := utils.Hash(w.key())
+	deployment, isKnown := kc.indexedDeployment(deploymentId)
+
+	if event.Type == watch.Deleted {
+		if !isKnown {
+			return
+		}
+
+		log.Info().Msgf("workload %s deleted", w.key())
+		kc.unindexWorkload(w)
+		kc.unindexDeployment(deploymentId)
+		eventStream <- &Event{
+			EventType:  DEPLOYMENT_DELETED,
+			Deployment: deployment,
+		}
+
+		return
+	}
+
+	if event.Type != watch.Added && event.Type != watch.Modified {
+		return
+	}
+
+	fallbackEdgeShare := float64(model.DEFAULT_EDGE_SHARE)
+	if isKnown {
+		fallbackEdgeShare = deployment.EdgeShare
+	}
+
+	modelDeployment, deploymentName, ok := toModelDeployment(w, fallbackEdgeShare)
+	if !ok {
+		return
+	}
+	modelDeployment.Priority = kc.templatePriority(w.template)
+	modelDeployment.DisruptionBudget = kc.workloadDisruptionBudget(w)
+	kc.indexWorkload(w)
+
+	if !isKnown {
+		log.Info().Msgf("workload %s added", deploymentName)
+		kc.indexDeployment(modelDeployment, deploymentName)
+		eventStream <- &Event{
+			EventType:  DEPLOYMENT_ADDED,
+			Deployment: modelDeployment,
+		}
+
+		return
+	}
+
+	if modelDeployment.EdgeShare == deployment.EdgeShare &&
+		mat.Equal(modelDeployment.ResourcesRequired, deployment.ResourcesRequired) &&
+		maps.Equal(modelDeployment.UserZones, deployment.UserZones) &&
+		modelDeployment.Priority == deployment.Priority &&
+		modelDeployment.Constraints.Equal(&deployment.Constraints) &&
+		maps.Equal(modelDeployment.Labels, deployment.Labels) &&
+		modelDeployment.Spread.Equal(&deployment.Spread) &&
+		modelDeployment.DisruptionBudget.Equal(deployment.DisruptionBudget) {
+		return
+	}
+
+	log.Info().Msgf("workload %s changed", deploymentName)
+	kc.indexDeployment(modelDeployment, deploymentName)
+	eventStream <- &Event{
+		EventType:  DEPLOYMENT_CHANGED,
+		Deployment: modelDeployment,
+	}
+}
+
+// Translates a k8s node event to internal events.
+func (kc *KubeConnector) translateNodeEvent(event watch.Event, eventStream chan<- *Event) {
+	v1Node, ok := event.Object.(*v1.Node)
+	if !ok {
+		return
+	}
+
+	nodeId := utils.Hash(v1Node.GetObjectMeta().GetName())
+	indexed, isKnown := kc.indexedNode(nodeId)
+	node := indexed.node
+
+	modelNode, clusterType, isManaged := toModelNode(v1Node)
+	if event.Type == watch.Deleted {
+		isManaged = false
+	}
+
+	switch {
+	case isKnown && !isManaged:
+		log.Info().Msgf("node %s removed", v1Node.Name)
+		kc.unindexNode(nodeId)
+		eventStream <- &Event{
+			EventType: NODE_REMOVED,
+			Node:      node,
+		}
+
+	case !isKnown && isManaged:
+		log.Info().Msgf("node %s added", v1Node.Name)
+		kc.indexNode(modelNode, clusterType, v1Node.Name)
+		eventStream <- &Event{
+			EventType: NODE_ADDED,
+			Node:      modelNode,
+			NodeType:  clusterType,
+		}
+
+	case isKnown && isManaged:
+		if indexed.clusterType != clusterType {
+			// The node has moved between edge and cloud.
+			log.Info().Msgf("node %s moved to %s", v1Node.Name, clusterType)
+			kc.indexNode(modelNode, clusterType, v1Node.Name)
+			eventStream <- &Event{
+				EventType: NODE_REMOVED,
+				Node:      node,
+			}
+			eventStream <- &Event{
+				EventType: NODE_ADDED,
+				Node:      modelNode,
+				NodeType:  clusterType,
+			}
+
+			return
+		}
+
+		if mat.Equal(node.Resources, modelNode.Resources) && node.Cost == modelNode.Cost &&
+			node.Zone == modelNode.Zone && maps.Equal(node.Latencies, modelNode.Latencies) &&
+			maps.Equal(node.Labels, modelNode.Labels) && slices.Equal(node.Taints, modelNode.Taints) {
+			return
+		}
+
+		log.Info().Msgf("node %s changed", v1Node.Name)
+		kc.indexNode(modelNode, clusterType, v1Node.Name)
+		eventStream <- &Event{
+			EventType: NODE_CHANGED,
+			Node:      modelNode,
+			NodeType:  clusterType,
+		}
+	}
+}
+
+// Reads the edge share of a deployment from its "ecmus/edge-share"
+// annotation, or label if there is no such annotation.
+// The edge share is the fraction of the deployment's pods
+// that are promised to be on edge, so it should be in [0, 1].
+func parseEdgeShare(objectMeta metav1.Object) (float64, error) {
+	value, ok := objectMeta.GetAnnotations()[EDGE_SHARE_KEY]
+	if !ok {
+		value, ok = objectMeta.GetLabels()[EDGE_SHARE_KEY]
+	}
+	if !ok {
+		return model.DEFAULT_EDGE_SHARE, nil
+	}
+
+	edgeShare, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("edge share %q is not a number", value)
+	}
+
+	if math.IsNaN(edgeShare) || edgeShare < 0 || edgeShare > 1 {
+		return 0, fmt.Errorf("edge share %q is not in [0, 1]", value)
+	}
+
+	return edgeShare, nil
+}
+
+// Reads the cost of a node from its "ecmus/cost"
+// annotation, or label if there is no such annotation.
+// Nodes without a cost are free.
+func parseNodeCost(objectMeta metav1.Object) (float64, error) {
+	value, ok := objectMeta.GetAnnotations()[NODE_COST_KEY]
+	if !ok {
+		value, ok = objectMeta.GetLabels()[NODE_COST_KEY]
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	cost, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("cost %q is not a number", value)
+	}
+
+	if math.IsNaN(cost) || math.IsInf(cost, 0) || cost < 0 {
+		return 0, fmt.Errorf("cost %q is not a non-negative number", value)
+	}
+
+	return cost, nil
+}
+
+// Reads values of zones, like "zone-a=5,zone-b=40", from the
+// annotation of the object, nil if there is no such annotation.
+// The values should be non-negative numbers.
+func parseZoneValues(objectMeta metav1.Object, key string) (map[string]float64, error) {
+	value, ok := objectMeta.GetAnnotations()[key]
+	if !ok {
+		return nil, nil
+	}
+
+	zoneValues := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		zone, zoneValue, ok := strings.Cut(entry, "=")
+		zone = strings.TrimSpace(zone)
+		if !ok || zone == "" {
+			return nil, fmt.Errorf("%q is not like zone=value", entry)
+		}
+
+		number, err := strconv.ParseFloat(strings.TrimSpace(zoneValue), 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) || number < 0 {
+			return nil, fmt.Errorf("value of zone %s, %q, is not a non-negative number", zone, zoneValue)
+		}
+		zoneValues[zone] = number
+	}
+
+	return zoneValues, nil
+}
+
+// Returns the priority of the pods of the template, from its
+// priority class which is asked once from the API-server.
+// Pods without a priority class have priority 0.
+func (kc *KubeConnector) templatePriority(template *v1.PodTemplateSpec) int32 {
+	if template.Spec.Priority != nil {
+		return *template.Spec.Priority
+	}
+
+	name := template.Spec.PriorityClassName
+	if name == "" {
+		return 0
+	}
+	if priority, ok := kc.indexedPriorityClass(name); ok {
+		return priority
+	}
+
+	priorityClass, err := kc.clientset.SchedulingV1().PriorityClasses().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		log.Err(err).Msgf("could not get priority class %s, using priority 0", name)
+
+		return 0
+	}
+	kc.indexPriorityClass(name, priorityClass.Value)
+
+	return priorityClass.Value
+}
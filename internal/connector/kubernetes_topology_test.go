@@ -0,0 +1,136 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestParseEdgeShare(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		wanted      float64
+		wantedError bool
+	}{
+		{"missing", nil, nil, model.DEFAULT_EDGE_SHARE, false},
+		{"annotation", map[string]string{EDGE_SHARE_KEY: "0.5"}, nil, 0.5, false},
+		{"label", nil, map[string]string{EDGE_SHARE_KEY: "0.25"}, 0.25, false},
+		{"annotation over label", map[string]string{EDGE_SHARE_KEY: "0.5"}, map[string]string{EDGE_SHARE_KEY: "0.25"}, 0.5, false},
+		{"spaces", map[string]string{EDGE_SHARE_KEY: " 0.3 "}, nil, 0.3, false},
+		{"bounds", map[string]string{EDGE_SHARE_KEY: "0"}, nil, 0, false},
+		{"not a number", map[string]string{EDGE_SHARE_KEY: "half"}, nil, 0, true},
+		{"empty", map[string]string{EDGE_SHARE_KEY: ""}, nil, 0, true},
+		{"above one", map[string]string{EDGE_SHARE_KEY: "1.5"}, nil, 0, true},
+		{"negative", map[string]string{EDGE_SHARE_KEY: "-0.1"}, nil, 0, true},
+		{"not a number value", map[string]string{EDGE_SHARE_KEY: "NaN"}, nil, 0, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			edgeShare, err := parseEdgeShare(&metav1.ObjectMeta{Annotations: test.annotations, Labels: test.labels})
+			if (err != nil) != test.wantedError {
+				t.Fatalf("got error %v, wanted an error: %v", err, test.wantedError)
+			}
+			if edgeShare != test.wanted {
+				t.Errorf("got edge share %v, wanted %v", edgeShare, test.wanted)
+			}
+		})
+	}
+}
+
+func TestSchedulerWorkloadIsIgnored(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.Name = "ecmus"
+
+	for _, test := range []struct {
+		name       string
+		workload   string
+		app        string
+		wantedSkip bool
+	}{
+		{"scheduler by name", "ecmus", "", true},
+		{"scheduler by label", "scheduler", "ecmus", true},
+		{"name starting with the scheduler's", "ecmus-frontend", "", false},
+		{"label starting with the scheduler's", "frontend", "ecmus-frontend", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: test.workload, Labels: map[string]string{"app": test.app}},
+				Spec:       appsv1.DeploymentSpec{Template: newKubePodTemplate()},
+			}
+			w, _ := workloadOf(deployment)
+
+			if _, _, ok := toModelDeployment(w, model.DEFAULT_EDGE_SHARE); ok == test.wantedSkip {
+				t.Errorf("got the workload scheduled: %v, wanted it skipped: %v", ok, test.wantedSkip)
+			}
+		})
+	}
+}
+
+func TestEdgeShareUpdates(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.Namespaces = []string{"team-a"}
+	config.SchedulerGeneralConfig.WorkloadSelector = ""
+
+	clusterState := model.NewClusterState()
+	kc := newKubeConnector(clusterState, nil)
+
+	// Sends an event of the deployment with the edge share annotation
+	// and applies the translated event to the cluster state.
+	send := func(eventType watch.EventType, edgeShare string) *Event {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"},
+			Spec:       appsv1.DeploymentSpec{Template: newKubePodTemplate()},
+		}
+		if edgeShare != "" {
+			deployment.Annotations = map[string]string{EDGE_SHARE_KEY: edgeShare}
+		}
+
+		eventStream := make(chan *Event, 1)
+		kc.translateDeploymentEvent(watch.Event{Type: eventType, Object: deployment}, eventStream)
+		select {
+		case event := <-eventStream:
+			switch event.EventType {
+			case DEPLOYMENT_ADDED:
+				clusterState.Edge.Config.AddDeployment(event.Deployment)
+			case DEPLOYMENT_CHANGED:
+				clusterState.UpdateDeployment(event.Deployment)
+			}
+			return event
+		default:
+			return nil
+		}
+	}
+
+	for _, test := range []struct {
+		name            string
+		eventType       watch.EventType
+		edgeShare       string
+		wantedEvent     EventType
+		wantedEdgeShare float64
+	}{
+		{"added", watch.Added, "0.5", DEPLOYMENT_ADDED, 0.5},
+		{"changed", watch.Modified, "0.8", DEPLOYMENT_CHANGED, 0.8},
+		{"unchanged", watch.Modified, "0.8", -1, 0.8},
+		// An invalid edge share keeps the last valid one.
+		{"invalid", watch.Modified, "2", -1, 0.8},
+		{"removed", watch.Modified, "", DEPLOYMENT_CHANGED, model.DEFAULT_EDGE_SHARE},
+	} {
+		event := send(test.eventType, test.edgeShare)
+		if test.wantedEvent == -1 {
+			if event != nil {
+				t.Errorf("%s: got event %v, wanted none", test.name, event)
+			}
+		} else if event == nil || event.EventType != test.wantedEvent {
+			t.Errorf("%s: got event %v, wanted %v", test.name, event, test.wantedEvent)
+		}
+
+		if deployments := clusterState.Edge.Config.Deployments; len(deployments) != 1 || deployments[0].EdgeShare != test.wantedEdgeShare {
+			t.Errorf("%s: wanted the deployment with edge share %v", test.name, test.wantedEdgeShare)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package connector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// The time waited before watching again after failing to watch.
+const WATCH_RETRY_DURATION = time.Second
+
+type listFunc func(options metav1.ListOptions) ([]runtime.Object, string, error)
+type watchFunc func(options metav1.ListOptions) (watch.Interface, error)
+
+// A watch of some kind of k8s objects that is resumed from the last
+// seen resource version whenever the API-server closes it.
+// If the resource version is too old to resume from, the objects
+// are listed again and the differences with the last seen objects
+// are handled as events, so no change is missed.
+type resumableWatch struct {
+	name string
+	// the options of both listing and watching, like selectors
+	options metav1.ListOptions
+	list    listFunc
+	watch   watchFunc
+	handle  func(watch.Event)
+	// called after events may have been missed
+	resync func()
+
+	resourceVersion string
+	// the last seen objects by their namespace and name
+	objects map[string]runtime.Object
+
+	// closed to stop watching
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newResumableWatch(name string, options metav1.ListOptions, list listFunc, watchCall watchFunc, handle func(watch.Event), resync func()) *resumableWatch {
+	return &resumableWatch{
+		name:    name,
+		options: options,
+		list:    list,
+		watch:   watchCall,
+		handle:  handle,
+		resync:  resync,
+		objects: make(map[string]runtime.Object),
+		done:    make(chan struct{}),
+	}
+}
+
+// Starts watching, the first watch is started before returning
+// so that the error of not being able to watch at all is reported.
+func (rw *resumableWatch) start() error {
+	watcher, err := rw.startWatch()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			rw.consume(watcher)
+
+			for {
+				if rw.isStopped() {
+					return
+				}
+
+				log.Info().Msgf("resuming %s watch from resource version %q", rw.name, rw.resourceVersion)
+				watcher, err = rw.startWatch()
+				if err == nil {
+					break
+				}
+
+				if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					rw.relist()
+					continue
+				}
+
+				log.Err(err).Msgf("couldn't watch %s, retrying after a while", rw.name)
+				rw.wait()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stops watching, the events after stopping are not handled.
+func (rw *resumableWatch) stop() {
+	rw.stopOnce.Do(func() {
+		close(rw.done)
+	})
+}
+
+func (rw *resumableWatch) isStopped() bool {
+	select {
+	case <-rw.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Waits before retrying, or until the watch is stopped.
+func (rw *resumableWatch) wait() {
+	select {
+	case <-time.After(WATCH_RETRY_DURATION):
+	case <-rw.done:
+	}
+}
+
+func (rw *resumableWatch) startWatch() (watch.Interface, error) {
+	options := rw.options
+	options.ResourceVersion = rw.resourceVersion
+	options.AllowWatchBookmarks = true
+
+	return rw.watch(options)
+}
+
+// Handles the watcher's events until it is closed or the watch is stopped.
+func (rw *resumableWatch) consume(watcher watch.Interface) {
+	defer watcher.Stop()
+
+	for {
+		var event watch.Event
+		select {
+		case e, ok := <-watcher.ResultChan():
+			if !ok {
+				log.Warn().Msgf("%s watch is closed", rw.name)
+				return
+			}
+			event = e
+		case <-rw.done:
+			return
+		}
+
+		switch event.Type {
+		case watch.Bookmark:
+			rw.remember(event)
+		case watch.Error:
+			err := apierrors.FromObject(event.Object)
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				log.Warn().Msgf("%s watch's resource version %q is too old", rw.name, rw.resourceVersion)
+				rw.relist()
+				return
+			}
+
+			log.Err(err).Msgf("%s watch failed", rw.name)
+			return
+		default:
+			rw.remember(event)
+			rw.handle(event)
+		}
+	}
+}
+
+// Keeps the event's object and resource version for resuming.
+func (rw *resumableWatch) remember(event watch.Event) {
+	accessor, err := meta.Accessor(event.Object)
+	if err != nil {
+		return
+	}
+
+	rw.resourceVersion = accessor.GetResourceVersion()
+	if event.Type == watch.Bookmark {
+		return
+	}
+
+	key := accessor.GetNamespace() + "/" + accessor.GetName()
+	if event.Type == watch.Deleted {
+		delete(rw.objects, key)
+		return
+	}
+	rw.objects[key] = event.Object
+}
+
+// Lists the objects again and handles the differences with the last
+// seen objects as events, then the watch resumes from the listed version.
+func (rw *resumableWatch) relist() {
+	log.Info().Msgf("listing %s again", rw.name)
+
+	var objects []runtime.Object
+	var resourceVersion string
+	for {
+		var err error
+		objects, resourceVersion, err = rw.list(rw.options)
+		if err == nil {
+			break
+		}
+
+		log.Err(err).Msgf("couldn't list %s, retrying after a while", rw.name)
+		rw.wait()
+		if rw.isStopped() {
+			return
+		}
+	}
+
+	listed := make(map[string]bool)
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+
+		key := accessor.GetNamespace() + "/" + accessor.GetName()
+		listed[key] = true
+
+		old, ok := rw.objects[key]
+		if !ok {
+			rw.handleListed(watch.Added, object)
+			continue
+		}
+
+		oldAccessor, err := meta.Accessor(old)
+		if err != nil || oldAccessor.GetResourceVersion() != accessor.GetResourceVersion() {
+			rw.handleListed(watch.Modified, object)
+		}
+	}
+
+	for key, object := range rw.objects {
+		if !listed[key] {
+			rw.handleListed(watch.Deleted, object)
+		}
+	}
+
+	rw.resourceVersion = resourceVersion
+	rw.resync()
+}
+
+func (rw *resumableWatch) handleListed(eventType watch.EventType, object runtime.Object) {
+	event := watch.Event{
+		Type:   eventType,
+		Object: object,
+	}
+
+	// The resource version is the listed one after all the differences.
+	resourceVersion := rw.resourceVersion
+	rw.remember(event)
+	rw.resourceVersion = resourceVersion
+
+	rw.handle(event)
+}
+
+// Returns a list function from a k8s list call.
+func listOf[T runtime.Object](listCall func(ctx context.Context, options metav1.ListOptions) (T, error)) listFunc {
+	return func(options metav1.ListOptions) ([]runtime.Object, string, error) {
+		objectList, err := listCall(context.Background(), options)
+		if err != nil {
+			return nil, "", err
+		}
+
+		objects, err := meta.ExtractList(objectList)
+		if err != nil {
+			return nil, "", err
+		}
+
+		listAccessor, err := meta.ListAccessor(objectList)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return objects, listAccessor.GetResourceVersion(), nil
+	}
+}
+
+// Returns a watch function from a k8s watch call.
+func watchOf(watchCall func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error)) watchFunc {
+	return func(options metav1.ListOptions) (watch.Interface, error) {
+		return watchCall(context.Background(), options)
+	}
+}
+
+// Translates the events of all the watches on a single goroutine,
+// so the events are sent to the stream in the order they are seen.
+// The translations run off the scheduler's goroutine, they must not
+// read or change the cluster state.
+type translator struct {
+	eventStream  chan<- *Event
+	translations chan func()
+	// the translated events, passed to the stream until stopped
+	translated chan *Event
+	done       chan struct{}
+	stopped    chan struct{}
+}
+
+func newTranslator(eventStream chan<- *Event) *translator {
+	t := &translator{
+		eventStream:  eventStream,
+		translations: make(chan func()),
+		translated:   make(chan *Event),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+
+	go func() {
+		defer close(t.stopped)
+		defer close(t.translated)
+
+		for {
+			select {
+			case translate := <-t.translations:
+				translate()
+			case <-t.done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for event := range t.translated {
+			select {
+			case t.eventStream <- event:
+			case <-t.done:
+				// nobody reads the events anymore
+			}
+		}
+	}()
+
+	return t
+}
+
+// Returns a watch handler that translates the events with translate.
+func (t *translator) handler(translate func(watch.Event, chan<- *Event)) func(watch.Event) {
+	return func(event watch.Event) {
+		select {
+		case t.translations <- func() { translate(event, t.translated) }:
+		case <-t.done:
+		}
+	}
+}
+
+// Asks the scheduler to resync, after the events translated before.
+func (t *translator) resync() {
+	select {
+	case t.translations <- func() {
+		t.translated <- &Event{
+			EventType: RESYNC_REQUIRED,
+		}
+	}:
+	case <-t.done:
+	}
+}
+
+// Stops translating and waits for the current translation,
+// the events after stopping are dropped.
+func (t *translator) stop() {
+	close(t.done)
+	<-t.stopped
+}
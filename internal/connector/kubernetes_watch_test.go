@@ -0,0 +1,274 @@
+package connector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestPod(name string, resourceVersion string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			ResourceVersion: resourceVersion,
+		},
+	}
+}
+
+// A resumable watch on fake watchers, the watchers are
+// started in order and the watched versions are recorded.
+type testWatch struct {
+	*resumableWatch
+
+	watchers []*watch.FakeWatcher
+	versions chan string
+	events   chan watch.Event
+	resyncs  chan struct{}
+}
+
+func newTestWatch(t *testing.T, watchers int, listed []runtime.Object, listedVersion string) *testWatch {
+	tw := &testWatch{
+		versions: make(chan string, watchers),
+		events:   make(chan watch.Event, 16),
+		resyncs:  make(chan struct{}, 1),
+	}
+	for i := 0; i < watchers; i++ {
+		tw.watchers = append(tw.watchers, watch.NewFake())
+	}
+
+	started := 0
+	tw.resumableWatch = newResumableWatch(
+		"pods",
+		metav1.ListOptions{},
+		func(options metav1.ListOptions) ([]runtime.Object, string, error) {
+			return listed, listedVersion, nil
+		},
+		func(options metav1.ListOptions) (watch.Interface, error) {
+			if started == len(tw.watchers) {
+				return nil, fmt.Errorf("watched more than %d times", len(tw.watchers))
+			}
+			tw.versions <- options.ResourceVersion
+			started++
+			return tw.watchers[started-1], nil
+		},
+		func(event watch.Event) { tw.events <- event },
+		func() { tw.resyncs <- struct{}{} },
+	)
+
+	if err := tw.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return tw
+}
+
+func (tw *testWatch) expectVersion(t *testing.T, want string) {
+	select {
+	case got := <-tw.versions:
+		if got != want {
+			t.Errorf("watched from resource version %q, wanted %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("not watched from resource version %q", want)
+	}
+}
+
+func (tw *testWatch) expectEvent(t *testing.T, eventType watch.EventType, name string) {
+	select {
+	case event := <-tw.events:
+		pod := event.Object.(*v1.Pod)
+		if event.Type != eventType || pod.Name != name {
+			t.Errorf("got %s event of pod %s, wanted %s of pod %s", event.Type, pod.Name, eventType, name)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no %s event of pod %s", eventType, name)
+	}
+}
+
+func TestResumableWatchResumes(t *testing.T) {
+	tw := newTestWatch(t, 2, nil, "")
+	tw.expectVersion(t, "")
+
+	tw.watchers[0].Add(newTestPod("a", "5"))
+	tw.expectEvent(t, watch.Added, "a")
+	tw.watchers[0].Action(watch.Bookmark, newTestPod("", "7"))
+	tw.watchers[0].Stop()
+
+	tw.expectVersion(t, "7")
+	tw.watchers[1].Modify(newTestPod("a", "8"))
+	tw.expectEvent(t, watch.Modified, "a")
+
+	select {
+	case <-tw.resyncs:
+		t.Errorf("resynced while no event is missed")
+	default:
+	}
+}
+
+func TestResumableWatchRelists(t *testing.T) {
+	listed := []runtime.Object{
+		newTestPod("a", "1"),
+		newTestPod("b", "4"),
+		newTestPod("d", "5"),
+	}
+	tw := newTestWatch(t, 2, listed, "10")
+	tw.expectVersion(t, "")
+
+	tw.watchers[0].Add(newTestPod("a", "1"))
+	tw.watchers[0].Add(newTestPod("b", "2"))
+	tw.watchers[0].Add(newTestPod("c", "3"))
+	for _, name := range []string{"a", "b", "c"} {
+		tw.expectEvent(t, watch.Added, name)
+	}
+	tw.watchers[0].Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+
+	tw.expectEvent(t, watch.Modified, "b")
+	tw.expectEvent(t, watch.Added, "d")
+	tw.expectEvent(t, watch.Deleted, "c")
+	select {
+	case <-tw.resyncs:
+	case <-time.After(time.Second):
+		t.Fatalf("not resynced after listing again")
+	}
+	tw.expectVersion(t, "10")
+}
+
+func TestResumableWatchStops(t *testing.T) {
+	tw := newTestWatch(t, 2, nil, "")
+	tw.expectVersion(t, "")
+
+	tw.stop()
+	for start := time.Now(); !tw.watchers[0].IsStopped(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("the watcher is not stopped")
+		}
+	}
+
+	select {
+	case version := <-tw.versions:
+		t.Errorf("watched again from resource version %q after stopping", version)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTranslator(t *testing.T) {
+	eventStream := make(chan *Event)
+	translator := newTranslator(eventStream)
+
+	// The translations of all the watches share the
+	// counter, they run on the translator's goroutine.
+	translated := 0
+	handle := translator.handler(func(event watch.Event, eventStream chan<- *Event) {
+		translated++
+		eventStream <- &Event{EventType: POD_CREATED}
+	})
+
+	const watches = 4
+	for i := 0; i < watches; i++ {
+		go func() {
+			handle(watch.Event{Type: watch.Added, Object: newTestPod("a", "1")})
+			translator.resync()
+		}()
+	}
+
+	created := 0
+	for i := 0; i < 2*watches; i++ {
+		select {
+		case event := <-eventStream:
+			if event.EventType == POD_CREATED {
+				created++
+			} else if created == 0 {
+				t.Fatalf("the resync is sent before the event handled before it")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, wanted %d", i, 2*watches)
+		}
+	}
+	if created != watches || translated != watches {
+		t.Fatalf("translated %d events, wanted %d", translated, watches)
+	}
+
+	// An event that nobody reads does not block stopping,
+	// and the events after stopping are dropped.
+	handle(watch.Event{Type: watch.Added, Object: newTestPod("b", "2")})
+	translator.stop()
+	handle(watch.Event{Type: watch.Added, Object: newTestPod("c", "3")})
+	if translated != watches+1 {
+		t.Errorf("translated %d events, wanted %d", translated, watches+1)
+	}
+}
+
+func TestTranslationsDoNotTouchClusterState(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.Namespaces = []string{"team-a"}
+	config.SchedulerGeneralConfig.WorkloadSelector = ""
+
+	template := newKubePodTemplate()
+	template.Spec.PriorityClassName = "high"
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"},
+		Spec:       appsv1.DeploymentSpec{Template: template},
+	}
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{}},
+	}
+	clientset := fake.NewSimpleClientset([]runtime.Object{
+		newKubeNode("edge", "edge"),
+		newKubeNode("cloud", "cloud"),
+		deployment,
+		budget,
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high"}, Value: 10},
+	}...)
+	clusterState := model.NewClusterState()
+	kc := newKubeConnector(clusterState, clientset)
+	if err := kc.FindNodes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := kc.FindDeployments(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The translations run while the scheduler changes the
+	// cluster state, the race detector finds shared accesses.
+	translated := make(chan struct{})
+	go func() {
+		defer close(translated)
+
+		eventStream := make(chan *Event, 1024)
+		for i := 0; i < 100; i++ {
+			pod := newKubePod("team-a", fmt.Sprintf("web-%d", i), DEPLOYMENT_KIND, "web", "edge")
+			kc.translatePodEvent(watch.Event{Type: watch.Added, Object: pod}, eventStream)
+			daemonPod := newKubePod("kube-system", fmt.Sprintf("daemon-%d", i), "DaemonSet", "d", "edge")
+			kc.translateReservingPodEvent(watch.Event{Type: watch.Added, Object: daemonPod}, eventStream)
+			kc.translateNodeEvent(watch.Event{Type: watch.Modified, Object: newKubeNode("edge", "edge")}, eventStream)
+			kc.translateDeploymentEvent(watch.Event{Type: watch.Modified, Object: deployment}, eventStream)
+			kc.translateDisruptionBudgetEvent(watch.Event{Type: watch.Modified, Object: budget}, eventStream)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		node := &model.Node{Id: i, Resources: toResourceVector(v1.ResourceList{})}
+		clusterState.AddNode(node, "edge")
+		clusterState.SetNodeReserved(node.Id, toResourceVector(v1.ResourceList{}))
+		clusterState.PodsMap[i] = &model.Pod{Id: i}
+		clusterState.RemoveNode(node.Id)
+		if err := kc.FindDeployments(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-translated
+}
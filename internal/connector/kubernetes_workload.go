@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"strings"
+
+	"github.com/amsen20/ecmus/internal/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kinds of the workloads whose pods are managed.
+const (
+	DEPLOYMENT_KIND   = "Deployment"
+	STATEFUL_SET_KIND = "StatefulSet"
+)
+
+// A workload whose pods the scheduler manages, each
+// workload is a deployment for the scheduler.
+type workload struct {
+	object   runtime.Object
+	meta     metav1.Object
+	kind     string
+	template *v1.PodTemplateSpec
+}
+
+// Returns the workload of a k8s deployment or stateful set.
+func workloadOf(object runtime.Object) (*workload, bool) {
+	switch object := object.(type) {
+	case *appsv1.Deployment:
+		return &workload{
+			object:   object,
+			meta:     object,
+			kind:     DEPLOYMENT_KIND,
+			template: &object.Spec.Template,
+		}, true
+	case *appsv1.StatefulSet:
+		return &workload{
+			object:   object,
+			meta:     object,
+			kind:     STATEFUL_SET_KIND,
+			template: &object.Spec.Template,
+		}, true
+	}
+
+	return nil, false
+}
+
+func (w *workload) key() string {
+	return workloadKey(w.meta.GetNamespace(), w.kind, w.meta.GetName())
+}
+
+// Returns the key of a workload, workloads with the same
+// name in different namespaces or of different kinds differ.
+func workloadKey(namespace string, kind string, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// Returns the key of the workload that owns the pod, from its owner
+// references. Deployments own pods through their replica sets.
+func (kc *KubeConnector) podWorkloadKey(pod *v1.Pod) (string, bool) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "", false
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		deploymentName, isOwned, err := kc.replicaSetDeployment(pod.Namespace, owner.Name)
+		if err == nil {
+			if !isOwned {
+				return "", false
+			}
+
+			return workloadKey(pod.Namespace, DEPLOYMENT_KIND, deploymentName), true
+		}
+		log.Err(err).Msgf("could not get replica set %s/%s, finding its deployment by its name", pod.Namespace, owner.Name)
+
+		// A deployment's replica sets are named after
+		// the deployment and their pod template's hash.
+		hash, ok := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		if !ok || !strings.HasSuffix(owner.Name, "-"+hash) {
+			return "", false
+		}
+
+		return workloadKey(pod.Namespace, DEPLOYMENT_KIND, strings.TrimSuffix(owner.Name, "-"+hash)), true
+	case STATEFUL_SET_KIND:
+		return workloadKey(pod.Namespace, STATEFUL_SET_KIND, owner.Name), true
+	}
+
+	return "", false
+}
+
+// Returns the name of the deployment that owns the replica set from
+// the replica set's owner references, and whether a deployment owns it.
+// The owners are asked once, see KubeConnector.getReplicaSet.
+func (kc *KubeConnector) replicaSetDeployment(namespace string, name string) (string, bool, error) {
+	key := namespace + "/" + name
+	if deploymentName, ok := kc.indexedReplicaSetOwner(key); ok {
+		return deploymentName, deploymentName != "", nil
+	}
+
+	replicaSet, err := kc.getReplicaSet(namespace, name)
+	if err != nil {
+		return "", false, err
+	}
+
+	var deploymentName string
+	if owner := metav1.GetControllerOf(replicaSet); owner != nil && owner.Kind == DEPLOYMENT_KIND {
+		deploymentName = owner.Name
+	}
+	kc.indexReplicaSetOwner(key, deploymentName)
+
+	return deploymentName, deploymentName != "", nil
+}
+
+// Returns a replica set getter asking the API-server.
+func replicaSetGetterOf(clientset kubernetes.Interface) func(string, string) (*appsv1.ReplicaSet, error) {
+	return func(namespace string, name string) (*appsv1.ReplicaSet, error) {
+		return clientset.AppsV1().ReplicaSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	}
+}
+
+// Returns the pod's id, pods with the same name
+// in different namespaces have different ids.
+func podId(pod *v1.Pod) int {
+	return utils.Hash(pod.Namespace + "/" + pod.Name)
+}
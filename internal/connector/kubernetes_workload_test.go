@@ -0,0 +1,62 @@
+package connector
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/model"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodWorkloadKey(t *testing.T) {
+	setUpConfig(t)
+
+	isController := true
+	newReplicaSet := func(name string, ownerKind string, ownerName string) *appsv1.ReplicaSet {
+		replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name}}
+		if ownerKind != "" {
+			replicaSet.OwnerReferences = []metav1.OwnerReference{{
+				Kind:       ownerKind,
+				Name:       ownerName,
+				Controller: &isController,
+			}}
+		}
+		return replicaSet
+	}
+	clientset := fake.NewSimpleClientset(
+		// The replica set's name does not start with its deployment's.
+		newReplicaSet("web-5d9c", DEPLOYMENT_KIND, "frontend"),
+		newReplicaSet("api-5d9c", "", ""),
+	)
+	kc := newKubeConnector(model.NewClusterState(), clientset)
+
+	for _, test := range []struct {
+		name       string
+		ownerKind  string
+		ownerName  string
+		wantedKey  string
+		wantedSkip bool
+	}{
+		{"deployment from the replica set's owner", DEPLOYMENT_KIND, "web", workloadKey("team-a", DEPLOYMENT_KIND, "frontend"), false},
+		{"replica set without a deployment", DEPLOYMENT_KIND, "api", "", true},
+		{"deployment from the missing replica set's name", DEPLOYMENT_KIND, "shop", workloadKey("team-a", DEPLOYMENT_KIND, "shop"), false},
+		{"stateful set", STATEFUL_SET_KIND, "db", workloadKey("team-a", STATEFUL_SET_KIND, "db"), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// The deployment pods are owned by the replica set of the owner's name.
+			pod := newKubePod("team-a", "p", test.ownerKind, test.ownerName, "")
+			key, ok := kc.podWorkloadKey(pod)
+			if ok == test.wantedSkip || key != test.wantedKey {
+				t.Errorf("got key %q, wanted %q", key, test.wantedKey)
+			}
+		})
+	}
+
+	// The owners are asked once.
+	actions := len(clientset.Actions())
+	kc.podWorkloadKey(newKubePod("team-a", "p", DEPLOYMENT_KIND, "web", ""))
+	if len(clientset.Actions()) != actions {
+		t.Errorf("the replica set's owner is asked again")
+	}
+}
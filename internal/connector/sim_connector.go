@@ -0,0 +1,506 @@
+package connector
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Capacity of the simulated connector's event stream, the
+// events delivered in a single step of the clock should fit in it.
+const SIM_EVENT_BUFFER_SIZE = 1024
+
+// Latencies of the simulated control plane and
+// the faults it injects in the watched events.
+type SimConfig struct {
+	// Seed of the faults' randomness.
+	Seed int64
+	// From a deployment lacking a pod until the pod is created.
+	CreateLatency time.Duration
+	// From a deploy request until the pod is bound to the node.
+	BindLatency time.Duration
+	// From a pod being bound until it is running.
+	StartLatency time.Duration
+	// From a delete request until the pod is gone.
+	DeleteLatency time.Duration
+	// From a change in the cluster until its event is delivered.
+	WatchLatency time.Duration
+
+	Faults SimFaults
+}
+
+// Probabilities of each event being dropped, duplicated or
+// delayed up to ReorderDelay more, which may reorder it.
+type SimFaults struct {
+	DropRate      float64
+	DuplicateRate float64
+	ReorderRate   float64
+	ReorderDelay  time.Duration
+}
+
+var DefaultSimConfig = SimConfig{
+	CreateLatency: 200 * time.Millisecond,
+	BindLatency:   50 * time.Millisecond,
+	StartLatency:  500 * time.Millisecond,
+	DeleteLatency: 100 * time.Millisecond,
+	WatchLatency:  10 * time.Millisecond,
+}
+
+// A pod as the simulated control plane knows it.
+type SimPod struct {
+	Id         int
+	Deployment *model.Deployment
+	// -1 if the pod is not bound yet
+	NodeId int
+	Status model.PodStatus
+	// whether the pod is being deleted
+	Terminating bool
+}
+
+type simDeployment struct {
+	deployment *model.Deployment
+	replicas   int
+	// number of pods that are going to be created
+	creating int
+}
+
+// An event of the simulated control plane, pods and nodes
+// are found in the cluster state when it is delivered.
+type simEvent struct {
+	eventType    EventType
+	podId        int
+	deploymentId int
+	nodeId       int
+	status       model.PodStatus
+}
+
+// This connector simulates a kubernetes like control plane
+// on a virtual clock: deployments' pods are created (and
+// re-created after being deleted) like a ReplicaSet does,
+// bound pods start running after a while and all changes
+// are watched with latency and optionally faults.
+// Nothing happens unless the clock is stepped.
+type SimConnector struct {
+	clusterState *model.ClusterState
+	clock        *VirtualClock
+	simConfig    SimConfig
+	random       *rand.Rand
+
+	edgeNodes   []*model.Node
+	cloudNodes  []*model.Node
+	deployments map[int]*simDeployment
+	pods        map[int]*SimPod
+	lastPodId   int
+
+	// nil until the events are watched
+	eventStream chan *Event
+}
+
+func NewSimConnector(clusterState *model.ClusterState, simConfig SimConfig) *SimConnector {
+	return &SimConnector{
+		clusterState: clusterState,
+		clock:        NewVirtualClock(),
+		simConfig:    simConfig,
+		random:       rand.New(rand.NewSource(simConfig.Seed)),
+		deployments:  make(map[int]*simDeployment),
+		pods:         make(map[int]*SimPod),
+	}
+}
+
+// Returns the clock that drives the simulated control plane.
+func (c *SimConnector) Clock() *VirtualClock {
+	return c.clock
+}
+
+// Adds a node to the simulated cluster, where is either "edge" or "cloud".
+// Nodes should be added before the scheduler finds them.
+func (c *SimConnector) AddNode(node *model.Node, where string) {
+	if where == "cloud" {
+		c.cloudNodes = append(c.cloudNodes, node)
+	} else {
+		c.edgeNodes = append(c.edgeNodes, node)
+	}
+}
+
+// Adds a deployment to the simulated cluster, its pods are created
+// as the clock goes. Deployments should be added before the scheduler
+// finds them.
+func (c *SimConnector) AddDeployment(deployment *model.Deployment, replicas int) {
+	c.deployments[deployment.Id] = &simDeployment{
+		deployment: deployment,
+		replicas:   replicas,
+	}
+	c.reconcile(deployment.Id)
+}
+
+// Changes the number of the deployment's pods,
+// extra pods are deleted, unbound ones first.
+func (c *SimConnector) Scale(deploymentId int, replicas int) error {
+	deployment, ok := c.deployments[deploymentId]
+	if !ok {
+		return fmt.Errorf("there is no deployment %d", deploymentId)
+	}
+
+	deployment.replicas = replicas
+	c.reconcile(deploymentId)
+
+	return nil
+}
+
+// Changes the faults injected in the events from now on.
+func (c *SimConnector) SetFaults(faults SimFaults) {
+	c.simConfig.Faults = faults
+}
+
+// Returns the pods of the simulated cluster, ordered by their ids.
+func (c *SimConnector) Pods() []SimPod {
+	ret := make([]SimPod, 0)
+	for _, pod := range c.sortedPods() {
+		ret = append(ret, *pod)
+	}
+
+	return ret
+}
+
+func (c *SimConnector) sortedPods() []*SimPod {
+	ret := make([]*SimPod, 0)
+	for _, pod := range c.pods {
+		ret = append(ret, pod)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Id < ret[j].Id
+	})
+
+	return ret
+}
+
+// Creates or deletes the deployment's pods like a ReplicaSet,
+// so it has as many pods (not being deleted) as its replicas.
+func (c *SimConnector) reconcile(deploymentId int) {
+	deployment := c.deployments[deploymentId]
+
+	var alive []*SimPod
+	for _, pod := range c.sortedPods() {
+		if pod.Deployment.Id == deploymentId && !pod.Terminating {
+			alive = append(alive, pod)
+		}
+	}
+
+	for missing := deployment.replicas - len(alive) - deployment.creating; missing > 0; missing-- {
+		deployment.creating++
+		c.clock.AfterFunc(c.simConfig.CreateLatency, func() {
+			deployment.creating--
+
+			pod := &SimPod{
+				Id:         c.lastPodId,
+				Deployment: deployment.deployment,
+				NodeId:     -1,
+				Status:     model.SCHEDULED,
+			}
+			c.lastPodId++
+			c.pods[pod.Id] = pod
+
+			log.Info().Msgf("sim: created pod %d of deployment %d", pod.Id, deploymentId)
+			c.emit(POD_CREATED, pod)
+
+			// The replicas may have been decreased meanwhile.
+			c.reconcile(deploymentId)
+		})
+	}
+
+	// Pods being created are checked again when they are created.
+	extra := len(alive) - deployment.replicas
+	if extra <= 0 {
+		return
+	}
+
+	// Unbound pods first, then the newest ones.
+	sort.SliceStable(alive, func(i, j int) bool {
+		if (alive[i].NodeId == -1) != (alive[j].NodeId == -1) {
+			return alive[i].NodeId == -1
+		}
+		return alive[i].Id > alive[j].Id
+	})
+	for ind := 0; ind < extra; ind++ {
+		c.terminate(alive[ind])
+	}
+}
+
+// Deletes the pod after the delete latency.
+func (c *SimConnector) terminate(pod *SimPod) {
+	pod.Terminating = true
+
+	c.clock.AfterFunc(c.simConfig.DeleteLatency, func() {
+		delete(c.pods, pod.Id)
+
+		log.Info().Msgf("sim: deleted pod %d", pod.Id)
+		c.emit(POD_DELETED, pod)
+	})
+}
+
+// Sends the event of the pod's current state through
+// the event stream, after the watch latency and faults.
+func (c *SimConnector) emit(eventType EventType, pod *SimPod) {
+	if c.eventStream == nil {
+		return
+	}
+
+	event := simEvent{
+		eventType:    eventType,
+		podId:        pod.Id,
+		deploymentId: pod.Deployment.Id,
+		nodeId:       pod.NodeId,
+		status:       pod.Status,
+	}
+
+	faults := c.simConfig.Faults
+	if c.random.Float64() < faults.DropRate {
+		log.Info().Msgf("sim: dropped an event of pod %d", pod.Id)
+		return
+	}
+
+	copies := 1
+	if c.random.Float64() < faults.DuplicateRate {
+		log.Info().Msgf("sim: duplicated an event of pod %d", pod.Id)
+		copies = 2
+	}
+
+	for i := 0; i < copies; i++ {
+		delay := c.simConfig.WatchLatency
+		if c.random.Float64() < faults.ReorderRate {
+			delay += time.Duration(c.random.Int63n(int64(faults.ReorderDelay) + 1))
+		}
+
+		c.clock.AfterFunc(delay, func() {
+			c.deliver(event)
+		})
+	}
+}
+
+// Translates the event like the kubernetes connector does.
+func (c *SimConnector) deliver(simEvent simEvent) {
+	if c.eventStream == nil {
+		// The watch is closed meanwhile.
+		return
+	}
+
+	deployment, ok := c.clusterState.Edge.Config.DeploymentIdToDeployment[simEvent.deploymentId]
+	if !ok {
+		log.Info().Msgf("some pod event has happened for deployment %d not related to scheduler.", simEvent.deploymentId)
+		return
+	}
+
+	// The scheduler uses its own pod if it knows the pod already.
+	pod := &model.Pod{
+		Id:         simEvent.podId,
+		Deployment: deployment,
+	}
+
+	var node *model.Node
+	if simEvent.nodeId != -1 {
+		node, ok = c.clusterState.GetNodeIdToNode()[simEvent.nodeId]
+		if !ok {
+			log.Warn().Msgf("pod's node (%d) is not registered, ignoring the event.", simEvent.nodeId)
+			return
+		}
+	}
+
+	c.eventStream <- &Event{
+		EventType: simEvent.eventType,
+		Pod:       pod,
+		Node:      node,
+		Status:    simEvent.status,
+	}
+}
+
+func (c *SimConnector) findNode(nodeId int) (*model.Node, bool) {
+	for _, node := range append(append([]*model.Node{}, c.edgeNodes...), c.cloudNodes...) {
+		if node.Id == nodeId {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *SimConnector) FindNodes() error {
+	for _, node := range c.edgeNodes {
+		c.clusterState.AddNode(node, "edge")
+	}
+	for _, node := range c.cloudNodes {
+		c.clusterState.AddNode(node, "cloud")
+	}
+
+	return nil
+}
+
+func (c *SimConnector) FindDeployments() error {
+	ids := make([]int, 0)
+	for id := range c.deployments {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		c.clusterState.Edge.Config.AddDeployment(c.deployments[id].deployment)
+	}
+
+	if _, err := c.SyncPods(); err != nil {
+		log.Err(err).Send()
+
+		return fmt.Errorf("couldn't sync pods")
+	}
+
+	return nil
+}
+
+func (c *SimConnector) GetClusterState() *model.ClusterState {
+	return c.clusterState
+}
+
+func (c *SimConnector) GetPendingPods() ([]*model.Pod, error) {
+	pendingPods := make([]*model.Pod, 0)
+
+	for _, simPod := range c.sortedPods() {
+		if simPod.NodeId != -1 || simPod.Terminating {
+			continue
+		}
+
+		pod, ok := c.clusterState.PodsMap[simPod.Id]
+		if !ok {
+			pod = &model.Pod{
+				Id:         simPod.Id,
+				Deployment: simPod.Deployment,
+				Status:     simPod.Status,
+			}
+			c.clusterState.PodsMap[pod.Id] = pod
+		}
+		pendingPods = append(pendingPods, pod)
+	}
+
+	return pendingPods, nil
+}
+
+func (c *SimConnector) SyncPods() ([]*model.Pod, error) {
+	allPods := make([]*model.Pod, 0)
+	for _, pod := range c.clusterState.PodsMap {
+		allPods = append(allPods, pod)
+	}
+	for _, pod := range allPods {
+		c.clusterState.RemovePod(pod)
+	}
+	for deploymentId := range c.clusterState.NumberOfRunningPods {
+		c.clusterState.NumberOfRunningPods[deploymentId] = 0
+	}
+
+	pendingPods := make([]*model.Pod, 0)
+	nodeIdToNode := c.clusterState.GetNodeIdToNode()
+
+	for _, simPod := range c.sortedPods() {
+		pod := &model.Pod{
+			Id:         simPod.Id,
+			Deployment: simPod.Deployment,
+			Status:     simPod.Status,
+		}
+
+		if simPod.NodeId == -1 {
+			c.clusterState.PodsMap[pod.Id] = pod
+			if !simPod.Terminating {
+				pendingPods = append(pendingPods, pod)
+			}
+			continue
+		}
+
+		node, ok := nodeIdToNode[simPod.NodeId]
+		if !ok {
+			log.Error().Msgf("found pod %d on node %d that is on neither cloud nor edge", simPod.Id, simPod.NodeId)
+			continue
+		}
+
+		if c.clusterState.IsCloudNode(node.Id) {
+			pod.Node = node
+			c.clusterState.DeployCloud(pod)
+		} else if err := c.clusterState.DeployEdge(pod, node); err != nil {
+			log.Err(err).Msgf("couldn't sync pod %d", pod.Id)
+			continue
+		}
+
+		if pod.Status == model.RUNNING {
+			c.clusterState.NumberOfRunningPods[pod.Deployment.Id] += 1
+		}
+	}
+
+	return pendingPods, nil
+}
+
+func (c *SimConnector) Deploy(pod *model.Pod, node *model.Node) error {
+	if node == nil {
+		return fmt.Errorf("cannot deploy a pod on a nil node")
+	}
+
+	simPod, ok := c.pods[pod.Id]
+	if !ok || simPod.Terminating {
+		return fmt.Errorf("the pod is not known")
+	}
+	if simPod.NodeId != -1 {
+		return fmt.Errorf("pod %d is already bound to node %d", pod.Id, simPod.NodeId)
+	}
+	simNode, ok := c.findNode(node.Id)
+	if !ok {
+		return fmt.Errorf("the pod's node is not mapped to a known node")
+	}
+	// Like kubelet, pods are rejected by the nodes they are not allowed on.
+	if !simPod.Deployment.CanRunOn(simNode) {
+		return fmt.Errorf("pod %d is not allowed on node %d", pod.Id, node.Id)
+	}
+
+	log.Info().Msgf("sim: deploying pod %d to node %d", pod.Id, node.Id)
+
+	c.clock.AfterFunc(c.simConfig.BindLatency, func() {
+		if c.pods[simPod.Id] != simPod || simPod.NodeId != -1 {
+			return
+		}
+		simPod.NodeId = node.Id
+		c.emit(POD_CHANGED, simPod)
+
+		c.clock.AfterFunc(c.simConfig.StartLatency, func() {
+			if c.pods[simPod.Id] != simPod || simPod.Terminating {
+				return
+			}
+			simPod.Status = model.RUNNING
+			c.emit(POD_CHANGED, simPod)
+		})
+	})
+
+	return nil
+}
+
+func (c *SimConnector) DeletePod(pod *model.Pod) (bool, error) {
+	simPod, ok := c.pods[pod.Id]
+	if !ok || simPod.Terminating {
+		return false, nil
+	}
+
+	c.terminate(simPod)
+	c.reconcile(simPod.Deployment.Id)
+
+	return true, nil
+}
+
+func (c *SimConnector) WatchSchedulingEvents() (<-chan *Event, error) {
+	c.eventStream = make(chan *Event, SIM_EVENT_BUFFER_SIZE)
+
+	return c.eventStream, nil
+}
+
+func (c *SimConnector) CloseWatch() {
+	if c.eventStream == nil {
+		return
+	}
+
+	close(c.eventStream)
+	c.eventStream = nil
+}
@@ -0,0 +1,92 @@
+package connector
+
+import (
+	"container/heap"
+	"time"
+)
+
+// A clock whose time only moves when it is stepped, it runs
+// the functions scheduled on it in the order of their times,
+// and in the order of being scheduled for equal times, so
+// everything driven by it is deterministic.
+type VirtualClock struct {
+	now    time.Duration
+	timers timerHeap
+	// number of functions scheduled so far, for ordering them
+	scheduled int
+}
+
+type timer struct {
+	at  time.Duration
+	seq int
+	f   func()
+}
+
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].at != h[j].at {
+		return h[i].at < h[j].at
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h timerHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *timerHeap) Push(x any) { *h = append(*h, x.(*timer)) }
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	ret := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return ret
+}
+
+func NewVirtualClock() *VirtualClock {
+	return &VirtualClock{}
+}
+
+// Returns the time passed since the clock's start.
+func (clock *VirtualClock) Now() time.Duration {
+	return clock.now
+}
+
+// Schedules f to be run when the clock reaches d after now.
+func (clock *VirtualClock) AfterFunc(d time.Duration, f func()) {
+	if d < 0 {
+		d = 0
+	}
+
+	heap.Push(&clock.timers, &timer{
+		at:  clock.now + d,
+		seq: clock.scheduled,
+		f:   f,
+	})
+	clock.scheduled++
+}
+
+// Returns the time of the earliest scheduled
+// function, false if nothing is scheduled.
+func (clock *VirtualClock) Next() (time.Duration, bool) {
+	if len(clock.timers) == 0 {
+		return 0, false
+	}
+
+	return clock.timers[0].at, true
+}
+
+// Moves the clock to the earliest scheduled function and runs
+// it, returns false if nothing is scheduled.
+func (clock *VirtualClock) Step() bool {
+	if len(clock.timers) == 0 {
+		return false
+	}
+
+	next := heap.Pop(&clock.timers).(*timer)
+	clock.now = next.at
+	next.f()
+
+	return true
+}
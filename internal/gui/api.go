@@ -0,0 +1,361 @@
+package gui
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/amsen20/ecmus/alg"
+	"github.com/amsen20/ecmus/internal/audit"
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Following are the JSON representations of the scheduler's
+// state, served under /api/v1.
+
+// Amount of each resource by the resource's name,
+// null if the amount is unlimited (like on cloud).
+type resourcesView map[string]*float64
+
+type nodeView struct {
+	Id        int           `json:"id"`
+	Location  string        `json:"location"`
+	Resources resourcesView `json:"resources"`
+	Used      resourcesView `json:"used"`
+	// used by the pods that the scheduler does not manage
+	Reserved resourcesView `json:"reserved,omitempty"`
+	Free     resourcesView `json:"free"`
+	Pods     []int         `json:"pods"`
+	Cost     float64       `json:"cost,omitempty"`
+}
+
+type deploymentView struct {
+	Id                int           `json:"id"`
+	Resources         resourcesView `json:"resources"`
+	EdgeShare         float64       `json:"edge_share"`
+	Priority          int32         `json:"priority"`
+	NumberOfPods      int           `json:"number_of_pods"`
+	NumberOfPodOnEdge int           `json:"number_of_pods_on_edge"`
+	QoS               float64       `json:"qos"`
+}
+
+type podView struct {
+	Id         int    `json:"id"`
+	Deployment int    `json:"deployment"`
+	Status     string `json:"status"`
+	// edge, cloud, pending or binding
+	Location string `json:"location"`
+	Node     *int   `json:"node"`
+}
+
+type expectationView struct {
+	Id   uint32 `json:"id"`
+	Type string `json:"type"`
+}
+
+type dryRunView struct {
+	Enabled    bool                          `json:"enabled"`
+	Plans      []scheduler.DryRunPlan        `json:"plans"`
+	Placements []scheduler.ObservedPlacement `json:"placements"`
+}
+
+type stateView struct {
+	QoS          float64           `json:"qos"`
+	Nodes        []nodeView        `json:"nodes"`
+	Deployments  []deploymentView  `json:"deployments"`
+	Pods         []podView         `json:"pods"`
+	Expectations []expectationView `json:"expectations"`
+}
+
+// Pod locations:
+const (
+	EDGE    = "edge"
+	CLOUD   = "cloud"
+	PENDING = "pending"
+	// bound but not confirmed yet
+	BINDING = "binding"
+)
+
+// Default number of audit records returned.
+const DEFAULT_AUDIT_LIMIT = 100
+
+var auditLog *audit.Log
+var snapshotRequestStream chan<- struct{}
+var snapshotStream <-chan *scheduler.Snapshot
+
+func registerAPIRoutes(group *gin.RouterGroup) {
+	group.GET("/state", func(ctx *gin.Context) {
+		snapshot, ok := getSnapshot(ctx)
+		if !ok {
+			return
+		}
+
+		state, err := newStateView(snapshot)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, state)
+	})
+
+	group.GET("/nodes", func(ctx *gin.Context) {
+		snapshot, ok := getSnapshot(ctx)
+		if !ok {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, newNodeViews(snapshot.ClusterState))
+	})
+
+	group.GET("/deployments", func(ctx *gin.Context) {
+		snapshot, ok := getSnapshot(ctx)
+		if !ok {
+			return
+		}
+
+		deployments, _, err := newDeploymentViews(snapshot.ClusterState)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, deployments)
+	})
+
+	group.GET("/dry-run", func(ctx *gin.Context) {
+		snapshot, ok := getSnapshot(ctx)
+		if !ok {
+			return
+		}
+
+		view := dryRunView{
+			Enabled:    config.SchedulerGeneralConfig.DryRun,
+			Plans:      make([]scheduler.DryRunPlan, 0),
+			Placements: make([]scheduler.ObservedPlacement, 0),
+		}
+		view.Plans = append(view.Plans, snapshot.DryRunPlans...)
+		view.Placements = append(view.Placements, snapshot.ObservedPlacements...)
+
+		ctx.JSON(http.StatusOK, view)
+	})
+
+	group.GET("/audit", func(ctx *gin.Context) {
+		limit, err := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(DEFAULT_AUDIT_LIMIT)))
+		if err != nil || limit < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit should be a non-negative number"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, auditLog.Latest(limit))
+	})
+
+	group.GET("/audit/pods/:id", func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "pod id should be a number"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, auditLog.ForPod(id))
+	})
+
+	group.GET("/pods/:id", func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "pod id should be a number"})
+			return
+		}
+
+		snapshot, ok := getSnapshot(ctx)
+		if !ok {
+			return
+		}
+
+		for _, pod := range newPodViews(snapshot) {
+			if pod.Id == id {
+				ctx.JSON(http.StatusOK, pod)
+				return
+			}
+		}
+
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "pod not found"})
+	})
+}
+
+// Returns the scheduler's snapshot, or responds with 503
+// and returns false if the scheduler is not available.
+func getSnapshot(ctx *gin.Context) (*scheduler.Snapshot, bool) {
+	snapshot, err := askScheduler(ctx.Request.Context(), snapshotRequestStream, snapshotStream)
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return snapshot, true
+}
+
+func newStateView(snapshot *scheduler.Snapshot) (stateView, error) {
+	deployments, score, err := newDeploymentViews(snapshot.ClusterState)
+	if err != nil {
+		return stateView{}, err
+	}
+
+	ret := stateView{
+		QoS:          score,
+		Nodes:        newNodeViews(snapshot.ClusterState),
+		Deployments:  deployments,
+		Pods:         newPodViews(snapshot),
+		Expectations: make([]expectationView, 0),
+	}
+	for _, expectation := range snapshot.Expectations {
+		ret.Expectations = append(ret.Expectations, expectationView{
+			Id:   expectation.Id,
+			Type: expectation.Type,
+		})
+	}
+
+	return ret, nil
+}
+
+func newNodeViews(clusterState *model.ClusterState) []nodeView {
+	ret := make([]nodeView, 0)
+
+	for _, node := range clusterState.Edge.Config.Nodes {
+		view := nodeView{
+			Id:        node.Id,
+			Location:  EDGE,
+			Resources: newResourcesView(node.Resources),
+			Used:      newResourcesView(clusterState.NodeResourcesUsed[node.Id]),
+			Reserved:  newResourcesView(clusterState.NodeResourcesReserved[node.Id]),
+			Free:      newResourcesView(clusterState.GetNodesResourcesRemained()[node.Id]),
+			Pods:      make([]int, 0),
+		}
+		for _, pod := range clusterState.Edge.Pods {
+			if pod.Node != nil && pod.Node.Id == node.Id {
+				view.Pods = append(view.Pods, pod.Id)
+			}
+		}
+
+		ret = append(ret, view)
+	}
+
+	for _, node := range clusterState.Cloud.Nodes {
+		view := nodeView{
+			Id:        node.Id,
+			Location:  CLOUD,
+			Resources: newResourcesView(node.Resources),
+			Used:      newResourcesView(clusterState.Cloud.ResourcesUsed[node.Id]),
+			Pods:      make([]int, 0),
+			Cost:      node.Cost,
+		}
+		for _, pod := range clusterState.Cloud.Pods {
+			if pod.Node != nil && pod.Node.Id == node.Id {
+				view.Pods = append(view.Pods, pod.Id)
+			}
+		}
+
+		ret = append(ret, view)
+	}
+
+	return ret
+}
+
+// Returns the deployments with their QoS and the QoS score of the cluster.
+func newDeploymentViews(clusterState *model.ClusterState) ([]deploymentView, float64, error) {
+	qosResult, err := alg.CalcNumberOfQosSatisfactions(clusterState.Edge.Config, clusterState.Cloud.Pods, clusterState.Edge.Pods, nil, nil)
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, 0, err
+	}
+
+	ret := make([]deploymentView, 0)
+	for _, deployment := range clusterState.Edge.Config.Deployments {
+		view := deploymentView{
+			Id:        deployment.Id,
+			Resources: newResourcesView(deployment.ResourcesRequired),
+			EdgeShare: deployment.EdgeShare,
+			Priority:  deployment.Priority,
+		}
+		if info, ok := qosResult.DeploymentsQoS[deployment.Id]; ok {
+			view.NumberOfPods = info.NumberOfPods
+			view.NumberOfPodOnEdge = info.NumberOfPodOnEdge
+			view.QoS = alg.QoS(info.EffectiveShare(), deployment.EdgeShare)
+		}
+
+		ret = append(ret, view)
+	}
+
+	return ret, qosResult.Score, nil
+}
+
+func newPodViews(snapshot *scheduler.Snapshot) []podView {
+	ret := make([]podView, 0)
+
+	for _, iter := range []struct {
+		location string
+		pods     []*model.Pod
+	}{
+		{location: EDGE, pods: snapshot.ClusterState.Edge.Pods},
+		{location: CLOUD, pods: snapshot.ClusterState.Cloud.Pods},
+		{location: PENDING, pods: snapshot.PendingPods},
+		{location: BINDING, pods: snapshot.BindingPods},
+	} {
+		for _, pod := range iter.pods {
+			view := podView{
+				Id:         pod.Id,
+				Deployment: pod.Deployment.Id,
+				Status:     podStatusToString(pod.Status),
+				Location:   iter.location,
+			}
+			if pod.Node != nil {
+				nodeId := pod.Node.Id
+				view.Node = &nodeId
+			}
+
+			ret = append(ret, view)
+		}
+	}
+
+	return ret
+}
+
+func newResourcesView(resources *mat.VecDense) resourcesView {
+	ret := make(resourcesView)
+	if resources == nil {
+		return ret
+	}
+
+	for ind, resource := range config.SchedulerGeneralConfig.Resources {
+		if ind >= resources.Len() {
+			break
+		}
+
+		value := resources.AtVec(ind)
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			ret[resource.Name] = nil
+		} else {
+			ret[resource.Name] = &value
+		}
+	}
+
+	return ret
+}
+
+func podStatusToString(status model.PodStatus) string {
+	switch status {
+	case model.SCHEDULED:
+		return "scheduled"
+	case model.RUNNING:
+		return "running"
+	case model.FINISHED:
+		return "finished"
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,148 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+	"github.com/amsen20/ecmus/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+func setUpAPI(t *testing.T, snapshot *scheduler.Snapshot) *gin.Engine {
+	requests := make(chan struct{})
+	snapshots := make(chan *scheduler.Snapshot)
+	snapshotRequestStream = requests
+	snapshotStream = snapshots
+
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case <-requests:
+				snapshots <- snapshot
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	registerAPIRoutes(engine.Group("/api/v1"))
+
+	return engine
+}
+
+func get(t *testing.T, engine *gin.Engine, path string, wantedCode int, result interface{}) {
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	if recorder.Code != wantedCode {
+		t.Fatalf("GET %s returned %d, wanted %d: %s", path, recorder.Code, wantedCode, recorder.Body.String())
+	}
+	if result != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf("GET %s returned invalid JSON: %v", path, err)
+		}
+	}
+}
+
+func TestAPI(t *testing.T) {
+	yamlFile, err := os.ReadFile("../../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(yamlFile); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 0.5},
+	})
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 4}: {"A"},
+		},
+		[]string{"A"},
+	)
+	pendingPods := builder.GetPods([]string{"A"})
+	bindingPods := builder.GetPods([]string{"A"})
+
+	engine := setUpAPI(t, &scheduler.Snapshot{
+		ClusterState: clusterState,
+		PendingPods:  pendingPods,
+		BindingPods:  bindingPods,
+		Expectations: []scheduler.ExpectationSnapshot{{Id: 7, Type: "placing"}},
+	})
+
+	var state stateView
+	get(t, engine, "/api/v1/state", http.StatusOK, &state)
+	if len(state.Nodes) != 2 || len(state.Deployments) != 1 || len(state.Pods) != 4 || len(state.Expectations) != 1 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	var nodes []nodeView
+	get(t, engine, "/api/v1/nodes", http.StatusOK, &nodes)
+	for _, node := range nodes {
+		switch node.Location {
+		case EDGE:
+			if *node.Free["cpu"] != 1 || *node.Used["memory"] != 1 || len(node.Pods) != 1 {
+				t.Fatalf("unexpected edge node %+v", node)
+			}
+		case CLOUD:
+			if node.Resources["cpu"] != nil {
+				t.Fatalf("cloud resources should be unlimited")
+			}
+		}
+	}
+
+	var deployments []deploymentView
+	get(t, engine, "/api/v1/deployments", http.StatusOK, &deployments)
+	if deployments[0].NumberOfPods != 2 || deployments[0].NumberOfPodOnEdge != 1 || deployments[0].QoS != 0.99 {
+		t.Fatalf("unexpected deployment %+v", deployments[0])
+	}
+
+	var pod podView
+	get(t, engine, "/api/v1/pods/"+strconv.Itoa(pendingPods[0].Id), http.StatusOK, &pod)
+	if pod.Location != PENDING || pod.Node != nil {
+		t.Fatalf("unexpected pod %+v", pod)
+	}
+	get(t, engine, "/api/v1/pods/"+strconv.Itoa(bindingPods[0].Id), http.StatusOK, &pod)
+	if pod.Location != BINDING || pod.Node != nil {
+		t.Fatalf("unexpected pod %+v", pod)
+	}
+	get(t, engine, "/api/v1/pods/"+strconv.Itoa(clusterState.Edge.Pods[0].Id), http.StatusOK, &pod)
+	if pod.Location != EDGE || pod.Node == nil || pod.Status != podStatusToString(model.RUNNING) {
+		t.Fatalf("unexpected pod %+v", pod)
+	}
+
+	get(t, engine, "/api/v1/pods/1000", http.StatusNotFound, nil)
+	get(t, engine, "/api/v1/pods/abc", http.StatusBadRequest, nil)
+}
+
+func TestAPIWithStoppedScheduler(t *testing.T) {
+	stopped := make(chan struct{})
+	close(stopped)
+	schedulerStopped = stopped
+	t.Cleanup(func() { schedulerStopped = nil })
+
+	// Nobody answers the requests after the scheduler stops.
+	snapshotRequestStream = make(chan struct{})
+	snapshotStream = make(chan *scheduler.Snapshot)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	registerAPIRoutes(engine.Group("/api/v1"))
+
+	get(t, engine, "/api/v1/state", http.StatusServiceUnavailable, nil)
+	get(t, engine, "/api/v1/pods/1", http.StatusServiceUnavailable, nil)
+}
@@ -4,37 +4,114 @@
 // The gui sends an empty struct to scheduler bridge
 // and the scheduler sends back a clone of state
 // to gui which displays it using a simple HTML file.
+// It also exposes the scheduler's metrics for prometheus
+// and the scheduler's state as JSON under /api/v1.
 package gui
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/amsen20/ecmus/internal/model"
 	"github.com/amsen20/ecmus/internal/scheduler"
+	"github.com/amsen20/ecmus/logging"
+	"github.com/amsen20/ecmus/statistics"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// Content type of prometheus' text exposition format.
+const METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Longest time a request waits for the scheduler's answer.
+const SCHEDULER_RESPONSE_TIMEOUT = 5 * time.Second
+
+var errSchedulerUnavailable = errors.New("the scheduler is not available")
+
+var log = logging.Get()
+
 var clusterStateRequestStream chan<- struct{}
 var clusterStateStream <-chan *model.ClusterState
 var router *gin.Engine
 
+// closed once the scheduler stops
+var schedulerStopped <-chan struct{}
+
+// Only one request is sent to the scheduler at a time,
+// so each request gets its own answer.
+var schedulerRequestLock sync.Mutex
+
+// Sends a request to the scheduler over the bridge and returns its answer,
+// or an error if the scheduler stopped or did not answer in time.
+func askScheduler[T any](ctx context.Context, requests chan<- struct{}, answers <-chan T) (T, error) {
+	schedulerRequestLock.Lock()
+	defer schedulerRequestLock.Unlock()
+
+	// Dropping the answers of the requests that were given up.
+	for len(answers) > 0 {
+		<-answers
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, SCHEDULER_RESPONSE_TIMEOUT)
+	defer cancel()
+
+	var answer T
+	select {
+	case requests <- struct{}{}:
+	case <-schedulerStopped:
+		return answer, errSchedulerUnavailable
+	case <-ctx.Done():
+		return answer, errSchedulerUnavailable
+	}
+
+	select {
+	case answer = <-answers:
+		return answer, nil
+	case <-schedulerStopped:
+		return answer, errSchedulerUnavailable
+	case <-ctx.Done():
+		return answer, errSchedulerUnavailable
+	}
+}
+
 func registerRoutes() {
 	router.POST("/state", func(ctx *gin.Context) {
-		clusterStateRequestStream <- struct{}{}
+		clusterState, err := askScheduler(ctx.Request.Context(), clusterStateRequestStream, clusterStateStream)
+		if err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
 		ctx.JSON(http.StatusOK, gin.H{
-			"content": (<-clusterStateStream).Display(),
+			"content": clusterState.Display(),
 		})
 	})
 
 	router.GET("/", func(ctx *gin.Context) {
 		ctx.HTML(http.StatusOK, "index.html", gin.H{})
 	})
+
+	registerAPIRoutes(router.Group("/api/v1"))
+
+	router.GET("/metrics", func(ctx *gin.Context) {
+		ctx.Header("Content-Type", METRICS_CONTENT_TYPE)
+		ctx.Status(http.StatusOK)
+		if err := statistics.Write(ctx.Writer); err != nil {
+			log.Err(err).Send()
+		}
+	})
 }
 
 func SetUp(bridge scheduler.SchedulerBridge) {
 	clusterStateStream = bridge.ClusterStateStream
 	clusterStateRequestStream = bridge.ClusterStateRequestStream
+	snapshotStream = bridge.SnapshotStream
+	auditLog = bridge.AuditLog
+	snapshotRequestStream = bridge.SnapshotRequestStream
+	schedulerStopped = bridge.Stopped
 
 	router = gin.Default()
 	router.LoadHTMLFiles("./internal/gui/index.html")
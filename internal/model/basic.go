@@ -22,15 +22,45 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// The edge share used for deployments that
+// do not declare their own.
+const DEFAULT_EDGE_SHARE = 1
+
 type Deployment struct {
 	Id                int
 	ResourcesRequired *mat.VecDense
 	EdgeShare         float64
+	// Fraction of the deployment's users in each zone,
+	// the users are near every edge node if it is empty.
+	UserZones map[string]float64
+	// Priority of the deployment's pods, pods of lower priorities
+	// never displace the pods of higher ones on edge.
+	Priority int32
+	// The nodes that the deployment's pods are allowed on.
+	Constraints Constraints
+	// Labels of the deployment's pods.
+	Labels map[string]string
+	Spread Spread
+	// nil if the deployment's pods have no disruption budget.
+	DisruptionBudget *DisruptionBudget
 }
 
 type Node struct {
 	Id        int           `yaml:"id"`
 	Resources *mat.VecDense `yaml:"resources"`
+	// The zone (or site) of the node.
+	Zone string `yaml:"zone"`
+	// Measured round trip times (ms) from the users of each
+	// zone to the node, the users of the node's zone are
+	// assumed to have no latency if it is not measured.
+	Latencies map[string]float64 `yaml:"latencies"`
+	// Cost of running the whole node, a pod costs the
+	// node's cost times its dominant share of the node.
+	// It is only used for cloud nodes.
+	Cost float64 `yaml:"cost"`
+	// Used to check the deployments' constraints on the node.
+	Labels map[string]string `yaml:"labels"`
+	Taints []Taint           `yaml:"taints"`
 }
 
 type PodStatus int
@@ -48,6 +78,29 @@ type Pod struct {
 	Deployment *Deployment `yaml:"deployment"`
 	Node       *Node       `yaml:"node"`
 	Status     PodStatus   `yaml:"status"`
+	// The group that the pod is placed together with, nil if it has none.
+	Group *PodGroup `yaml:"group,omitempty"`
+}
+
+// Pods of a group, which may be of different deployments, are placed
+// together, all on edge or all on cloud.
+type PodGroup struct {
+	Name string `yaml:"name"`
+	// Number of the group's pods, the group is placed when all of
+	// them are pending, or when any of them is if it is zero.
+	Size int `yaml:"size"`
+}
+
+// Returns the pods of each group among the pods, by the groups' names.
+func PodsByGroup(pods []*Pod) map[string][]*Pod {
+	ret := make(map[string][]*Pod)
+	for _, pod := range pods {
+		if pod.Group != nil {
+			ret[pod.Group.Name] = append(ret[pod.Group.Name], pod)
+		}
+	}
+
+	return ret
 }
 
 // Followings are some methods for representing
@@ -55,23 +108,45 @@ type Pod struct {
 
 func (deployment *Deployment) MarshalYAML() (interface{}, error) {
 	return &struct {
-		Id                int     `yaml:"id"`
-		ResourcesRequired string  `yaml:"resources"`
-		EdgeShare         float64 `yaml:"edge_share"`
+		Id                int                `yaml:"id"`
+		ResourcesRequired string             `yaml:"resources"`
+		EdgeShare         float64            `yaml:"edge_share"`
+		UserZones         map[string]float64 `yaml:"user_zones,omitempty"`
+		Priority          int32              `yaml:"priority,omitempty"`
+		Constraints       Constraints        `yaml:"constraints,omitempty"`
+		Labels            map[string]string  `yaml:"labels,omitempty"`
+		Spread            Spread             `yaml:"spread,omitempty"`
+		DisruptionBudget  *DisruptionBudget  `yaml:"disruption_budget,omitempty"`
 	}{
 		Id:                deployment.Id,
 		ResourcesRequired: utils.ToString(deployment.ResourcesRequired),
 		EdgeShare:         deployment.EdgeShare,
+		UserZones:         deployment.UserZones,
+		Priority:          deployment.Priority,
+		Constraints:       deployment.Constraints,
+		Labels:            deployment.Labels,
+		Spread:            deployment.Spread,
+		DisruptionBudget:  deployment.DisruptionBudget,
 	}, nil
 }
 
 func (node *Node) MarshalYAML() (interface{}, error) {
 	return &struct {
-		Id        int    `yaml:"id"`
-		Resources string `yaml:"resources"`
+		Id        int                `yaml:"id"`
+		Resources string             `yaml:"resources"`
+		Zone      string             `yaml:"zone,omitempty"`
+		Latencies map[string]float64 `yaml:"latencies,omitempty"`
+		Cost      float64            `yaml:"cost,omitempty"`
+		Labels    map[string]string  `yaml:"labels,omitempty"`
+		Taints    []Taint            `yaml:"taints,omitempty"`
 	}{
 		Id:        node.Id,
 		Resources: utils.ToString(node.Resources),
+		Zone:      node.Zone,
+		Latencies: node.Latencies,
+		Cost:      node.Cost,
+		Labels:    node.Labels,
+		Taints:    node.Taints,
 	}, nil
 }
 
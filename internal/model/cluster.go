@@ -3,7 +3,6 @@ package model
 import (
 	"fmt"
 	"math"
-	"math/rand"
 
 	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/utils"
@@ -31,11 +30,14 @@ type EdgeState struct {
 }
 
 // Stores dynamic and static properties of the cloud.
-// Scheduler assumption is that all cloud nodes are symmetric
-// and so schedulers the pod on them randomly.
+// Cloud nodes have limited resources and different costs,
+// each pod is placed on the cheapest node that it fits in.
 type CloudState struct {
 	Nodes []*Node
 	Pods  []*Pod
+
+	// amount of resource used for each cloud node
+	ResourcesUsed map[int]*mat.VecDense
 }
 
 // The whole state of the cluster in
@@ -49,6 +51,9 @@ type ClusterState struct {
 
 	// amount of resource used for all nodes
 	NodeResourcesUsed map[int]*mat.VecDense
+	// amount of resource of each edge node used by the pods that the scheduler
+	// does not manage, it is in NodeResourcesUsed and Edge.UsedResources too
+	NodeResourcesReserved map[int]*mat.VecDense
 	// a mapping from pod id to pod
 	PodsMap map[int]*Pod
 	// number of running pods for each deployment
@@ -76,19 +81,24 @@ func newEdgeState() *EdgeState {
 
 func NewClusterState() *ClusterState {
 	return &ClusterState{
-		Edge:                newEdgeState(),
-		Cloud:               &CloudState{},
-		PodsMap:             make(map[int]*Pod),
-		NodeResourcesUsed:   make(map[int]*mat.VecDense),
-		NumberOfRunningPods: make(map[int]int),
-		shouldLog:           true,
+		Edge:                  newEdgeState(),
+		Cloud:                 &CloudState{ResourcesUsed: make(map[int]*mat.VecDense)},
+		PodsMap:               make(map[int]*Pod),
+		NodeResourcesUsed:     make(map[int]*mat.VecDense),
+		NodeResourcesReserved: make(map[int]*mat.VecDense),
+		NumberOfRunningPods:   make(map[int]int),
+		shouldLog:             true,
 	}
 }
 
-// Following methods are for building scheduler assumption of
-// cluster's static properties, if this methods are called
-// in the middle of scheduler's execution it may cause unexpected
-// behavior.
+// Following methods are for building and changing scheduler's
+// assumption of cluster's static properties.
+// They can be called in the middle of scheduler's execution,
+// the pods which are affected by removing or shrinking
+// a node are evicted to cloud in the scheduler's point of view
+// and are returned, so the scheduler can re-plan.
+// The node and deployment objects are never mutated, they are
+// replaced instead, so clones of the state keep a consistent view.
 
 func (ec *EdgeConfig) AddDeployment(deployment *Deployment) bool {
 
@@ -102,10 +112,89 @@ func (ec *EdgeConfig) AddDeployment(deployment *Deployment) bool {
 	return true
 }
 
+// Replaces the deployment having the same id with the given one
+// and points all of its pods to the new object.
+// If the required resources have changed, the deployment's
+// edge pods are re-deployed on their nodes and the ones
+// that do not fit anymore are evicted to cloud.
+func (c *ClusterState) UpdateDeployment(deployment *Deployment) ([]*Pod, bool) {
+	oldDeployment, ok := c.Edge.Config.DeploymentIdToDeployment[deployment.Id]
+	if !ok {
+		return nil, false
+	}
+
+	deployments := make([]*Deployment, 0, len(c.Edge.Config.Deployments))
+	for _, configDeployment := range c.Edge.Config.Deployments {
+		if configDeployment.Id == deployment.Id {
+			configDeployment = deployment
+		}
+		deployments = append(deployments, configDeployment)
+	}
+	c.Edge.Config.Deployments = deployments
+	c.Edge.Config.DeploymentIdToDeployment[deployment.Id] = deployment
+
+	var evictedPods []*Pod
+	for _, pod := range c.getPodsOfDeployment(oldDeployment.Id) {
+		node := pod.Node
+		if !c.RemovePodEdge(pod) {
+			// Cloud pods stay on their nodes with the new size.
+			isCloud := c.RemovePodCloud(pod)
+			pod.Deployment = deployment
+			if isCloud {
+				pod.Node = node
+				c.DeployCloud(pod)
+			}
+			continue
+		}
+
+		pod.Deployment = deployment
+		if err := c.DeployEdge(pod, node); err != nil {
+			c.DeployCloud(pod)
+			evictedPods = append(evictedPods, pod)
+		}
+	}
+
+	if c.shouldLog {
+		log.Info().Msgf("updated deployment %d, %d pods evicted", deployment.Id, len(evictedPods))
+	}
+
+	return evictedPods, true
+}
+
+// Removes the deployment and all of its pods
+// from the cluster state, the removed pods are returned.
+func (c *ClusterState) RemoveDeployment(deploymentId int) ([]*Pod, bool) {
+	if _, ok := c.Edge.Config.DeploymentIdToDeployment[deploymentId]; !ok {
+		return nil, false
+	}
+
+	removedPods := c.getPodsOfDeployment(deploymentId)
+	for _, pod := range removedPods {
+		c.RemovePod(pod)
+	}
+
+	deployments := make([]*Deployment, 0, len(c.Edge.Config.Deployments))
+	for _, deployment := range c.Edge.Config.Deployments {
+		if deployment.Id != deploymentId {
+			deployments = append(deployments, deployment)
+		}
+	}
+	c.Edge.Config.Deployments = deployments
+	delete(c.Edge.Config.DeploymentIdToDeployment, deploymentId)
+	delete(c.NumberOfRunningPods, deploymentId)
+
+	if c.shouldLog {
+		log.Info().Msgf("removed deployment %d with %d pods", deploymentId, len(removedPods))
+	}
+
+	return removedPods, true
+}
+
 func (c *ClusterState) AddNode(n *Node, where string) {
 
 	if where == "cloud" {
 		c.Cloud.Nodes = append(c.Cloud.Nodes, n)
+		c.Cloud.ResourcesUsed[n.Id] = mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
 
 		if c.shouldLog {
 			log.Info().Msg("added to cloud")
@@ -116,12 +205,196 @@ func (c *ClusterState) AddNode(n *Node, where string) {
 	utils.SAddVec(c.Edge.Config.Resources, n.Resources)
 
 	c.NodeResourcesUsed[n.Id] = mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
+	c.NodeResourcesReserved[n.Id] = mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
 
 	if c.shouldLog {
 		log.Info().Msg("added to edge")
 	}
 }
 
+// Sets the resources of the edge node that are used by the pods
+// the scheduler does not manage. No pod is evicted if the node
+// gets overcommitted, it is full until those pods are gone.
+func (c *ClusterState) SetNodeReserved(nodeId int, reserved *mat.VecDense) bool {
+	oldReserved, ok := c.NodeResourcesReserved[nodeId]
+	if !ok {
+		return false
+	}
+
+	utils.SSubVec(c.NodeResourcesUsed[nodeId], oldReserved)
+	utils.SAddVec(c.NodeResourcesUsed[nodeId], reserved)
+	utils.SSubVec(c.Edge.UsedResources, oldReserved)
+	utils.SAddVec(c.Edge.UsedResources, reserved)
+	c.NodeResourcesReserved[nodeId] = mat.VecDenseCopyOf(reserved)
+
+	if c.shouldLog {
+		log.Info().Msgf("node %d has %s reserved", nodeId, utils.ToString(reserved))
+	}
+
+	return true
+}
+
+// Returns the edge resources used by the pods the scheduler
+// does not manage, they can not be freed by offloading.
+func (c *ClusterState) EdgeReservedResources() *mat.VecDense {
+	reserved := mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
+	for _, nodeReserved := range c.NodeResourcesReserved {
+		utils.SAddVec(reserved, nodeReserved)
+	}
+
+	return reserved
+}
+
+// Replaces the node having the same id with the given one,
+// if the node is shrunk the pods that do not fit
+// in it anymore are evicted to cloud and returned.
+func (c *ClusterState) UpdateNode(node *Node) ([]*Pod, bool) {
+	if c.IsCloudNode(node.Id) {
+		c.Cloud.Nodes = replaceNode(c.Cloud.Nodes, node)
+		for _, pod := range c.Cloud.Pods {
+			if pod.Node != nil && pod.Node.Id == node.Id {
+				pod.Node = node
+			}
+		}
+
+		return nil, true
+	}
+
+	oldNode, ok := c.getEdgeNode(node.Id)
+	if !ok {
+		return nil, false
+	}
+
+	c.Edge.Config.Nodes = replaceNode(c.Edge.Config.Nodes, node)
+	utils.SSubVec(c.Edge.Config.Resources, oldNode.Resources)
+	utils.SAddVec(c.Edge.Config.Resources, node.Resources)
+
+	var nodePods []*Pod
+	for _, pod := range c.Edge.Pods {
+		if pod.Node.Id == node.Id {
+			pod.Node = node
+			nodePods = append(nodePods, pod)
+		}
+	}
+
+	var evictedPods []*Pod
+	for i := len(nodePods) - 1; i >= 0; i-- {
+		if utils.LEThan(c.NodeResourcesUsed[node.Id], node.Resources) {
+			break
+		}
+
+		c.evictToCloud(nodePods[i])
+		evictedPods = append(evictedPods, nodePods[i])
+	}
+
+	if c.shouldLog {
+		log.Info().Msgf("updated node %d, %d pods evicted", node.Id, len(evictedPods))
+	}
+
+	return evictedPods, true
+}
+
+// Removes the node from the cluster state, all of
+// its pods are evicted to cloud and returned.
+func (c *ClusterState) RemoveNode(nodeId int) ([]*Pod, bool) {
+	if c.IsCloudNode(nodeId) {
+		c.Cloud.Nodes = removeNode(c.Cloud.Nodes, nodeId)
+		delete(c.Cloud.ResourcesUsed, nodeId)
+
+		var movedPods []*Pod
+		for _, pod := range c.Cloud.Pods {
+			if pod.Node != nil && pod.Node.Id == nodeId {
+				movedPods = append(movedPods, pod)
+			}
+		}
+		for _, pod := range movedPods {
+			c.RemovePodCloud(pod)
+			c.DeployCloud(pod)
+		}
+
+		return movedPods, true
+	}
+
+	node, ok := c.getEdgeNode(nodeId)
+	if !ok {
+		return nil, false
+	}
+
+	var evictedPods []*Pod
+	for _, pod := range c.Edge.Pods {
+		if pod.Node.Id == nodeId {
+			evictedPods = append(evictedPods, pod)
+		}
+	}
+	for _, pod := range evictedPods {
+		c.evictToCloud(pod)
+	}
+
+	c.Edge.Config.Nodes = removeNode(c.Edge.Config.Nodes, nodeId)
+	utils.SSubVec(c.Edge.Config.Resources, node.Resources)
+	utils.SSubVec(c.Edge.UsedResources, c.NodeResourcesReserved[nodeId])
+	delete(c.NodeResourcesUsed, nodeId)
+	delete(c.NodeResourcesReserved, nodeId)
+
+	if c.shouldLog {
+		log.Info().Msgf("removed node %d, %d pods evicted", nodeId, len(evictedPods))
+	}
+
+	return evictedPods, true
+}
+
+func (c *ClusterState) evictToCloud(pod *Pod) {
+	c.RemovePodEdge(pod)
+	c.DeployCloud(pod)
+}
+
+func (c *ClusterState) getEdgeNode(nodeId int) (*Node, bool) {
+	for _, node := range c.Edge.Config.Nodes {
+		if node.Id == nodeId {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *ClusterState) getPodsOfDeployment(deploymentId int) []*Pod {
+	var pods []*Pod
+	for _, pod := range c.PodsMap {
+		if pod.Deployment.Id == deploymentId {
+			pods = append(pods, pod)
+		}
+	}
+
+	return pods
+}
+
+// Following helpers build new slices instead of changing
+// the given ones, the old slices may be in use.
+
+func replaceNode(nodes []*Node, node *Node) []*Node {
+	ret := make([]*Node, 0, len(nodes))
+	for _, current := range nodes {
+		if current.Id == node.Id {
+			current = node
+		}
+		ret = append(ret, current)
+	}
+
+	return ret
+}
+
+func removeNode(nodes []*Node, nodeId int) []*Node {
+	ret := make([]*Node, 0, len(nodes))
+	for _, current := range nodes {
+		if current.Id != nodeId {
+			ret = append(ret, current)
+		}
+	}
+
+	return ret
+}
+
 // Following methods are for changing scheduler's point of view
 // of cluster's dynamic properties.
 func (c *ClusterState) DeployEdge(pod *Pod, node *Node) error {
@@ -156,9 +429,18 @@ func (c *ClusterState) DeployCloud(pod *Pod) {
 		)
 	}
 
-	if len(c.Cloud.Nodes) > 0 {
-		target := c.Cloud.Nodes[rand.Intn(len(c.Cloud.Nodes))]
-		pod.Node = target
+	// The pod stays on its cloud node if it has one.
+	if pod.Node == nil || !c.IsCloudNode(pod.Node.Id) {
+		pod.Node = nil
+		if node, fits := c.PickCloudNode(pod.Deployment); node != nil {
+			if !fits && c.shouldLog {
+				log.Warn().Msgf("pod %d does not fit in any cloud node, deploying it on node %d", pod.Id, node.Id)
+			}
+			pod.Node = node
+		}
+	}
+	if pod.Node != nil {
+		utils.SAddVec(c.Cloud.ResourcesUsed[pod.Node.Id], pod.Deployment.ResourcesRequired)
 	}
 
 	c.PodsMap[pod.Id] = pod
@@ -195,6 +477,12 @@ func (c *ClusterState) RemovePodCloud(pod *Pod) bool {
 		return false
 	}
 
+	if pod.Node != nil {
+		if used, ok := c.Cloud.ResourcesUsed[pod.Node.Id]; ok {
+			utils.SSubVec(used, pod.Deployment.ResourcesRequired)
+		}
+	}
+
 	pod.Node = nil
 	c.Cloud.Pods[cpod_ind] = c.Cloud.Pods[len(c.Cloud.Pods)-1]
 	c.Cloud.Pods = c.Cloud.Pods[:len(c.Cloud.Pods)-1]
@@ -267,6 +555,7 @@ func (c *ClusterState) Clone() *ClusterState {
 			Deployment: pod.Deployment,
 			Node:       pod.Node,
 			Status:     pod.Status,
+			Group:      pod.Group,
 		}, pod.Node)
 
 		if pod.Status == RUNNING {
@@ -279,6 +568,7 @@ func (c *ClusterState) Clone() *ClusterState {
 			Deployment: pod.Deployment,
 			Node:       pod.Node,
 			Status:     pod.Status,
+			Group:      pod.Group,
 		})
 
 		if pod.Status == RUNNING {
@@ -286,9 +576,109 @@ func (c *ClusterState) Clone() *ClusterState {
 		}
 	}
 
+	// Reserved after the pods, so that the pods
+	// are cloned even if the node is overcommitted.
+	for nodeId, reserved := range c.NodeResourcesReserved {
+		ret.SetNodeReserved(nodeId, reserved)
+	}
+
 	return ret
 }
 
+// Returns the cost of a pod of the deployment on the cloud node,
+// which is the node's cost times the pod's dominant share of it.
+// The resources that the node does not have are not shared.
+func PodCost(deployment *Deployment, node *Node) float64 {
+	if node.Cost == 0 {
+		return 0
+	}
+
+	var share float64
+	for i := 0; i < node.Resources.Len(); i++ {
+		required := deployment.ResourcesRequired.AtVec(i)
+		if required == 0 || node.Resources.AtVec(i) == 0 {
+			continue
+		}
+
+		share = math.Max(share, required/node.Resources.AtVec(i))
+	}
+
+	return node.Cost * share
+}
+
+// Returns the cheapest cloud node that the deployment's pods are allowed
+// on and has enough free resources for a pod of the deployment. If no
+// node fits the pod, the cheapest allowed node (or the cheapest node if
+// none is allowed) is returned with false, nil only if there is no cloud node.
+// A node without some resource that the pod requires is not allowed.
+func (c *ClusterState) PickCloudNode(deployment *Deployment) (*Node, bool) {
+	var cheapest, cheapestAllowed, cheapestFitting *Node
+	isCheaper := func(node, than *Node) bool {
+		return than == nil || PodCost(deployment, node) < PodCost(deployment, than)
+	}
+
+	for _, node := range c.Cloud.Nodes {
+		if isCheaper(node, cheapest) {
+			cheapest = node
+		}
+		if !deployment.CanRunOn(node) || !hasResources(node, deployment.ResourcesRequired) {
+			continue
+		}
+		if isCheaper(node, cheapestAllowed) {
+			cheapestAllowed = node
+		}
+
+		remained := utils.SubVec(node.Resources, c.Cloud.ResourcesUsed[node.Id])
+		if !utils.LEThan(deployment.ResourcesRequired, remained) {
+			continue
+		}
+		if isCheaper(node, cheapestFitting) {
+			cheapestFitting = node
+		}
+	}
+
+	if cheapestFitting != nil {
+		return cheapestFitting, true
+	}
+	if cheapestAllowed != nil {
+		return cheapestAllowed, false
+	}
+
+	return cheapest, false
+}
+
+// Returns whether the node has some of each of the resources.
+func hasResources(node *Node, resources *mat.VecDense) bool {
+	for i := 0; i < node.Resources.Len(); i++ {
+		if resources.AtVec(i) > 0 && node.Resources.AtVec(i) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the cost of placing a pod of the deployment on cloud now.
+func (c *ClusterState) CloudCost(deployment *Deployment) float64 {
+	node, _ := c.PickCloudNode(deployment)
+	if node == nil {
+		return 0
+	}
+
+	return PodCost(deployment, node)
+}
+
+// Returns whether the node is one of the cloud nodes.
+func (c *ClusterState) IsCloudNode(nodeId int) bool {
+	for _, node := range c.Cloud.Nodes {
+		if node.Id == nodeId {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Returns a mapping of [(node id) -> (node object)].
 func (c *ClusterState) GetNodeIdToNode() map[int]*Node {
 	nodeIdToNode := make(map[int]*Node)
@@ -324,10 +714,9 @@ func (c *ClusterState) Display() string {
 	repr += "DEPLOYMENTS:\n"
 	for _, deployment := range c.Edge.Config.Deployments {
 		deploymentDesc := fmt.Sprintf(
-			"{deployment %d (%f, %f)} share %f, number of running pods: %d",
+			"{deployment %d %s} share %f, number of running pods: %d",
 			deployment.Id,
-			deployment.ResourcesRequired.AtVec(0),
-			deployment.ResourcesRequired.AtVec(1),
+			utils.ToString(deployment.ResourcesRequired),
 			deployment.EdgeShare,
 			c.NumberOfRunningPods[deployment.Id],
 		)
@@ -339,14 +728,13 @@ func (c *ClusterState) Display() string {
 	repr += "EDGE NODES:\n"
 	for _, node := range c.Edge.Config.Nodes {
 		nodeDesc := ""
-		nodeDesc += fmt.Sprintf("{node %d (%f, %f)}: ", node.Id, node.Resources.AtVec(0), node.Resources.AtVec(1))
+		nodeDesc += fmt.Sprintf("{node %d %s}: ", node.Id, utils.ToString(node.Resources))
 		for _, pod := range c.Edge.Pods {
 			if pod.Node.Id == node.Id {
 				nodeDesc += fmt.Sprintf(
-					"{pod %d (%f, %f)} || ",
+					"{pod %d %s} || ",
 					pod.Id,
-					pod.Deployment.ResourcesRequired.AtVec(0),
-					pod.Deployment.ResourcesRequired.AtVec(1),
+					utils.ToTuple(pod.Deployment.ResourcesRequired),
 				)
 			}
 		}
@@ -359,14 +747,13 @@ func (c *ClusterState) Display() string {
 	// FIXME duplication
 	for _, node := range c.Cloud.Nodes {
 		nodeDesc := ""
-		nodeDesc += fmt.Sprintf("{node %d (%f, %f)}: ", node.Id, node.Resources.AtVec(0), node.Resources.AtVec(1))
+		nodeDesc += fmt.Sprintf("{node %d %s}: ", node.Id, utils.ToString(node.Resources))
 		for _, pod := range c.Cloud.Pods {
-			if pod.Node.Id == node.Id {
+			if pod.Node != nil && pod.Node.Id == node.Id {
 				nodeDesc += fmt.Sprintf(
-					"{pod %d (%f, %f)} || ",
+					"{pod %d %s} || ",
 					pod.Id,
-					pod.Deployment.ResourcesRequired.AtVec(0),
-					pod.Deployment.ResourcesRequired.AtVec(1),
+					utils.ToTuple(pod.Deployment.ResourcesRequired),
 				)
 			}
 		}
@@ -0,0 +1,218 @@
+package model_test
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+	"gonum.org/v1/gonum/mat"
+)
+
+func setUpConfig(t *testing.T) {
+	yamlFile, err := os.ReadFile("../../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(yamlFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns a cluster with A, A and B pods on a 4x4 edge node,
+// a B pod on a 2x2 edge node and an A pod on the cloud node.
+func newCluster(t *testing.T) (*testing_tool.Builder, *model.ClusterState) {
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetCluster(map[*testing_tool.NodeDesc][]string{}, []string{"A"})
+
+	nodes := []*model.Node{
+		{Id: 100, Resources: mat.NewVecDense(2, []float64{4, 4})},
+		{Id: 101, Resources: mat.NewVecDense(2, []float64{2, 2})},
+	}
+	for _, node := range nodes {
+		clusterState.AddNode(node, "edge")
+	}
+	for ind, pod := range builder.GetPods([]string{"A", "A", "B", "B"}) {
+		node := nodes[0]
+		if ind == 3 {
+			node = nodes[1]
+		}
+		if err := clusterState.DeployEdge(pod, node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return builder, clusterState
+}
+
+// Checks that the used resources of the edge nodes
+// are the sum of their pods' and reserved resources.
+func checkUsedResources(t *testing.T, clusterState *model.ClusterState) {
+	used := make(map[int]*mat.VecDense)
+	edgeUsed := mat.NewVecDense(2, nil)
+	for _, node := range clusterState.Edge.Config.Nodes {
+		used[node.Id] = mat.VecDenseCopyOf(clusterState.NodeResourcesReserved[node.Id])
+		edgeUsed.AddVec(edgeUsed, clusterState.NodeResourcesReserved[node.Id])
+	}
+	for _, pod := range clusterState.Edge.Pods {
+		used[pod.Node.Id].AddVec(used[pod.Node.Id], pod.Deployment.ResourcesRequired)
+		edgeUsed.AddVec(edgeUsed, pod.Deployment.ResourcesRequired)
+	}
+
+	for nodeId, nodeUsed := range used {
+		if !mat.Equal(nodeUsed, clusterState.NodeResourcesUsed[nodeId]) {
+			t.Errorf("node %d uses %v, wanted %v", nodeId, clusterState.NodeResourcesUsed[nodeId], nodeUsed)
+		}
+	}
+	if !mat.Equal(edgeUsed, clusterState.Edge.UsedResources) {
+		t.Errorf("edge uses %v, wanted %v", clusterState.Edge.UsedResources, edgeUsed)
+	}
+}
+
+func TestClusterChanges(t *testing.T) {
+	setUpConfig(t)
+
+	for _, test := range []struct {
+		name          string
+		change        func(builder *testing_tool.Builder, clusterState *model.ClusterState) ([]*model.Pod, bool)
+		wantedEvicted int
+		wantedEdge    int
+		wantedCloud   int
+	}{
+		{
+			"shrink an edge node",
+			func(_ *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				return c.UpdateNode(&model.Node{Id: 100, Resources: mat.NewVecDense(2, []float64{2, 2})})
+			},
+			1, 3, 2,
+		},
+		{
+			"grow an edge node",
+			func(_ *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				return c.UpdateNode(&model.Node{Id: 100, Resources: mat.NewVecDense(2, []float64{8, 8})})
+			},
+			0, 4, 1,
+		},
+		{
+			"remove an edge node",
+			func(_ *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				return c.RemoveNode(101)
+			},
+			1, 3, 2,
+		},
+		{
+			"remove the last cloud node",
+			func(_ *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				return c.RemoveNode(c.Cloud.Nodes[0].Id)
+			},
+			1, 4, 1,
+		},
+		{
+			"grow a deployment",
+			func(builder *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				a := *builder.Deployments["A"]
+				a.ResourcesRequired = mat.NewVecDense(2, []float64{2, 2})
+				return c.UpdateDeployment(&a)
+			},
+			1, 3, 2,
+		},
+		{
+			"shrink a deployment",
+			func(builder *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				a := *builder.Deployments["A"]
+				a.ResourcesRequired = mat.NewVecDense(2, []float64{0.5, 0.5})
+				return c.UpdateDeployment(&a)
+			},
+			0, 4, 1,
+		},
+		{
+			"reserve a full node's resources",
+			func(_ *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				return nil, c.SetNodeReserved(101, mat.NewVecDense(2, []float64{2, 2}))
+			},
+			0, 4, 1,
+		},
+		{
+			"remove a node with reserved resources",
+			func(_ *testing_tool.Builder, c *model.ClusterState) ([]*model.Pod, bool) {
+				c.SetNodeReserved(101, mat.NewVecDense(2, []float64{1, 1}))
+				return c.RemoveNode(101)
+			},
+			1, 3, 2,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			builder, clusterState := newCluster(t)
+
+			evicted, ok := test.change(builder, clusterState)
+			if !ok {
+				t.Fatal("the change is not applied")
+			}
+			if len(evicted) != test.wantedEvicted {
+				t.Errorf("got %d evicted pods, wanted %d", len(evicted), test.wantedEvicted)
+			}
+			if len(clusterState.Edge.Pods) != test.wantedEdge || len(clusterState.Cloud.Pods) != test.wantedCloud {
+				t.Errorf(
+					"got %d edge and %d cloud pods, wanted %d and %d",
+					len(clusterState.Edge.Pods), len(clusterState.Cloud.Pods), test.wantedEdge, test.wantedCloud,
+				)
+			}
+			if len(clusterState.PodsMap) != 5 {
+				t.Errorf("got %d pods, wanted all 5 pods kept", len(clusterState.PodsMap))
+			}
+			for _, pod := range clusterState.PodsMap {
+				if wanted := clusterState.Edge.Config.DeploymentIdToDeployment[pod.Deployment.Id]; pod.Deployment != wanted {
+					t.Errorf("pod %d points to an old deployment", pod.Id)
+				}
+			}
+			checkUsedResources(t, clusterState)
+		})
+	}
+}
+
+func TestRemoveLastCloudNode(t *testing.T) {
+	setUpConfig(t)
+
+	_, clusterState := newCluster(t)
+	cloudNode := clusterState.Cloud.Nodes[0]
+	if _, ok := clusterState.RemoveNode(cloudNode.Id); !ok {
+		t.Fatal("the cloud node is not removed")
+	}
+
+	if len(clusterState.Cloud.Nodes) != 0 || clusterState.Cloud.ResourcesUsed[cloudNode.Id] != nil {
+		t.Errorf("the cloud node is kept")
+	}
+	// The cloud pods wait for a new cloud node.
+	if pod := clusterState.Cloud.Pods[0]; pod.Node != nil {
+		t.Errorf("pod %d is on node %d, wanted no node", pod.Id, pod.Node.Id)
+	}
+	if node, _ := clusterState.PickCloudNode(clusterState.Cloud.Pods[0].Deployment); node != nil {
+		t.Errorf("picked cloud node %d without any cloud node", node.Id)
+	}
+}
+
+func TestPickCloudNode(t *testing.T) {
+	setUpConfig(t)
+
+	deployment := &model.Deployment{Id: 0, ResourcesRequired: mat.NewVecDense(2, []float64{1, 1})}
+	// The cheap node does not have any memory.
+	cheap := &model.Node{Id: 0, Cost: 1, Resources: mat.NewVecDense(2, []float64{4, 0})}
+	large := &model.Node{Id: 1, Cost: 4, Resources: mat.NewVecDense(2, []float64{4, 4})}
+
+	if cost := model.PodCost(deployment, cheap); math.IsInf(cost, 0) || cost != 0.25 {
+		t.Errorf("got cost %v on the node without memory, wanted 0.25", cost)
+	}
+
+	clusterState := model.NewClusterState()
+	clusterState.AddNode(cheap, "cloud")
+	clusterState.AddNode(large, "cloud")
+	if node, fits := clusterState.PickCloudNode(deployment); node != large || !fits {
+		t.Errorf("got node %v, wanted the one with memory", node)
+	}
+}
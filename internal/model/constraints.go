@@ -0,0 +1,236 @@
+package model
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Placement constraints of deployments' pods, which are the
+// node selector, the required node affinity and the tolerations
+// of the pods' taints, the same as kube-scheduler's.
+
+// Taint effects, pods without a matching toleration are kept off
+// the nodes with NoSchedule or NoExecute taints, PreferNoSchedule
+// taints are only preferences and are ignored.
+const (
+	NO_SCHEDULE_EFFECT        = "NoSchedule"
+	PREFER_NO_SCHEDULE_EFFECT = "PreferNoSchedule"
+	NO_EXECUTE_EFFECT         = "NoExecute"
+)
+
+// Operators of tolerations (Exists and Equal) and
+// of node selector requirements (the others and Exists).
+const (
+	EXISTS_OPERATOR         = "Exists"
+	EQUAL_OPERATOR          = "Equal"
+	IN_OPERATOR             = "In"
+	NOT_IN_OPERATOR         = "NotIn"
+	DOES_NOT_EXIST_OPERATOR = "DoesNotExist"
+	GT_OPERATOR             = "Gt"
+	LT_OPERATOR             = "Lt"
+)
+
+// Key of the node's id in node selector terms, it is matched
+// like a label of the node but it is not a valid label key.
+const NODE_ID_KEY = "@node-id"
+
+type Taint struct {
+	Key    string `yaml:"key"`
+	Value  string `yaml:"value,omitempty"`
+	Effect string `yaml:"effect"`
+}
+
+type Toleration struct {
+	Key string `yaml:"key,omitempty"`
+	// Equal if it is empty.
+	Operator string `yaml:"operator,omitempty"`
+	Value    string `yaml:"value,omitempty"`
+	// Matches all effects if it is empty.
+	Effect string `yaml:"effect,omitempty"`
+}
+
+type LabelRequirement struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values,omitempty"`
+}
+
+// A node matches the term if it meets all of its requirements.
+type NodeSelectorTerm []LabelRequirement
+
+// A pod matches the selector if its labels meet all of its requirements,
+// unlike node selector terms an empty selector matches all pods.
+type LabelSelector []LabelRequirement
+
+// Rules for spreading the deployment's pods on edge,
+// zero values mean no rule.
+type Spread struct {
+	// Maximum number of the deployment's pods on an edge node.
+	MaxPerNode int `yaml:"max_per_node,omitempty"`
+	// Maximum difference between the numbers of the deployment's
+	// pods in the zones of the edge nodes it is allowed on.
+	MaxZoneSkew int `yaml:"max_zone_skew,omitempty"`
+	// The deployment's pods do not share a node with the pods
+	// of the deployments whose labels match any of the selectors.
+	AntiAffinity []LabelSelector `yaml:"anti_affinity,omitempty"`
+}
+
+type Constraints struct {
+	// Labels that the node should have.
+	NodeSelector map[string]string `yaml:"node_selector,omitempty"`
+	// The node should match at least one of the terms, if there is any.
+	RequiredAffinity []NodeSelectorTerm `yaml:"required_affinity,omitempty"`
+	Tolerations      []Toleration       `yaml:"tolerations,omitempty"`
+}
+
+func (constraints *Constraints) Equal(other *Constraints) bool {
+	return reflect.DeepEqual(constraints, other)
+}
+
+func (spread *Spread) Equal(other *Spread) bool {
+	return reflect.DeepEqual(spread, other)
+}
+
+// Returns whether the pods of the deployment are allowed on the node.
+func (deployment *Deployment) CanRunOn(node *Node) bool {
+	constraints := &deployment.Constraints
+
+	for key, value := range constraints.NodeSelector {
+		if nodeValue, ok := node.Labels[key]; !ok || nodeValue != value {
+			return false
+		}
+	}
+
+	if len(constraints.RequiredAffinity) > 0 {
+		labels := make(map[string]string, len(node.Labels)+1)
+		for key, value := range node.Labels {
+			labels[key] = value
+		}
+		labels[NODE_ID_KEY] = strconv.Itoa(node.Id)
+
+		matched := false
+		for _, term := range constraints.RequiredAffinity {
+			if term.Matches(labels) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, taint := range node.Taints {
+		if taint.Effect == PREFER_NO_SCHEDULE_EFFECT {
+			continue
+		}
+		if !constraints.Tolerates(taint) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns whether any of the tolerations matches the taint.
+func (constraints *Constraints) Tolerates(taint Taint) bool {
+	for _, toleration := range constraints.Tolerations {
+		if toleration.Effect != "" && toleration.Effect != taint.Effect {
+			continue
+		}
+		if toleration.Key != "" && toleration.Key != taint.Key {
+			continue
+		}
+
+		switch toleration.Operator {
+		case EXISTS_OPERATOR:
+			return true
+		case EQUAL_OPERATOR, "":
+			// An empty key with Equal matches nothing, like in k8s.
+			if toleration.Key != "" && toleration.Value == taint.Value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns whether the labels meet all the requirements of the term,
+// an empty term matches no labels like in k8s.
+func (term NodeSelectorTerm) Matches(labels map[string]string) bool {
+	if len(term) == 0 {
+		return false
+	}
+
+	for _, requirement := range term {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (selector LabelSelector) Matches(labels map[string]string) bool {
+	for _, requirement := range selector {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns whether the pods of the deployments should not share a node,
+// which is the case if any of them has an anti-affinity with the other.
+func IsAntiAffine(a, b *Deployment) bool {
+	for _, selector := range a.Spread.AntiAffinity {
+		if selector.Matches(b.Labels) {
+			return true
+		}
+	}
+	for _, selector := range b.Spread.AntiAffinity {
+		if selector.Matches(a.Labels) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (requirement *LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[requirement.Key]
+
+	switch requirement.Operator {
+	case IN_OPERATOR:
+		return ok && slices.Contains(requirement.Values, value)
+	case NOT_IN_OPERATOR:
+		return !ok || !slices.Contains(requirement.Values, value)
+	case EXISTS_OPERATOR:
+		return ok
+	case DOES_NOT_EXIST_OPERATOR:
+		return !ok
+	case GT_OPERATOR, LT_OPERATOR:
+		if !ok || len(requirement.Values) != 1 {
+			return false
+		}
+
+		labelValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		bound, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+
+		if requirement.Operator == GT_OPERATOR {
+			return labelValue > bound
+		}
+		return labelValue < bound
+	}
+
+	return false
+}
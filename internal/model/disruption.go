@@ -0,0 +1,46 @@
+package model
+
+import "reflect"
+
+// Like a k8s PodDisruptionBudget, bounds how many of the deployment's
+// running pods can be disrupted (deleted to be moved) at once.
+// If both bounds are set, MinAvailable is used.
+type DisruptionBudget struct {
+	// Minimum number of the deployment's running pods.
+	MinAvailable *PodCount `yaml:"min_available,omitempty"`
+	// Maximum number of the deployment's pods that are not running.
+	MaxUnavailable *PodCount `yaml:"max_unavailable,omitempty"`
+}
+
+// A number of pods, or a percentage of the deployment's pods.
+type PodCount struct {
+	Value     int  `yaml:"value"`
+	IsPercent bool `yaml:"is_percent,omitempty"`
+}
+
+// Returns the number of pods out of the deployment's pods,
+// percentages are rounded up like in k8s.
+func (count *PodCount) Of(pods int) int {
+	if !count.IsPercent {
+		return count.Value
+	}
+
+	return (count.Value*pods + 99) / 100
+}
+
+// Returns how many of the deployment's running pods can be
+// disrupted, given the number of its pods and its running pods.
+func (budget *DisruptionBudget) AllowedDisruptions(pods int, running int) int {
+	var desired int
+	if budget.MinAvailable != nil {
+		desired = budget.MinAvailable.Of(pods)
+	} else if budget.MaxUnavailable != nil {
+		desired = pods - budget.MaxUnavailable.Of(pods)
+	}
+
+	return max(0, running-desired)
+}
+
+func (budget *DisruptionBudget) Equal(other *DisruptionBudget) bool {
+	return reflect.DeepEqual(budget, other)
+}
@@ -20,6 +20,17 @@ type DecisionForNewPods struct {
 	ToEdgePods                []*Pod
 	ToCloudPods               []*Pod
 	Migrations                []*Migration
+
+	// For auditing, number of edge/cloud splits of the new pods
+	// that were considered and the best ones of them.
+	ConsideredSplits int
+	BestSplits       []*SplitCandidate
+}
+
+// A possible edge/cloud split of new pods.
+type SplitCandidate struct {
+	EdgePods []*Pod
+	Score    float64
 }
 
 type Candidate struct {
@@ -30,6 +41,18 @@ type Candidate struct {
 type EdgePodMapping struct {
 	Mapping         map[int]*Node
 	DeFragmentation float64
+	// How many pods the deployments are over their maximum
+	// zone skews with the mapping, the other spread rules
+	// are never broken by the mapping.
+	Violations int
+}
+
+// Evicting the victims, which have lower priorities than
+// the pod, from the edge node to cloud so the pod fits in it.
+type Preemption struct {
+	Pod     *Pod
+	Node    *Node
+	Victims []*Pod
 }
 
 type ReorderSuggestion struct {
@@ -8,6 +8,7 @@ import (
 	"math"
 	"sort"
 
+	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/model"
 	"gonum.org/v1/gonum/mat"
 )
@@ -15,13 +16,18 @@ import (
 type NodeDesc struct {
 	Cpu    float64
 	Memory float64
+	// Other configured resources by their names,
+	// the ones that are not mentioned are zero.
+	Others map[string]float64
 }
 
 type DeploymentDesc struct {
 	Name      string
 	Cpu       float64
 	Memory    float64
+	Others    map[string]float64
 	EdgeShare float64
+	Priority  int32
 }
 
 type Builder struct {
@@ -42,8 +48,9 @@ func (builder *Builder) ImportDeployments(deploymentsDesc []*DeploymentDesc) {
 	for ind, deploymentDesc := range deploymentsDesc {
 		deployment := &model.Deployment{
 			Id:                ind,
-			ResourcesRequired: mat.NewVecDense(2, []float64{deploymentDesc.Cpu, deploymentDesc.Memory}),
+			ResourcesRequired: resourceVector(deploymentDesc.Cpu, deploymentDesc.Memory, deploymentDesc.Others),
 			EdgeShare:         deploymentDesc.EdgeShare,
+			Priority:          deploymentDesc.Priority,
 		}
 		builder.Deployments[deploymentDesc.Name] = deployment
 		builder.DeploymentName[deployment.Id] = deploymentDesc.Name
@@ -78,12 +85,7 @@ func (builder *Builder) GetCluster(edge map[*NodeDesc][]string, cloudPodsDesc []
 	}
 
 	for nodeDesc, podsDesc := range edge {
-		node := &model.Node{
-			Id:        builder.lastNodeId,
-			Resources: mat.NewVecDense(2, []float64{nodeDesc.Cpu, nodeDesc.Memory}),
-		}
-		builder.lastNodeId += 1
-		clusterState.AddNode(node, "edge")
+		node := builder.addEdgeNode(clusterState, nodeDesc)
 
 		for _, pod := range builder.GetPods(podsDesc) {
 			err := clusterState.DeployEdge(pod, node)
@@ -93,12 +95,7 @@ func (builder *Builder) GetCluster(edge map[*NodeDesc][]string, cloudPodsDesc []
 		}
 	}
 
-	cloudNode := &model.Node{
-		Id:        builder.lastNodeId,
-		Resources: mat.NewVecDense(2, []float64{math.Inf(1), math.Inf(1)}),
-	}
-	builder.lastNodeId += 1
-	clusterState.AddNode(cloudNode, "cloud")
+	builder.addCloudNode(clusterState)
 
 	for _, pod := range builder.GetPods(cloudPodsDesc) {
 		clusterState.DeployCloud(pod)
@@ -107,6 +104,57 @@ func (builder *Builder) GetCluster(edge map[*NodeDesc][]string, cloudPodsDesc []
 	return clusterState
 }
 
+// Returns a cluster without any pods, unlike GetCluster
+// edge nodes are added (and get their ids) in the given order
+// and so are deployments in the order of their ids.
+func (builder *Builder) GetEmptyCluster(edge []*NodeDesc) *model.ClusterState {
+	clusterState := model.NewClusterState()
+
+	deployments := make([]*model.Deployment, 0)
+	for _, deployment := range builder.Deployments {
+		deployments = append(deployments, deployment)
+	}
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Id < deployments[j].Id
+	})
+	for _, deployment := range deployments {
+		clusterState.Edge.Config.AddDeployment(deployment)
+	}
+
+	for _, nodeDesc := range edge {
+		builder.addEdgeNode(clusterState, nodeDesc)
+	}
+
+	builder.addCloudNode(clusterState)
+
+	return clusterState
+}
+
+func (builder *Builder) addEdgeNode(clusterState *model.ClusterState, nodeDesc *NodeDesc) *model.Node {
+	node := &model.Node{
+		Id:        builder.lastNodeId,
+		Resources: resourceVector(nodeDesc.Cpu, nodeDesc.Memory, nodeDesc.Others),
+	}
+	builder.lastNodeId += 1
+	clusterState.AddNode(node, "edge")
+
+	return node
+}
+
+func (builder *Builder) addCloudNode(clusterState *model.ClusterState) {
+	cloudResources := mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
+	for i := 0; i < cloudResources.Len(); i++ {
+		cloudResources.SetVec(i, math.Inf(1))
+	}
+
+	cloudNode := &model.Node{
+		Id:        builder.lastNodeId,
+		Resources: cloudResources,
+	}
+	builder.lastNodeId += 1
+	clusterState.AddNode(cloudNode, "cloud")
+}
+
 func (builder *Builder) Expect(got *model.ClusterState, wantEdge map[*NodeDesc][]string, wantCloudPodsDesc []string) {
 	gotDeploymentOccurrences := make(map[string][]*NodeDesc)
 	wantDeploymentOccurrences := make(map[string][]*NodeDesc)
@@ -114,8 +162,8 @@ func (builder *Builder) Expect(got *model.ClusterState, wantEdge map[*NodeDesc][
 	for _, pod := range got.Edge.Pods {
 		key := builder.DeploymentName[pod.Deployment.Id]
 		gotDeploymentOccurrences[key] = append(gotDeploymentOccurrences[key], &NodeDesc{
-			Cpu:    pod.Node.Resources.AtVec(0),
-			Memory: pod.Node.Resources.AtVec(1),
+			Cpu:    resourceAt(pod.Node.Resources, "cpu"),
+			Memory: resourceAt(pod.Node.Resources, "memory"),
 		})
 	}
 	for _, pod := range got.Cloud.Pods {
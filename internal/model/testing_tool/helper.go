@@ -1,5 +1,45 @@
 package testing_tool
 
+import (
+	"fmt"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Builds a resource vector of all configured resources.
+func resourceVector(cpu, memory float64, others map[string]float64) *mat.VecDense {
+	ret := mat.NewVecDense(config.SchedulerGeneralConfig.ResourceCount, nil)
+
+	set := func(name string, value float64) {
+		ind := config.ResourceIndex(name)
+		if ind == -1 {
+			if value != 0 {
+				panic(fmt.Sprintf("resource %s is not configured", name))
+			}
+			return
+		}
+		ret.SetVec(ind, value)
+	}
+
+	set("cpu", cpu)
+	set("memory", memory)
+	for name, value := range others {
+		set(name, value)
+	}
+
+	return ret
+}
+
+func resourceAt(resources *mat.VecDense, name string) float64 {
+	ind := config.ResourceIndex(name)
+	if ind == -1 {
+		return 0
+	}
+
+	return resources.AtVec(ind)
+}
+
 type PodOnNodeOccurrencesSorter struct {
 	objects []*NodeDesc
 }
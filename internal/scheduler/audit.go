@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/audit"
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Adds the decision about the pods to the audit log, it should
+// be called before the decision is applied on the cluster state.
+// Pods decided for edge but not in the edge mapping go to cloud,
+// the cloud mapping has the cloud nodes of the pods going to cloud.
+// Preemptions override the decision for their pods and victims.
+func (scheduler *Scheduler) auditDecision(
+	recordType string,
+	pods []*model.Pod,
+	decision model.DecisionForNewPods,
+	edgeMapping map[int]*model.Node,
+	cloudMapping map[int]*model.Node,
+	preemptions []*model.Preemption,
+) {
+	record := &audit.Record{
+		Time:             time.Now(),
+		Type:             recordType,
+		Algorithm:        scheduler.algorithmName,
+		FreeResources:    make(map[int]map[string]float64),
+		ConsideredSplits: decision.ConsideredSplits,
+		Mapping:          make(map[int]int),
+		Reasons:          make(map[int]string),
+	}
+
+	if !math.IsInf(decision.Score, 0) {
+		score := decision.Score
+		record.Score = &score
+	}
+
+	for _, pod := range pods {
+		record.NewPods = append(record.NewPods, audit.PodRecord{
+			Id:         pod.Id,
+			Deployment: pod.Deployment.Id,
+			Priority:   pod.Deployment.Priority,
+			Node:       nodeIdOf(pod.Node),
+			Group:      groupNameOf(pod),
+		})
+	}
+
+	for nodeId, resources := range scheduler.clusterState.GetNodesResourcesRemained() {
+		record.FreeResources[nodeId] = make(map[string]float64)
+		for ind, resource := range config.SchedulerGeneralConfig.Resources {
+			record.FreeResources[nodeId][resource.Name] = resources.AtVec(ind)
+		}
+	}
+
+	for _, split := range decision.BestSplits {
+		splitRecord := audit.SplitRecord{
+			EdgePods: make([]int, 0),
+			Score:    split.Score,
+		}
+		for _, pod := range split.EdgePods {
+			splitRecord.EdgePods = append(splitRecord.EdgePods, pod.Id)
+		}
+		record.BestSplits = append(record.BestSplits, splitRecord)
+	}
+
+	for _, pod := range decision.ToCloudPods {
+		record.ToCloudPods = append(record.ToCloudPods, pod.Id)
+		record.Mapping[pod.Id] = nodeIdOf(cloudMapping[pod.Id])
+		record.Reasons[pod.Id] = cloudReason(pod, decision)
+	}
+
+	for _, pod := range decision.ToEdgePods {
+		record.ToEdgePods = append(record.ToEdgePods, pod.Id)
+
+		if node, ok := edgeMapping[pod.Id]; ok {
+			record.Mapping[pod.Id] = node.Id
+			record.Reasons[pod.Id] = fmt.Sprintf("decided for edge with score %f and mapped to node %d", decision.Score, node.Id)
+		} else {
+			record.Mapping[pod.Id] = nodeIdOf(cloudMapping[pod.Id])
+			record.Reasons[pod.Id] = "decided for edge, but no edge node had enough free resources when mapping, so placed on cloud"
+		}
+	}
+
+	for _, pod := range decision.EdgeToCloudOffloadingPods {
+		record.FreedPods = append(record.FreedPods, pod.Id)
+		record.Mapping[pod.Id] = nodeIdOf(cloudMapping[pod.Id])
+		record.Reasons[pod.Id] = fmt.Sprintf("offloaded from node %d to cloud, to free edge resources for other pods", nodeIdOf(pod.Node))
+	}
+
+	for _, migration := range decision.Migrations {
+		record.Migrations = append(record.Migrations, audit.MigrationRecord{
+			Pod:  migration.Pod.Id,
+			Node: migration.Node.Id,
+		})
+		record.Mapping[migration.Pod.Id] = migration.Node.Id
+		record.Reasons[migration.Pod.Id] = fmt.Sprintf(
+			"migrated from node %d to node %d, to make room on edge for other pods",
+			nodeIdOf(migration.Pod.Node),
+			migration.Node.Id,
+		)
+	}
+
+	for _, preemption := range preemptions {
+		preemptionRecord := audit.PreemptionRecord{
+			Pod:     preemption.Pod.Id,
+			Node:    preemption.Node.Id,
+			Victims: make([]int, 0),
+		}
+		for _, victim := range preemption.Victims {
+			preemptionRecord.Victims = append(preemptionRecord.Victims, victim.Id)
+			record.Mapping[victim.Id] = nodeIdOf(cloudMapping[victim.Id])
+			record.Reasons[victim.Id] = fmt.Sprintf(
+				"preempted by pod %d with priority %d, moved from node %d to cloud since its priority is %d",
+				preemption.Pod.Id,
+				preemption.Pod.Deployment.Priority,
+				preemption.Node.Id,
+				victim.Deployment.Priority,
+			)
+		}
+		record.Preemptions = append(record.Preemptions, preemptionRecord)
+
+		record.Mapping[preemption.Pod.Id] = preemption.Node.Id
+		record.Reasons[preemption.Pod.Id] = fmt.Sprintf(
+			"its deployment is below its edge share, so it preempted %d pods with lower priorities than %d on node %d",
+			len(preemption.Victims),
+			preemption.Pod.Deployment.Priority,
+			preemption.Node.Id,
+		)
+	}
+
+	for _, pod := range pods {
+		if _, ok := record.Reasons[pod.Id]; !ok {
+			record.Reasons[pod.Id] = "the algorithm made no decision for the pod"
+		}
+	}
+
+	scheduler.auditLog.Add(record)
+}
+
+// Explains why the pod is decided for cloud, by comparing
+// the chosen split with the best one having the pod on edge.
+func cloudReason(pod *model.Pod, decision model.DecisionForNewPods) string {
+	for _, split := range decision.BestSplits {
+		for _, edgePod := range split.EdgePods {
+			if edgePod.Id == pod.Id {
+				return fmt.Sprintf(
+					"decided for cloud, the chosen split has score %f while the best split with the pod on edge has score %f",
+					decision.Score,
+					split.Score,
+				)
+			}
+		}
+	}
+
+	if decision.ConsideredSplits == 0 {
+		return "decided for cloud, the algorithm did not report the splits it considered"
+	}
+
+	return fmt.Sprintf(
+		"decided for cloud with score %f, none of the %d best of %d considered splits has the pod on edge",
+		decision.Score,
+		len(decision.BestSplits),
+		decision.ConsideredSplits,
+	)
+}
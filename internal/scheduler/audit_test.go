@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/audit"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestAuditDecision(t *testing.T) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {},
+		},
+		[]string{},
+	)
+
+	scheduler, err := New(clusterState, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods := builder.GetPods([]string{"A", "A", "A"})
+	node := clusterState.Edge.Config.Nodes[0]
+	cloudNode := clusterState.Cloud.Nodes[0]
+
+	decision := model.DecisionForNewPods{
+		Score:            2,
+		ToCloudPods:      []*model.Pod{pods[0]},
+		ToEdgePods:       []*model.Pod{pods[1], pods[2]},
+		ConsideredSplits: 8,
+		BestSplits: []*model.SplitCandidate{
+			{EdgePods: []*model.Pod{pods[1], pods[2]}, Score: 2},
+			{EdgePods: []*model.Pod{pods[0], pods[1]}, Score: 1},
+		},
+	}
+	scheduler.auditDecision(audit.PLACING, pods, decision, map[int]*model.Node{pods[1].Id: node}, map[int]*model.Node{pods[0].Id: cloudNode, pods[2].Id: cloudNode}, nil)
+
+	records := scheduler.auditLog.ForPod(pods[0].Id)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, wanted 1", len(records))
+	}
+	record := records[0]
+
+	for _, expected := range []struct {
+		pod    *model.Pod
+		node   int
+		reason string
+	}{
+		{pod: pods[0], node: cloudNode.Id, reason: "best split with the pod on edge has score 1"},
+		{pod: pods[1], node: node.Id, reason: "mapped to node"},
+		{pod: pods[2], node: cloudNode.Id, reason: "no edge node had enough free resources"},
+	} {
+		if record.Mapping[expected.pod.Id] != expected.node {
+			t.Fatalf("pod %d is mapped to %d, wanted %d", expected.pod.Id, record.Mapping[expected.pod.Id], expected.node)
+		}
+		if !strings.Contains(record.Reasons[expected.pod.Id], expected.reason) {
+			t.Fatalf("reason of pod %d is %q, wanted it to contain %q", expected.pod.Id, record.Reasons[expected.pod.Id], expected.reason)
+		}
+	}
+}
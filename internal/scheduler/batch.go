@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Takes the next batch of new pods from the buffer, at most BatchSize
+// pods unless a pod group alone is larger. Pods of a group are taken
+// together when all of its pods that are not placed yet are pending,
+// until then they stay in the buffer.
+func (scheduler *Scheduler) takeBatch() []*model.Pod {
+	batchSize := config.SchedulerGeneralConfig.BatchSize
+	groups := model.PodsByGroup(scheduler.newPodBuffer)
+
+	// Pods of a group re-created alone, like after being evicted
+	// or migrated, do not wait for the placed pods of their group.
+	placed := make(map[string]int)
+	for _, pod := range scheduler.clusterState.PodsMap {
+		if pod.Group != nil && pod.Node != nil && pod.Status != model.FINISHED {
+			placed[pod.Group.Name]++
+		}
+	}
+
+	var batch, remained []*model.Pod
+	// number of pods in the batch, with the group pods that are not reached yet
+	taken := 0
+	takeGroup := make(map[string]bool)
+	for _, pod := range scheduler.newPodBuffer {
+		if pod.Group == nil {
+			if taken < batchSize {
+				batch = append(batch, pod)
+				taken++
+			} else {
+				remained = append(remained, pod)
+			}
+			continue
+		}
+
+		take, ok := takeGroup[pod.Group.Name]
+		if !ok {
+			members := groups[pod.Group.Name]
+			waiting := pod.Group.Size - placed[pod.Group.Name] - len(members)
+			take = waiting <= 0 && (taken == 0 || taken+len(members) <= batchSize)
+			if take {
+				taken += len(members)
+			} else if waiting > 0 {
+				log.Info().Msgf("waiting for %d more pods of group %s", waiting, pod.Group.Name)
+			}
+			takeGroup[pod.Group.Name] = take
+		}
+
+		if take {
+			batch = append(batch, pod)
+		} else {
+			remained = append(remained, pod)
+		}
+	}
+	scheduler.newPodBuffer = remained
+
+	return batch
+}
+
+func groupNameOf(pod *model.Pod) string {
+	if pod.Group == nil {
+		return ""
+	}
+
+	return pod.Group.Name
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestTakeBatch(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.BatchSize = 3
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	pods := builder.GetPods([]string{"A", "A", "A", "A", "A"})
+	group := &model.PodGroup{Name: "default/shop", Size: 3}
+	pods[1].Group, pods[3].Group = group, group
+
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{{Cpu: 4, Memory: 4}})
+	scheduler := &Scheduler{
+		clusterState: clusterState,
+		newPodBuffer: append([]*model.Pod(nil), pods...),
+	}
+
+	// The group waits for its third pod.
+	batch := scheduler.takeBatch()
+	if len(batch) != 3 || batch[0] != pods[0] || batch[1] != pods[2] || batch[2] != pods[4] {
+		t.Fatalf("got a batch of %d pods, wanted the pods without a group", len(batch))
+	}
+	if len(scheduler.newPodBuffer) != 2 {
+		t.Fatalf("the group's pods are not kept in the buffer")
+	}
+
+	// The group is taken as a whole, even if the batch is full by its pods.
+	third := builder.GetPods([]string{"A"})[0]
+	third.Group = group
+	other := builder.GetPods([]string{"A"})[0]
+	scheduler.newPodBuffer = append(scheduler.newPodBuffer, other, third)
+	batch = scheduler.takeBatch()
+	if len(batch) != 3 || batch[0] != pods[1] || batch[1] != pods[3] || batch[2] != third {
+		t.Fatalf("got a batch of %d pods, wanted the group's pods", len(batch))
+	}
+	if len(scheduler.newPodBuffer) != 1 || scheduler.newPodBuffer[0] != other {
+		t.Errorf("the pod after the group is not kept in the buffer")
+	}
+
+	// A pod re-created alone does not wait for the placed pods of its group.
+	pair := &model.PodGroup{Name: "default/pair", Size: 2}
+	placed := builder.GetPods([]string{"A"})[0]
+	placed.Group = pair
+	if err := clusterState.DeployEdge(placed, clusterState.Edge.Config.Nodes[0]); err != nil {
+		t.Fatal(err)
+	}
+	recreated := builder.GetPods([]string{"A"})[0]
+	recreated.Group = pair
+	scheduler.newPodBuffer = []*model.Pod{recreated}
+	if batch := scheduler.takeBatch(); len(batch) != 1 || batch[0] != recreated {
+		t.Errorf("the re-created pod waits for the placed pod of its group")
+	}
+}
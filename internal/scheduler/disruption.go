@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/statistics"
+)
+
+// A pod deleted by a reorder to be moved, for rate limiting.
+type migrationRecord struct {
+	time         time.Time
+	deploymentId int
+}
+
+// Returns the current time, of the virtual clock if the scheduler runs on one.
+func (scheduler *Scheduler) now() time.Time {
+	if scheduler.clock != nil {
+		return time.Time{}.Add(scheduler.clock.Now())
+	}
+
+	return time.Now()
+}
+
+// Returns an error if deleting the pods to move them disrupts more
+// running pods of a deployment than its disruption budget allows.
+// All the pods are counted as disrupted at once.
+func (scheduler *Scheduler) checkDisruptionBudgets(pods []*model.Pod) error {
+	disrupted := make(map[int]int)
+	deployments := make(map[int]*model.Deployment)
+	for _, pod := range pods {
+		if pod.Status == model.RUNNING && pod.Deployment.DisruptionBudget != nil {
+			disrupted[pod.Deployment.Id]++
+			deployments[pod.Deployment.Id] = pod.Deployment
+		}
+	}
+	if len(disrupted) == 0 {
+		return nil
+	}
+
+	numberOfPods := make(map[int]int)
+	for _, pod := range scheduler.clusterState.PodsMap {
+		numberOfPods[pod.Deployment.Id]++
+	}
+
+	for deploymentId, count := range disrupted {
+		deployment := deployments[deploymentId]
+		allowed := deployment.DisruptionBudget.AllowedDisruptions(
+			numberOfPods[deploymentId],
+			scheduler.clusterState.NumberOfRunningPods[deploymentId],
+		)
+		if count > allowed {
+			return fmt.Errorf(
+				"moving %d running pods of deployment %d breaks its disruption budget, %d are allowed",
+				count, deploymentId, allowed,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Returns an error if deleting the pods to move them migrates more pods
+// than the limits of the current window, of the cluster or of a deployment.
+func (scheduler *Scheduler) checkMigrationRate(pods []*model.Pod) error {
+	generalConfig := &config.SchedulerGeneralConfig
+	if generalConfig.MigrationWindowDuration == 0 {
+		return nil
+	}
+
+	// Forgetting the migrations before the window.
+	windowStart := scheduler.now().Add(-time.Duration(generalConfig.MigrationWindowDuration) * time.Millisecond)
+	ind := 0
+	for ind < len(scheduler.migrations) && scheduler.migrations[ind].time.Before(windowStart) {
+		ind++
+	}
+	scheduler.migrations = scheduler.migrations[ind:]
+
+	if limit := generalConfig.MaximumWindowMigrations; limit > 0 && len(scheduler.migrations)+len(pods) > limit {
+		return fmt.Errorf("%d pods are migrated in the window, %d more is over the limit of %d", len(scheduler.migrations), len(pods), limit)
+	}
+
+	limit := generalConfig.MaximumDeploymentWindowMigrations
+	if limit == 0 {
+		return nil
+	}
+
+	migrated := make(map[int]int)
+	for _, record := range scheduler.migrations {
+		migrated[record.deploymentId]++
+	}
+	for _, pod := range pods {
+		migrated[pod.Deployment.Id]++
+		if migrated[pod.Deployment.Id] > limit {
+			return fmt.Errorf("migrating more pods of deployment %d in the window is over the limit of %d", pod.Deployment.Id, limit)
+		}
+	}
+
+	return nil
+}
+
+// Records the pods deleted to be moved, for the migration rate limits.
+// Nothing is moved in dry run mode, so nothing is recorded.
+func (scheduler *Scheduler) recordMigrations(pods []*model.Pod) {
+	if config.SchedulerGeneralConfig.DryRun {
+		return
+	}
+
+	now := scheduler.now()
+	for _, pod := range pods {
+		scheduler.migrations = append(scheduler.migrations, migrationRecord{
+			time:         now,
+			deploymentId: pod.Deployment.Id,
+		})
+	}
+}
+
+// Returns an error if moving the pods of a reorder should be deferred,
+// so the disruption budgets and the migration rate limits are kept.
+func (scheduler *Scheduler) checkDisruptions(pods []*model.Pod) error {
+	if err := scheduler.checkDisruptionBudgets(pods); err != nil {
+		statistics.DeferredReorders.Inc(statistics.DISRUPTION_BUDGET)
+		return err
+	}
+
+	if err := scheduler.checkMigrationRate(pods); err != nil {
+		statistics.DeferredReorders.Inc(statistics.MIGRATION_RATE)
+		return err
+	}
+
+	return nil
+}
+
+// Returns the preemptions whose victims can be moved without breaking
+// the disruption budgets and the migration rate limits and records
+// their migrations, with the error of the first one that can not.
+// Each preemption is found assuming the ones before it are done,
+// so the ones after a deferred preemption are deferred too.
+// The victims that are new pods are not disrupted.
+func (scheduler *Scheduler) allowedPreemptions(
+	preemptions []*model.Preemption,
+	edgeMapping map[int]*model.Node,
+) ([]*model.Preemption, error) {
+	disrupted := make([]*model.Pod, 0)
+	var err error
+	for ind, preemption := range preemptions {
+		victims := slices.Clone(disrupted)
+		for _, victim := range preemption.Victims {
+			if _, isNew := edgeMapping[victim.Id]; !isNew {
+				victims = append(victims, scheduler.clusterState.PodsMap[victim.Id])
+			}
+		}
+
+		if err = scheduler.checkDisruptions(victims); err != nil {
+			preemptions = preemptions[:ind]
+			break
+		}
+		disrupted = victims
+	}
+	scheduler.recordMigrations(disrupted)
+
+	return preemptions, err
+}
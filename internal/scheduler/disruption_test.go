@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/connector"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestDisruptionControls(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.MigrationWindowDuration = 60000
+	config.SchedulerGeneralConfig.MaximumWindowMigrations = 3
+	config.SchedulerGeneralConfig.MaximumDeploymentWindowMigrations = 2
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	a := builder.Deployments["A"]
+	a.DisruptionBudget = &model.DisruptionBudget{
+		MinAvailable: &model.PodCount{Value: 75, IsPercent: true},
+	}
+
+	clusterState := builder.GetCluster(map[*testing_tool.NodeDesc][]string{
+		{Cpu: 4, Memory: 4}: {"A", "A", "A", "B"},
+	}, []string{"A", "B"}).Clone()
+	var aPods, bPods []*model.Pod
+	for _, pod := range clusterState.PodsMap {
+		if pod.Deployment == a {
+			aPods = append(aPods, pod)
+		} else {
+			bPods = append(bPods, pod)
+		}
+	}
+
+	clock := connector.NewVirtualClock()
+	scheduler := &Scheduler{
+		clusterState: clusterState,
+		clock:        clock,
+	}
+
+	// 3 of the 4 A pods should be running.
+	if err := scheduler.checkDisruptions(aPods[:1]); err != nil {
+		t.Errorf("moving an A pod is deferred: %s", err)
+	}
+	if err := scheduler.checkDisruptions(aPods[:2]); err == nil {
+		t.Errorf("moving two A pods breaks their disruption budget")
+	}
+	if err := scheduler.checkDisruptions(bPods); err != nil {
+		t.Errorf("moving the B pods without a budget is deferred: %s", err)
+	}
+
+	// The rate limits are of the whole cluster and of each deployment.
+	scheduler.recordMigrations(aPods[:2])
+	if err := scheduler.checkDisruptions(aPods[2:3]); err == nil {
+		t.Errorf("migrated more A pods than the deployment's limit")
+	}
+	if err := scheduler.checkDisruptions(bPods[:1]); err != nil {
+		t.Errorf("migrating a B pod is deferred: %s", err)
+	}
+	scheduler.recordMigrations(bPods[:1])
+	if err := scheduler.checkDisruptions(bPods[1:]); err == nil {
+		t.Errorf("migrated more pods than the cluster's limit")
+	}
+
+	// The migrations are forgotten after the window.
+	clock.AfterFunc(61*time.Second, func() {})
+	clock.Step()
+	if err := scheduler.checkDisruptions(bPods); err != nil {
+		t.Errorf("migrating the B pods after the window is deferred: %s", err)
+	}
+}
+
+func TestDryRunRecordsNoMigrations(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.DryRun = true
+	defer func() { config.SchedulerGeneralConfig.DryRun = false }()
+	config.SchedulerGeneralConfig.MigrationWindowDuration = 60000
+	config.SchedulerGeneralConfig.MaximumWindowMigrations = 1
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetCluster(map[*testing_tool.NodeDesc][]string{
+		{Cpu: 2, Memory: 2}: {"A", "A"},
+	}, []string{}).Clone()
+
+	scheduler := &Scheduler{
+		clusterState: clusterState,
+		clock:        connector.NewVirtualClock(),
+	}
+
+	// The plans that are only recorded move no pod.
+	scheduler.recordMigrations(clusterState.Edge.Pods)
+	if err := scheduler.checkDisruptions(clusterState.Edge.Pods[:1]); err != nil {
+		t.Errorf("dry run plans are charged against the migration limit: %s", err)
+	}
+}
+
+func TestAllowedPreemptions(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.MigrationWindowDuration = 60000
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	a := builder.Deployments["A"]
+	a.DisruptionBudget = &model.DisruptionBudget{
+		MaxUnavailable: &model.PodCount{Value: 1},
+	}
+
+	clusterState := builder.GetCluster(map[*testing_tool.NodeDesc][]string{
+		{Cpu: 4, Memory: 4}: {"A", "A", "A"},
+	}, []string{"A", "B"}).Clone()
+	aPods := clusterState.Edge.Pods
+	newPod := builder.GetPods([]string{"B"})[0]
+	node := clusterState.Edge.Config.Nodes[0]
+	edgeMapping := map[int]*model.Node{newPod.Id: node}
+
+	scheduler := &Scheduler{
+		clusterState: clusterState,
+		clock:        connector.NewVirtualClock(),
+	}
+
+	// The second preemption disrupts another A pod and the third
+	// one is found after it, the new pod is not disrupted.
+	preemptions := []*model.Preemption{
+		{Pod: builder.GetPods([]string{"B"})[0], Node: node, Victims: []*model.Pod{aPods[0], newPod}},
+		{Pod: builder.GetPods([]string{"B"})[0], Node: node, Victims: []*model.Pod{aPods[1]}},
+		{Pod: builder.GetPods([]string{"B"})[0], Node: node, Victims: []*model.Pod{}},
+	}
+	allowed, err := scheduler.allowedPreemptions(preemptions, edgeMapping)
+	if err == nil || len(allowed) != 1 || allowed[0] != preemptions[0] {
+		t.Fatalf("got %d allowed preemptions and error %v, wanted only the first one", len(allowed), err)
+	}
+	if len(scheduler.migrations) != 1 || scheduler.migrations[0].deploymentId != a.Id {
+		t.Errorf("recorded migrations %v, wanted the first victim's", scheduler.migrations)
+	}
+}
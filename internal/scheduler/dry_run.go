@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/amsen20/ecmus/alg"
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Actions of plan elements:
+const (
+	BIND_ACTION   = "bind"
+	DELETE_ACTION = "delete"
+	// binding a re-created pod of the deployment
+	MIGRATE_ACTION = "migrate"
+)
+
+// Maximum number of plans and placements kept by the dry run mode,
+// the oldest ones are forgotten first.
+const DRY_RUN_RECORD_LIMIT = 1000
+
+// A single action of a plan on the cluster.
+type PlannedAction struct {
+	Action string `json:"action"`
+	// -1 for pods that are not created yet
+	PodId        int `json:"pod"`
+	DeploymentId int `json:"deployment"`
+	// -1 if the pod has no node
+	NodeId int `json:"node"`
+}
+
+// A plan that the scheduler would have done, in dry run mode.
+type DryRunPlan struct {
+	Time    time.Time       `json:"time"`
+	Type    string          `json:"type"`
+	Actions []PlannedAction `json:"actions"`
+	// Change of the cluster's QoS score if the plan was done.
+	PredictedQoSDelta float64 `json:"predicted_qos_delta"`
+}
+
+// A placement that the other scheduler (the one which
+// actually binds pods) made, in dry run mode.
+type ObservedPlacement struct {
+	Time         time.Time `json:"time"`
+	PodId        int       `json:"pod"`
+	DeploymentId int       `json:"deployment"`
+	NodeId       int       `json:"node"`
+	// The node that the scheduler would have bound
+	// the pod on, -1 if it had no plan for the pod.
+	IntendedNodeId int `json:"intended_node"`
+}
+
+type dryRunRecord struct {
+	plans      []DryRunPlan
+	placements []ObservedPlacement
+	// the last node each pod was planned to be bound on
+	intendedNodes map[int]int
+}
+
+func newDryRunRecord() *dryRunRecord {
+	return &dryRunRecord{
+		intendedNodes: make(map[int]int),
+	}
+}
+
+func nodeIdOf(node *model.Node) int {
+	if node == nil {
+		return -1
+	}
+
+	return node.Id
+}
+
+// Records the plan instead of doing it, a plan
+// the same as the previous one of its type is ignored.
+func (scheduler *Scheduler) recordPlan(plan []*planElement, planType expectationType) {
+	record := scheduler.dryRunRecord
+
+	dryRunPlan := DryRunPlan{
+		Time: time.Now(),
+		Type: expectationTypeToString(planType),
+	}
+	for _, element := range plan {
+		if element.action != nil {
+			dryRunPlan.Actions = append(dryRunPlan.Actions, *element.action)
+		}
+	}
+
+	for ind := len(record.plans) - 1; ind >= 0; ind-- {
+		if record.plans[ind].Type != dryRunPlan.Type {
+			continue
+		}
+		if reflect.DeepEqual(record.plans[ind].Actions, dryRunPlan.Actions) {
+			return
+		}
+		break
+	}
+
+	dryRunPlan.PredictedQoSDelta = scheduler.predictQoSDelta(dryRunPlan.Actions)
+	for _, action := range dryRunPlan.Actions {
+		if action.Action == BIND_ACTION {
+			record.intendedNodes[action.PodId] = action.NodeId
+		}
+	}
+
+	log.Info().Msgf(
+		"dry run: would have done %d actions for %s, predicted QoS delta %f",
+		len(dryRunPlan.Actions),
+		dryRunPlan.Type,
+		dryRunPlan.PredictedQoSDelta,
+	)
+
+	record.plans = append(record.plans, dryRunPlan)
+	if len(record.plans) > DRY_RUN_RECORD_LIMIT {
+		record.plans = record.plans[len(record.plans)-DRY_RUN_RECORD_LIMIT:]
+	}
+}
+
+// Records where the other scheduler placed the pod.
+func (scheduler *Scheduler) observePlacement(pod *model.Pod, node *model.Node) {
+	record := scheduler.dryRunRecord
+
+	intendedNodeId, ok := record.intendedNodes[pod.Id]
+	if !ok {
+		intendedNodeId = -1
+	}
+	delete(record.intendedNodes, pod.Id)
+
+	log.Info().Msgf("dry run: pod %d is placed on node %d, intended node %d", pod.Id, node.Id, intendedNodeId)
+
+	record.placements = append(record.placements, ObservedPlacement{
+		Time:           time.Now(),
+		PodId:          pod.Id,
+		DeploymentId:   pod.Deployment.Id,
+		NodeId:         node.Id,
+		IntendedNodeId: intendedNodeId,
+	})
+	if len(record.placements) > DRY_RUN_RECORD_LIMIT {
+		record.placements = record.placements[len(record.placements)-DRY_RUN_RECORD_LIMIT:]
+	}
+}
+
+// Applies the actions on a clone of the cluster state
+// and returns how much the QoS score changes.
+func (scheduler *Scheduler) predictQoSDelta(actions []PlannedAction) float64 {
+	imgState := scheduler.clusterState.Clone()
+	nodeIdToNode := imgState.GetNodeIdToNode()
+
+	place := func(pod *model.Pod, nodeId int) {
+		node, ok := nodeIdToNode[nodeId]
+		if !ok {
+			return
+		}
+
+		if imgState.IsCloudNode(nodeId) {
+			imgState.DeployCloud(pod)
+		} else if err := imgState.DeployEdge(pod, node); err != nil {
+			imgState.DeployCloud(pod)
+		}
+	}
+
+	// deleted pods waiting for being re-created, by their deployment
+	deletedPods := make(map[int][]*model.Pod)
+
+	for _, action := range actions {
+		switch action.Action {
+		case DELETE_ACTION:
+			if pod, ok := imgState.PodsMap[action.PodId]; ok {
+				imgState.RemovePod(pod)
+				deletedPods[action.DeploymentId] = append(deletedPods[action.DeploymentId], pod)
+			}
+		case BIND_ACTION:
+			pod, ok := imgState.PodsMap[action.PodId]
+			if ok {
+				imgState.RemovePod(pod)
+			} else {
+				// A pending pod, which is not in the clone.
+				pendingPod, ok := scheduler.clusterState.PodsMap[action.PodId]
+				if !ok {
+					continue
+				}
+				pod = &model.Pod{
+					Id:         pendingPod.Id,
+					Deployment: pendingPod.Deployment,
+					Status:     pendingPod.Status,
+				}
+			}
+			place(pod, action.NodeId)
+		case MIGRATE_ACTION:
+			pods := deletedPods[action.DeploymentId]
+			if len(pods) == 0 {
+				continue
+			}
+			deletedPods[action.DeploymentId] = pods[1:]
+			place(pods[0], action.NodeId)
+		}
+	}
+
+	return qosScore(imgState) - qosScore(scheduler.clusterState)
+}
+
+func qosScore(clusterState *model.ClusterState) float64 {
+	qosResult, err := alg.CalcNumberOfQosSatisfactions(clusterState.Edge.Config, clusterState.Cloud.Pods, clusterState.Edge.Pods, nil, nil)
+	if err != nil {
+		log.Err(err).Msg("couldn't calculate the QoS score")
+
+		return 0
+	}
+
+	return qosResult.Score
+}
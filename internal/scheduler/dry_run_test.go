@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"os"
+	"testing"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func setUpConfig(t *testing.T) {
+	yamlFile, err := os.ReadFile("../../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(yamlFile); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDryRunRecordsPlans(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.DryRun = true
+	defer func() { config.SchedulerGeneralConfig.DryRun = false }()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetCluster(
+		map[*testing_tool.NodeDesc][]string{
+			{Cpu: 2, Memory: 2}: {"A"},
+		},
+		[]string{"A"},
+	)
+
+	// Any call to the connector would panic.
+	scheduler, err := New(clusterState, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := clusterState.Cloud.Pods[0]
+	node := clusterState.Edge.Config.Nodes[0]
+	plan := []*planElement{getBindPodPlanElement(scheduler, pod, node)}
+
+	scheduler.schedulePlan(plan, PLACING)
+	scheduler.schedulePlan(plan, PLACING)
+
+	if len(scheduler.expectations) != 0 {
+		t.Fatalf("dry run should not expect any event")
+	}
+	if len(scheduler.dryRunRecord.plans) != 1 {
+		t.Fatalf("got %d plans, wanted the repeated plan to be recorded once", len(scheduler.dryRunRecord.plans))
+	}
+
+	recorded := scheduler.dryRunRecord.plans[0]
+	if len(recorded.Actions) != 1 || recorded.Actions[0].Action != BIND_ACTION || recorded.Actions[0].NodeId != node.Id {
+		t.Fatalf("unexpected recorded actions %v", recorded.Actions)
+	}
+	if recorded.PredictedQoSDelta <= 0 {
+		t.Fatalf("moving a pod to edge should increase QoS, got delta %f", recorded.PredictedQoSDelta)
+	}
+	if pod.Node == node {
+		t.Fatalf("dry run should not change the cluster state")
+	}
+
+	scheduler.observePlacement(pod, clusterState.Cloud.Nodes[0])
+	placement := scheduler.dryRunRecord.placements[0]
+	if placement.IntendedNodeId != node.Id || placement.NodeId != clusterState.Cloud.Nodes[0].Id {
+		t.Fatalf("unexpected observed placement %+v", placement)
+	}
+}
@@ -25,6 +25,9 @@ type planElement struct {
 	do      func(*connector.Event) error
 	isValid func(*connector.Event) bool
 	after   func(*connector.Event) error
+	// what the plan element does to the cluster,
+	// nil if it only waits for an event.
+	action *PlannedAction
 }
 
 func expectationTypeToString(tp expectationType) string {
@@ -36,6 +39,12 @@ func expectationTypeToString(tp expectationType) string {
 
 func getDeletePodPlanElement(scheduler *Scheduler, pod *model.Pod) *planElement {
 	return &planElement{
+		action: &PlannedAction{
+			Action:       DELETE_ACTION,
+			PodId:        pod.Id,
+			DeploymentId: pod.Deployment.Id,
+			NodeId:       nodeIdOf(pod.Node),
+		},
 		do: func(event *connector.Event) error {
 			if scheduler.clusterState.NumberOfRunningPods[pod.Deployment.Id] <= 1 && pod.Status == model.RUNNING {
 				return fmt.Errorf("should not delete this pod because this is the only one running")
@@ -85,6 +94,12 @@ func getCreatePodPlanElement(scheduler *Scheduler, deployment *model.Deployment)
 
 func getMigrateBindPodPlanElement(scheduler *Scheduler, deployment *model.Deployment, node *model.Node) *planElement {
 	return &planElement{
+		action: &PlannedAction{
+			Action:       MIGRATE_ACTION,
+			PodId:        -1,
+			DeploymentId: deployment.Id,
+			NodeId:       node.Id,
+		},
 		do: func(event *connector.Event) error {
 			err := scheduler.connector.Deploy(event.Pod, node)
 			if err != nil {
@@ -103,6 +118,7 @@ func getMigrateBindPodPlanElement(scheduler *Scheduler, deployment *model.Deploy
 			if _, ok := scheduler.clusterState.NodeResourcesUsed[node.Id]; ok {
 				err = scheduler.clusterState.DeployEdge(event.Pod, event.Node)
 			} else {
+				event.Pod.Node = event.Node
 				scheduler.clusterState.DeployCloud(event.Pod)
 			}
 			if err != nil {
@@ -117,6 +133,12 @@ func getMigrateBindPodPlanElement(scheduler *Scheduler, deployment *model.Deploy
 
 func getBindPodPlanElement(scheduler *Scheduler, pod *model.Pod, node *model.Node) *planElement {
 	return &planElement{
+		action: &PlannedAction{
+			Action:       BIND_ACTION,
+			PodId:        pod.Id,
+			DeploymentId: pod.Deployment.Id,
+			NodeId:       node.Id,
+		},
 		do: func(event *connector.Event) error {
 			err := scheduler.connector.Deploy(pod, node)
 			if err != nil {
@@ -138,6 +160,7 @@ func getBindPodPlanElement(scheduler *Scheduler, pod *model.Pod, node *model.Nod
 			if _, ok := scheduler.clusterState.NodeResourcesUsed[node.Id]; ok {
 				err = scheduler.clusterState.DeployEdge(pod, event.Node)
 			} else {
+				pod.Node = event.Node
 				scheduler.clusterState.DeployCloud(pod)
 			}
 			if err != nil {
@@ -149,3 +172,56 @@ func getBindPodPlanElement(scheduler *Scheduler, pod *model.Pod, node *model.Nod
 		},
 	}
 }
+
+// Returns the plan elements of binding the pods of a group on the nodes,
+// the first element binds all of them and each element waits for one
+// of them to be bound. The pods are bound at once, so each element
+// waits for any of the pods whose binding is not seen yet.
+// If a pod can not be bound, the pods bound before
+// it are deleted, so either all or none of them are bound.
+func getBindPodGroupPlanElements(scheduler *Scheduler, pods []*model.Pod, nodes []*model.Node) []*planElement {
+	bindings := make(map[int]*planElement)
+	for ind, pod := range pods {
+		bindings[pod.Id] = getBindPodPlanElement(scheduler, pod, nodes[ind])
+	}
+	seen := make(map[int]bool)
+
+	elements := make([]*planElement, 0, len(pods))
+	for ind, pod := range pods {
+		element := getBindPodPlanElement(scheduler, pod, nodes[ind])
+		element.do = func(event *connector.Event) error {
+			return nil
+		}
+		element.isValid = func(event *connector.Event) bool {
+			binding, ok := bindings[event.Pod.Id]
+			return ok && !seen[event.Pod.Id] && event.Node != nil && binding.isValid(event)
+		}
+		element.after = func(event *connector.Event) error {
+			seen[event.Pod.Id] = true
+			return bindings[event.Pod.Id].after(event)
+		}
+		elements = append(elements, element)
+	}
+
+	elements[0].do = func(event *connector.Event) error {
+		for ind, pod := range pods {
+			err := scheduler.connector.Deploy(pod, nodes[ind])
+			if err == nil {
+				log.Info().Msgf("--- binding pod %d on node %d", pod.Id, nodes[ind].Id)
+				continue
+			}
+
+			for _, boundPod := range pods[:ind] {
+				if _, deleteErr := scheduler.connector.DeletePod(boundPod); deleteErr != nil {
+					log.Err(deleteErr).Msgf("could not delete pod %d of the group", boundPod.Id)
+				}
+			}
+
+			return fmt.Errorf("could not bind pod %d of group %s, deleted the %d bound pods: %w", pod.Id, groupNameOf(pod), ind, err)
+		}
+
+		return nil
+	}
+
+	return elements
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/connector"
+)
+
+// The scheduler should see the cluster as it is at most
+// this long after the faults are gone, it is a bit more
+// than two health checks and a recover retry.
+const CONVERGENCE_BOUND = 3 * time.Minute
+
+func TestFaultyConnector(t *testing.T) {
+	faultFrom := 20 * time.Second
+	faultUntil := 30 * time.Second
+
+	for _, kind := range []connector.FaultKind{
+		connector.BIND_ERROR,
+		connector.DELETE_ERROR,
+		connector.UNWANTED_NODE,
+		connector.WATCH_CLOSED,
+		connector.LIST_ERROR,
+	} {
+		t.Run(kind.String(), func(t *testing.T) {
+			scheduler, sim, builder := newSimScheduler(t, connector.DefaultSimConfig)
+
+			clock := sim.Clock()
+			faulty := connector.NewFaultyConnector(sim, clock, []connector.Fault{
+				{Kind: kind, From: faultFrom, Until: faultUntil},
+			})
+			scheduler.connector = faulty
+
+			// New pods are placed and the freed edge resources
+			// make pods on cloud migrate to edge during the faults.
+			clock.AfterFunc(faultFrom, func() {
+				sim.Scale(builder.Deployments["C"].Id, 4)
+				sim.Scale(builder.Deployments["A"].Id, 0)
+			})
+			clock.AfterFunc(faultFrom+5*time.Second, func() {
+				sim.Scale(builder.Deployments["D"].Id, 0)
+				sim.Scale(builder.Deployments["B"].Id, 3)
+			})
+
+			if err := scheduler.RunOnClock(clock, faultUntil); err != nil {
+				t.Fatal(err)
+			}
+			if faulty.Injected(kind) == 0 {
+				t.Fatalf("the fault is never injected")
+			}
+
+			for now := faultUntil; len(divergence(scheduler, sim)) > 0; now += time.Second {
+				if now-faultUntil > CONVERGENCE_BOUND {
+					t.Errorf("not converged %s after the faults", CONVERGENCE_BOUND)
+					break
+				}
+				if err := scheduler.RunOnClock(clock, now); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expectConverged(t, scheduler, sim)
+		})
+	}
+}
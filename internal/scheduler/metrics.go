@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/amsen20/ecmus/alg"
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/statistics"
+)
+
+// How often the scheduler's main life cycle updates the metrics.
+const METRICS_UPDATE_DURATION = time.Second
+
+// Updates the gauges describing the scheduler's view of the cluster.
+// The labeled gauges are replaced at once, so a scrape never
+// sees the series of the deployments or nodes missing.
+func (scheduler *Scheduler) updateMetrics() {
+	statistics.BufferLength.Set(float64(len(scheduler.newPodBuffer)), "new_pods")
+	statistics.BufferLength.Set(float64(len(scheduler.expectations)), "expectations")
+
+	clusterState := scheduler.clusterState
+
+	qosResult, err := alg.CalcNumberOfQosSatisfactions(clusterState.Edge.Config, clusterState.Cloud.Pods, clusterState.Edge.Pods, nil, nil)
+	if err != nil {
+		// The last values are kept.
+		log.Err(err).Msg("couldn't calculate deployments' QoS for metrics")
+	} else {
+		var qos, edgeShares, promisedEdgeShares []statistics.GaugeValue
+		for deploymentId, info := range qosResult.DeploymentsQoS {
+			deployment := clusterState.Edge.Config.DeploymentIdToDeployment[deploymentId]
+			edgeShare := info.EffectiveShare()
+			labelValues := []string{strconv.Itoa(deploymentId)}
+
+			qos = append(qos, statistics.GaugeValue{Value: alg.QoS(edgeShare, deployment.EdgeShare), LabelValues: labelValues})
+			edgeShares = append(edgeShares, statistics.GaugeValue{Value: edgeShare, LabelValues: labelValues})
+			promisedEdgeShares = append(promisedEdgeShares, statistics.GaugeValue{Value: deployment.EdgeShare, LabelValues: labelValues})
+		}
+		statistics.DeploymentQoS.SetAll(qos)
+		statistics.DeploymentEdgeShare.SetAll(edgeShares)
+		statistics.DeploymentPromisedEdgeShare.SetAll(promisedEdgeShares)
+	}
+
+	statistics.SpreadViolations.Set(float64(alg.SpreadViolations(clusterState)))
+
+	var utilizations []statistics.GaugeValue
+	for _, node := range clusterState.Edge.Config.Nodes {
+		used, ok := clusterState.NodeResourcesUsed[node.Id]
+		if !ok {
+			continue
+		}
+
+		for ind, resource := range config.SchedulerGeneralConfig.Resources {
+			if node.Resources.AtVec(ind) <= 0 {
+				continue
+			}
+
+			utilizations = append(utilizations, statistics.GaugeValue{
+				Value:       used.AtVec(ind) / node.Resources.AtVec(ind),
+				LabelValues: []string{strconv.Itoa(node.Id), resource.Name},
+			})
+		}
+	}
+	statistics.EdgeNodeUtilization.SetAll(utilizations)
+}
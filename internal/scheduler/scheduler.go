@@ -3,13 +3,14 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/amsen20/ecmus/alg"
+	"github.com/amsen20/ecmus/internal/audit"
 	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/connector"
 	"github.com/amsen20/ecmus/internal/model"
-	"github.com/amsen20/ecmus/internal/utils"
 	"github.com/amsen20/ecmus/logging"
 	"github.com/amsen20/ecmus/statistics"
 	"github.com/google/uuid"
@@ -29,33 +30,88 @@ const (
 type Scheduler struct {
 	clusterState *model.ClusterState
 	connector    connector.Connector
+	algorithm    alg.Algorithm
+	// the algorithm's name, for auditing
+	algorithmName string
+	auditLog      *audit.Log
 
-	goingToPlace map[int]bool
+	// pods that the plans bind, by their ids, until the plans are flushed
+	goingToPlace map[int]*model.Pod
 	newPodBuffer []*model.Pod
 
 	// Expectations must be either all PLACING or REORDERING not a mixture of them.
 	// TODO refactor this to a interface with two implementations PLACING and REORDERING
 	expectations               []*expectation
 	expectedReorderDeployments map[int]int
+	// pods deleted by reorders in the current migration window
+	migrations []migrationRecord
 
 	healthCheckSample *healthCheckSample
+
+	eventStream <-chan *connector.Event
+	// runs the function in the scheduler's life cycle after the duration
+	afterFunc func(time.Duration, func())
+	// only when running on a virtual clock
+	clock *connector.VirtualClock
+
+	// only in dry run mode
+	dryRunRecord *dryRunRecord
 }
 
 type SchedulerBridge struct {
 	ClusterStateRequestStream chan<- struct{}
 	ClusterStateStream        <-chan *model.ClusterState
+	SnapshotRequestStream     chan<- struct{}
+	SnapshotStream            <-chan *Snapshot
+	AuditLog                  *audit.Log
 	CanSchedule               chan<- struct{}
 	CanSuggestCloud           chan<- struct{}
+	// closed when the scheduler's life cycle ends
+	Stopped <-chan struct{}
 }
 
 func New(clusterState *model.ClusterState, connector connector.Connector) (*Scheduler, error) {
-	return &Scheduler{
+	algorithm, err := alg.Get(config.SchedulerGeneralConfig.Algorithm)
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not find the scheduling algorithm")
+	}
+
+	auditLog, err := audit.New(config.SchedulerGeneralConfig.AuditLogPath)
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not create the audit log")
+	}
+
+	algorithmName := config.SchedulerGeneralConfig.Algorithm
+	if algorithmName == "" {
+		algorithmName = alg.QOS_ALGORITHM
+	}
+
+	scheduler := &Scheduler{
 		clusterState: clusterState,
 		connector:    connector,
+		algorithm:    algorithm,
 
-		goingToPlace:               make(map[int]bool),
+		algorithmName: algorithmName,
+		auditLog:      auditLog,
+
+		goingToPlace:               make(map[int]*model.Pod),
 		expectedReorderDeployments: make(map[int]int),
-	}, nil
+
+		afterFunc: func(d time.Duration, f func()) {
+			time.Sleep(d)
+			f()
+		},
+	}
+	if config.SchedulerGeneralConfig.DryRun {
+		log.Warn().Msg("the scheduler is in dry run mode, no pod will be bound or deleted")
+		scheduler.dryRunRecord = newDryRunRecord()
+	}
+
+	return scheduler, nil
 }
 
 func (scheduler *Scheduler) Start() error {
@@ -81,7 +137,7 @@ func (scheduler *Scheduler) Start() error {
 func (scheduler *Scheduler) flushExpectations(reschedule bool) {
 	log.Info().Msg("flushing expectations")
 
-	scheduler.goingToPlace = make(map[int]bool)
+	scheduler.goingToPlace = make(map[int]*model.Pod)
 	scheduler.expectedReorderDeployments = make(map[int]int)
 
 	scheduler.expectations = nil
@@ -92,12 +148,7 @@ func (scheduler *Scheduler) flushExpectations(reschedule bool) {
 
 func (scheduler *Scheduler) nextExpectation() {
 	if len(scheduler.expectations) > 0 {
-		statistics.Change(
-			fmt.Sprintf(
-				"expectation type %s done", expectationTypeToString(
-					scheduler.expectations[0].tp,
-				)), 1,
-		)
+		statistics.ExpectationsDone.Inc(expectationTypeToString(scheduler.expectations[0].tp))
 
 		scheduler.expectations = scheduler.expectations[1:]
 	}
@@ -112,10 +163,29 @@ func (scheduler *Scheduler) handleEvent(event *connector.Event) {
 		event,
 	)
 
+	if event.EventType == connector.RESYNC_REQUIRED {
+		scheduler.recoverHealth()
+		return
+	}
+
+	if event.IsTopologyEvent() {
+		scheduler.handleTopologyEvent(event)
+		return
+	}
+
+	// Connectors translate the events off the scheduler's goroutine,
+	// so the pods that are not known yet are registered here.
 	pod, ok := scheduler.clusterState.PodsMap[event.Pod.Id]
-	if !ok {
+	switch {
+	case !ok && event.EventType == connector.POD_DELETED:
+		return
+	case !ok:
+		pod = event.Pod
+		scheduler.clusterState.PodsMap[pod.Id] = pod
+	case event.EventType == connector.POD_CREATED:
 		return
 	}
+	event.Pod = pod
 	log.Info().Msgf("%v", pod)
 
 	podCreation := event.EventType == connector.POD_CREATED
@@ -163,25 +233,23 @@ func (scheduler *Scheduler) handleEvent(event *connector.Event) {
 				podNodeId = pod.Node.Id
 			}
 
-			log.Error().Msgf(
-				"pod changed to a unwanted node, wanted %d, got %d",
-				podNodeId,
-				event.Node.Id,
-			)
+			if config.SchedulerGeneralConfig.DryRun {
+				// Pods are bound by another scheduler.
+				scheduler.observePlacement(pod, event.Node)
+			} else {
+				log.Error().Msgf(
+					"pod changed to a unwanted node, wanted %d, got %d",
+					podNodeId,
+					event.Node.Id,
+				)
+			}
 
 			if pod.Node != nil {
 				scheduler.clusterState.RemovePod(pod)
 			}
 
-			isCloud := false
-			for _, cloud_node := range scheduler.clusterState.Cloud.Nodes {
-				if cloud_node.Id == event.Node.Id {
-					isCloud = true
-					break
-				}
-			}
-
-			if isCloud {
+			if scheduler.clusterState.IsCloudNode(event.Node.Id) {
+				pod.Node = event.Node
 				scheduler.clusterState.DeployCloud(pod)
 			} else {
 				scheduler.clusterState.DeployEdge(pod, event.Node)
@@ -216,6 +284,56 @@ func (scheduler *Scheduler) handleEvent(event *connector.Event) {
 	}
 }
 
+// Applies changes of cluster's nodes and deployments to
+// the cluster state, if any pod is affected the current
+// expectations are not valid anymore and the scheduler re-plans.
+func (scheduler *Scheduler) handleTopologyEvent(event *connector.Event) {
+	var affectedPods []*model.Pod
+	var ok bool
+
+	switch event.EventType {
+	case connector.NODE_ADDED:
+		scheduler.clusterState.AddNode(event.Node, event.NodeType)
+		ok = true
+	case connector.NODE_CHANGED:
+		affectedPods, ok = scheduler.clusterState.UpdateNode(event.Node)
+	case connector.NODE_REMOVED:
+		affectedPods, ok = scheduler.clusterState.RemoveNode(event.Node.Id)
+	case connector.NODE_RESERVED_CHANGED:
+		ok = scheduler.clusterState.SetNodeReserved(event.Node.Id, event.Reserved)
+	case connector.DEPLOYMENT_ADDED:
+		ok = scheduler.clusterState.Edge.Config.AddDeployment(event.Deployment)
+	case connector.DEPLOYMENT_CHANGED:
+		affectedPods, ok = scheduler.clusterState.UpdateDeployment(event.Deployment)
+		// Buffered pods are not in the cluster state yet.
+		for _, pod := range scheduler.newPodBuffer {
+			if pod.Deployment.Id == event.Deployment.Id {
+				pod.Deployment = event.Deployment
+			}
+		}
+	case connector.DEPLOYMENT_DELETED:
+		affectedPods, ok = scheduler.clusterState.RemoveDeployment(event.Deployment.Id)
+
+		var filteredBuffer []*model.Pod
+		for _, pod := range scheduler.newPodBuffer {
+			if pod.Deployment.Id != event.Deployment.Id {
+				filteredBuffer = append(filteredBuffer, pod)
+			}
+		}
+		scheduler.newPodBuffer = filteredBuffer
+	}
+
+	if !ok {
+		log.Warn().Msgf("couldn't apply the topology event, ignoring it")
+		return
+	}
+
+	if len(affectedPods) > 0 || event.EventType == connector.NODE_REMOVED || event.EventType == connector.DEPLOYMENT_DELETED {
+		log.Info().Msgf("topology has changed, %d pods affected, re-planning", len(affectedPods))
+		scheduler.flushExpectations(true)
+	}
+}
+
 func (scheduler *Scheduler) schedulePlan(plan []*planElement, planType expectationType) {
 	log.Info().Msg("scheduling plan")
 	if len(plan) == 0 {
@@ -223,10 +341,16 @@ func (scheduler *Scheduler) schedulePlan(plan []*planElement, planType expectati
 		return
 	}
 
+	if config.SchedulerGeneralConfig.DryRun {
+		scheduler.recordPlan(plan, planType)
+		return
+	}
+
 	log.Info().Msg("planning")
 	if err := plan[0].do(nil); err != nil {
 		log.Err(err).Send()
 		scheduler.flushExpectations(false)
+		return
 	}
 
 	for i := 0; i < len(plan)-1; i++ {
@@ -268,7 +392,7 @@ func (scheduler *Scheduler) schedulePlan(plan []*planElement, planType expectati
 		tp: planType,
 	})
 
-	statistics.Change(fmt.Sprintf("expectation type %s added", expectationTypeToString(planType)), len(plan))
+	statistics.ExpectationsAdded.Add(float64(len(plan)), expectationTypeToString(planType))
 }
 
 func (scheduler *Scheduler) schedule() {
@@ -361,39 +485,194 @@ func (scheduler *Scheduler) schedule() {
 		scheduler.flushExpectations(false)
 	}
 
-	newPodsLength := utils.Min(len(scheduler.newPodBuffer), config.SchedulerGeneralConfig.BatchSize)
-	newPods := scheduler.newPodBuffer[:newPodsLength]
-	scheduler.newPodBuffer = scheduler.newPodBuffer[newPodsLength:]
+	if len(scheduler.clusterState.Cloud.Nodes) == 0 {
+		log.Warn().Msg("there is no cloud node, postponing the scheduling")
+		return
+	}
 
-	decision := alg.MakeDecisionForNewPods(scheduler.clusterState, newPods, false)
+	newPods := scheduler.takeBatch()
+	if len(newPods) == 0 {
+		log.Info().Msg("all new pods are waiting for the rest of their groups")
+		return
+	}
+
+	decisionStart := time.Now()
+	decision := scheduler.algorithm.DecideForNewPods(scheduler.clusterState, newPods, false)
+	statistics.DecisionDuration.Observe(time.Since(decisionStart).Seconds(), statistics.DECIDE_FOR_NEW_PODS)
 
 	log.Info().Msgf("decision has been made %v", decision)
 
-	cloudNode := scheduler.clusterState.Cloud.Nodes[0]
+	imgState := scheduler.clusterState.Clone()
+	cloudMapping := make(map[int]*model.Node)
+
+	// Pods mapped to edge are deployed in the image state first,
+	// so the preemptions see the edge nodes' resources they use.
+	edgePodMapping := scheduler.algorithm.MapPodToEdge(scheduler.clusterState, decision.ToEdgePods, decision.EdgeToCloudOffloadingPods, decision.Migrations)
+	if edgePodMapping.Violations > 0 {
+		log.Warn().Msgf("the edge mapping is %d pods over the deployments' zone skews", edgePodMapping.Violations)
+	}
+	edgeMapping := edgePodMapping.Mapping
+	// A group's pods are bound on edge only if all of them are mapped to edge.
+	for _, name := range alg.KeepPodGroupsTogether(newPods, edgeMapping) {
+		log.Info().Msgf("not all pods of group %s can go to edge, placing the group on cloud", name)
+	}
+	cloudPods := make([]*model.Pod, 0)
+	cloudPods = append(cloudPods, decision.ToCloudPods...)
+	for _, pod := range decision.ToEdgePods {
+		if node, ok := edgeMapping[pod.Id]; ok {
+			imgState.DeployEdge(&model.Pod{
+				Id:         pod.Id,
+				Deployment: pod.Deployment,
+				Status:     pod.Status,
+				Group:      pod.Group,
+			}, node)
+		} else {
+			cloudPods = append(cloudPods, pod)
+		}
+	}
+
+	preemptions, err := scheduler.allowedPreemptions(alg.Preempt(imgState, cloudPods), edgeMapping)
+	if err != nil {
+		log.Info().Msgf("deferred the preemptions: %s", err)
+	}
+	preemptionPlan, victimIds := scheduler.applyPreemptions(imgState, preemptions, edgeMapping, cloudMapping)
+	preemptorIds := make(map[int]bool)
+	for _, preemption := range preemptions {
+		preemptorIds[preemption.Pod.Id] = true
+	}
+
+	for _, pod := range cloudPods {
+		if !preemptorIds[pod.Id] {
+			cloudMapping[pod.Id] = placeOnCloud(imgState, pod)
+		}
+	}
+	scheduler.auditDecision(audit.PLACING, newPods, decision, edgeMapping, cloudMapping, preemptions)
 
 	var plan []*planElement
-	for _, pod := range decision.ToCloudPods {
-		plan = append(plan, getBindPodPlanElement(scheduler, pod, cloudNode))
+	// The pods of each group are bound together, after the other pods.
+	groupPods := make(map[string][]*model.Pod)
+	groupNodes := make(map[string][]*model.Node)
+	bind := func(pod *model.Pod, node *model.Node) {
+		scheduler.goingToPlace[pod.Id] = pod
+		if pod.Group == nil {
+			plan = append(plan, getBindPodPlanElement(scheduler, pod, node))
+			return
+		}
 
-		scheduler.goingToPlace[pod.Id] = true
+		groupPods[pod.Group.Name] = append(groupPods[pod.Group.Name], pod)
+		groupNodes[pod.Group.Name] = append(groupNodes[pod.Group.Name], node)
 	}
 
-	edgeMapping := alg.MapPodToEdge(scheduler.clusterState, decision.ToEdgePods, decision.EdgeToCloudOffloadingPods, decision.Migrations).Mapping
+	for _, pod := range decision.ToCloudPods {
+		if preemptorIds[pod.Id] {
+			continue
+		}
+
+		bind(pod, cloudMapping[pod.Id])
+	}
 
 	for _, pod := range decision.ToEdgePods {
+		if preemptorIds[pod.Id] {
+			continue
+		}
+
 		if node, ok := edgeMapping[pod.Id]; ok {
-			plan = append(plan, getBindPodPlanElement(scheduler, pod, node))
+			bind(pod, node)
 		} else {
-			log.Warn().Msgf("couldn't deploy pod %d on edge, deploying on cloud", pod.Id)
-			plan = append(plan, getBindPodPlanElement(scheduler, pod, cloudNode))
+			if !victimIds[pod.Id] {
+				log.Warn().Msgf("couldn't deploy pod %d on edge, deploying on cloud", pod.Id)
+			}
+			bind(pod, cloudMapping[pod.Id])
 		}
+	}
 
-		scheduler.goingToPlace[pod.Id] = true
+	for _, pod := range newPods {
+		name := groupNameOf(pod)
+		if pods, ok := groupPods[name]; ok {
+			plan = append(plan, getBindPodGroupPlanElements(scheduler, pods, groupNodes[name])...)
+			delete(groupPods, name)
+		}
 	}
+	// Preemptors are bound after all the other pods,
+	// when their victims are evicted.
+	plan = append(plan, preemptionPlan...)
 
 	scheduler.schedulePlan(plan, PLACING)
 }
 
+// Applies the preemptions on the image state and returns the plan
+// of evicting the victims to cloud and binding the preemptors, and the
+// ids of the victims.
+// Victims which are new pods are not on edge yet, so they are only
+// mapped to cloud instead of edge.
+func (scheduler *Scheduler) applyPreemptions(
+	imgState *model.ClusterState,
+	preemptions []*model.Preemption,
+	edgeMapping map[int]*model.Node,
+	cloudMapping map[int]*model.Node,
+) ([]*planElement, map[int]bool) {
+	plan := make([]*planElement, 0)
+	victimIds := make(map[int]bool)
+
+	for _, preemption := range preemptions {
+		log.Info().Msgf(
+			"pod %d preempts %d pods on node %d",
+			preemption.Pod.Id,
+			len(preemption.Victims),
+			preemption.Node.Id,
+		)
+
+		for _, victim := range preemption.Victims {
+			victimIds[victim.Id] = true
+
+			imgVictim := imgState.PodsMap[victim.Id]
+			imgState.RemovePod(imgVictim)
+			imgState.DeployCloud(imgVictim)
+			cloudMapping[victim.Id] = imgVictim.Node
+
+			if _, isNew := edgeMapping[victim.Id]; isNew {
+				delete(edgeMapping, victim.Id)
+				continue
+			}
+
+			pod := scheduler.clusterState.PodsMap[victim.Id]
+			plan = append(plan, getDeletePodPlanElement(scheduler, pod))
+			plan = append(plan, getCreatePodPlanElement(scheduler, pod.Deployment))
+			plan = append(plan, getMigrateBindPodPlanElement(scheduler, pod.Deployment, imgVictim.Node))
+			scheduler.expectedReorderDeployments[pod.Deployment.Id] += 1
+		}
+
+		pod := preemption.Pod
+		imgState.DeployEdge(&model.Pod{
+			Id:         pod.Id,
+			Deployment: pod.Deployment,
+			Status:     pod.Status,
+		}, preemption.Node)
+		edgeMapping[pod.Id] = preemption.Node
+		delete(cloudMapping, pod.Id)
+
+		plan = append(plan, getBindPodPlanElement(scheduler, pod, preemption.Node))
+		scheduler.goingToPlace[pod.Id] = pod
+	}
+
+	return plan, victimIds
+}
+
+// Deploys a copy of the pod on cloud in the image state and returns
+// the chosen cloud node, so the pods placed after it in the same
+// image state see the node's resources used.
+func placeOnCloud(imgState *model.ClusterState, pod *model.Pod) *model.Node {
+	imgPod := &model.Pod{
+		Id:         pod.Id,
+		Deployment: pod.Deployment,
+		Status:     pod.Status,
+		Group:      pod.Group,
+	}
+	imgState.DeployCloud(imgPod)
+
+	return imgPod.Node
+}
+
 func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion) {
 	log.Info().Msg("checking suggestion")
 	if len(scheduler.expectations) != 0 {
@@ -401,12 +680,19 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 		return
 	}
 
+	if len(scheduler.clusterState.Cloud.Nodes) == 0 {
+		log.Warn().Msg("there is no cloud node, ignored the suggestion")
+		return
+	}
+
 	// Resetting everything.
 	scheduler.flushExpectations(false)
 
-	cloudNode := scheduler.clusterState.Cloud.Nodes[0]
+	cloudMapping := make(map[int]*model.Node)
 	updatedDecision := model.DecisionForNewPods{}
 	plan := make([]*planElement, 0)
+	// pods that the plan deletes to move them
+	migratedPods := make([]*model.Pod, 0)
 
 	canBeFreedFromCloud := make(map[int]*model.Pod)
 	for _, suggestionPod := range suggestion.CloudToEdgePods {
@@ -419,7 +705,7 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 			continue
 		}
 
-		if pod.Node == cloudNode {
+		if scheduler.clusterState.IsCloudNode(pod.Node.Id) {
 			canBeFreedFromCloud[pod.Id] = pod
 		}
 	}
@@ -442,6 +728,7 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 				Deployment: pod.Deployment,
 				Node:       pod.Node,
 				Status:     pod.Status,
+				Group:      pod.Group,
 			}
 		}
 		return imgPod
@@ -449,18 +736,20 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 
 	for _, suggestionPod := range suggestion.Decision.EdgeToCloudOffloadingPods {
 		pod, ok := scheduler.clusterState.PodsMap[suggestionPod.Id]
-		if !ok || pod.Node == nil || pod.Node.Id == cloudNode.Id {
+		if !ok || pod.Node == nil || scheduler.clusterState.IsCloudNode(pod.Node.Id) {
 			// Either already deleted or placed on cloud.
 			continue
 		}
 
-		plan = append(plan, getDeletePodPlanElement(scheduler, pod))
-		plan = append(plan, getCreatePodPlanElement(scheduler, pod.Deployment))
-		plan = append(plan, getMigrateBindPodPlanElement(scheduler, pod.Deployment, cloudNode))
-
 		imgPod := getImgPod(pod)
 		imgState.RemovePod(imgPod)
 		imgState.DeployCloud(imgPod)
+		cloudMapping[pod.Id] = imgPod.Node
+
+		plan = append(plan, getDeletePodPlanElement(scheduler, pod))
+		plan = append(plan, getCreatePodPlanElement(scheduler, pod.Deployment))
+		plan = append(plan, getMigrateBindPodPlanElement(scheduler, pod.Deployment, imgPod.Node))
+		migratedPods = append(migratedPods, pod)
 
 		updatedDecision.EdgeToCloudOffloadingPods = append(
 			updatedDecision.EdgeToCloudOffloadingPods,
@@ -472,13 +761,18 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 		pod, ok := scheduler.clusterState.PodsMap[suggestedMigration.Pod.Id]
 		node := suggestedMigration.Node
 
-		if !ok || suggestedMigration.Pod.Node.Id != node.Id {
-			// Already deleted or changed the node.
+		if node == nil {
+			log.Error().Msgf("suggested migrating pod %d to no node, skipped it", suggestedMigration.Pod.Id)
 			continue
 		}
 
-		if node == nil {
-			panic("TRIED TO MIGRATE A POD WHICH DOES NOT HAVE NODE")
+		if !ok || nodeIdOf(pod.Node) != nodeIdOf(suggestedMigration.Pod.Node) {
+			// Already deleted or changed the node.
+			continue
+		}
+		if nodeIdOf(pod.Node) == node.Id {
+			// Stays on its node, migrating it only re-creates it.
+			continue
 		}
 
 		imgPod := getImgPod(pod)
@@ -487,6 +781,7 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 		if pod.Node != nil {
 			// Need to be migrated:
 			plan = append(plan, getDeletePodPlanElement(scheduler, pod))
+			migratedPods = append(migratedPods, pod)
 			plan = append(plan, getCreatePodPlanElement(scheduler, pod.Deployment))
 
 			if err := imgState.DeployEdge(imgPod, node); err == nil {
@@ -505,12 +800,12 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 				// * to be done by the suggestion system.
 
 				imgState.DeployCloud(imgPod)
-				plan = append(plan, getMigrateBindPodPlanElement(scheduler, pod.Deployment, cloudNode))
+				plan = append(plan, getMigrateBindPodPlanElement(scheduler, pod.Deployment, imgPod.Node))
 
 				updatedDecision.Migrations = append(
 					updatedDecision.Migrations, &model.Migration{
 						Pod:  pod,
-						Node: cloudNode,
+						Node: imgPod.Node,
 					},
 				)
 			}
@@ -528,13 +823,13 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 				)
 			} else {
 				imgState.DeployCloud(imgPod)
-				plan = append(plan, getBindPodPlanElement(scheduler, pod, cloudNode))
+				plan = append(plan, getBindPodPlanElement(scheduler, pod, imgPod.Node))
 
 				updatedDecision.Migrations = append(
 					updatedDecision.Migrations,
 					&model.Migration{
 						Pod:  pod,
-						Node: cloudNode,
+						Node: imgPod.Node,
 					},
 				)
 			}
@@ -544,7 +839,7 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 	// ToCloudPods are already on cloud,
 	// so nothing to do with decision.ToCloudPods.
 
-	edgeMapping := alg.MapPodToEdge(scheduler.clusterState, updatedDecision.ToEdgePods, updatedDecision.EdgeToCloudOffloadingPods, updatedDecision.Migrations).Mapping
+	edgeMapping := scheduler.algorithm.MapPodToEdge(scheduler.clusterState, updatedDecision.ToEdgePods, updatedDecision.EdgeToCloudOffloadingPods, updatedDecision.Migrations).Mapping
 
 	for _, pod := range updatedDecision.ToEdgePods {
 		if node, ok := edgeMapping[pod.Id]; ok {
@@ -552,11 +847,29 @@ func (scheduler *Scheduler) checkSuggestion(suggestion model.ReorderSuggestion)
 			plan = append(plan, getDeletePodPlanElement(scheduler, pod))
 			plan = append(plan, getCreatePodPlanElement(scheduler, pod.Deployment))
 			plan = append(plan, getMigrateBindPodPlanElement(scheduler, pod.Deployment, node))
+			migratedPods = append(migratedPods, pod)
 		} else {
 			// It is already on cloud, so no need to do anything.
 		}
+	}
 
-		scheduler.goingToPlace[pod.Id] = true
+	// The reorder is suggested again later, when the pods can be moved.
+	if err := scheduler.checkDisruptions(migratedPods); err != nil {
+		log.Info().Msgf("deferred the suggestion: %s", err)
+		return
+	}
+	scheduler.recordMigrations(migratedPods)
+
+	for _, pod := range updatedDecision.ToEdgePods {
+		scheduler.goingToPlace[pod.Id] = pod
+	}
+
+	if len(plan) > 0 {
+		auditedDecision := updatedDecision
+		auditedDecision.Score = suggestion.Decision.Score
+		auditedDecision.ConsideredSplits = suggestion.Decision.ConsideredSplits
+		auditedDecision.BestSplits = suggestion.Decision.BestSplits
+		scheduler.auditDecision(audit.REORDERING, suggestion.CloudToEdgePods, auditedDecision, edgeMapping, cloudMapping, nil)
 	}
 
 	// Adding deleted pods to expected reorder deployments:
@@ -591,7 +904,7 @@ func (scheduler *Scheduler) resetClusterView() error {
 
 func (scheduler *Scheduler) recoverHealth() {
 	log.Warn().Msg("resetting scheduler's view of cluster...")
-	statistics.Change("restarts", 1)
+	statistics.Restarts.Inc()
 
 	recoverRetryDuration := time.Duration(config.SchedulerGeneralConfig.RecoverRetryDuration) * time.Millisecond
 
@@ -599,8 +912,8 @@ func (scheduler *Scheduler) recoverHealth() {
 	if err != nil {
 		log.Err(err).Msg("couldn't reset due to the error")
 		log.Warn().Msg("waiting for couple of seconds and retrying")
-		time.Sleep(recoverRetryDuration)
-		scheduler.recoverHealth()
+		scheduler.afterFunc(recoverRetryDuration, scheduler.recoverHealth)
+		return
 	}
 
 	scheduler.healthCheckSample = nil
@@ -609,6 +922,24 @@ func (scheduler *Scheduler) recoverHealth() {
 	log.Info().Msg("done with resetting scheduler's view of cluster")
 }
 
+// Watches the events again after the event stream is closed,
+// events may have been missed meanwhile so the view is reset.
+func (scheduler *Scheduler) rewatch() {
+	log.Warn().Msg("the event stream is closed, watching again...")
+	scheduler.eventStream = nil
+
+	eventStream, err := scheduler.connector.WatchSchedulingEvents()
+	if err != nil {
+		log.Err(err).Msg("couldn't watch the events, retrying after a while")
+		recoverRetryDuration := time.Duration(config.SchedulerGeneralConfig.RecoverRetryDuration) * time.Millisecond
+		scheduler.afterFunc(recoverRetryDuration, scheduler.rewatch)
+		return
+	}
+
+	scheduler.eventStream = eventStream
+	scheduler.recoverHealth()
+}
+
 func (scheduler *Scheduler) checkHealth() {
 	log.Info().Msg("checking scheduler's health...")
 
@@ -633,45 +964,113 @@ func (scheduler *Scheduler) Run(ctx context.Context) (SchedulerBridge, error) {
 
 		return SchedulerBridge{}, fmt.Errorf("could not start watching scheduling events")
 	}
+	scheduler.eventStream = eventStream
 	log.Info().Msg("got event watcher from connector")
 
+	// The goroutines sending to the scheduler's loop
+	// stop once it stops, instead of blocking forever.
+	deferredStream := make(chan func())
+	scheduler.afterFunc = func(d time.Duration, f func()) {
+		go func() {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case deferredStream <- f:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
 	scheduleTicker := time.NewTicker(time.Duration(config.SchedulerGeneralConfig.FlushPeriodDuration) * time.Millisecond)
 	healthCheckTicker := time.NewTicker(time.Duration(config.SchedulerGeneralConfig.HealthCheckDuration) * time.Millisecond)
+	metricsTicker := time.NewTicker(METRICS_UPDATE_DURATION)
 	cloudSuggestionDuration := time.Duration(config.SchedulerGeneralConfig.CloudSuggestDuration) * time.Millisecond
 
 	clusterStateRequestStream := make(chan struct{})
 	clusterStateStream := make(chan *model.ClusterState, 1024)
+	snapshotRequestStream := make(chan struct{})
+	snapshotStream := make(chan *Snapshot, 1024)
 
 	makeCloudSuggestion := make(chan struct{})
 
 	reorderSuggestStream := make(chan model.ReorderSuggestion)
-	go func() {
-		<-time.After(cloudSuggestionDuration)
-		makeCloudSuggestion <- struct{}{}
-	}()
+	suggestLater := func() {
+		select {
+		case <-time.After(cloudSuggestionDuration):
+		case <-ctx.Done():
+			return
+		}
 
+		select {
+		case makeCloudSuggestion <- struct{}{}:
+		case <-ctx.Done():
+		}
+	}
+	go suggestLater()
+
+	// The scheduler stops once the suggestions being made are done.
+	var suggestions sync.WaitGroup
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
+		defer suggestions.Wait()
+		defer scheduleTicker.Stop()
+		defer healthCheckTicker.Stop()
+		defer metricsTicker.Stop()
 	scheduler_live:
 		for {
 			select {
 			case <-ctx.Done():
-				scheduleTicker.Stop()
+				if stopper, ok := scheduler.connector.(connector.Stopper); ok {
+					stopper.Stop()
+				}
+				if err := scheduler.auditLog.Close(); err != nil {
+					log.Err(err).Send()
+				}
 				break scheduler_live
-			case event := <-eventStream:
+			case event, ok := <-scheduler.eventStream:
+				if !ok {
+					scheduler.rewatch()
+					break
+				}
 				scheduler.handleEvent(event)
+			case f := <-deferredStream:
+				f()
 			case <-scheduleTicker.C:
 				scheduler.schedule()
 			case <-healthCheckTicker.C:
 				scheduler.checkHealth()
+			case <-metricsTicker.C:
+				scheduler.updateMetrics()
 			case <-clusterStateRequestStream:
 				clusterStateStream <- scheduler.clusterState.Clone()
+			case <-snapshotRequestStream:
+				snapshotStream <- scheduler.snapshot()
 			case <-makeCloudSuggestion:
 				clonedState := scheduler.clusterState.Clone()
+				suggestions.Add(1)
 				go func() {
+					defer suggestions.Done()
+					select {
+					case <-ctx.Done():
+						return
+					default:
+					}
+
 					log.Info().Msg("making suggestion")
-					reorderSuggestStream <- alg.SuggestReorder(clonedState)
-					<-time.After(cloudSuggestionDuration)
-					makeCloudSuggestion <- struct{}{}
+					suggestionStart := time.Now()
+					suggestion := scheduler.algorithm.SuggestReorder(clonedState)
+					statistics.DecisionDuration.Observe(time.Since(suggestionStart).Seconds(), statistics.SUGGEST_REORDER)
+					select {
+					case reorderSuggestStream <- suggestion:
+					case <-ctx.Done():
+						return
+					}
+					suggestLater()
 				}()
 			case suggestion := <-reorderSuggestStream:
 				scheduler.checkSuggestion(suggestion)
@@ -683,5 +1082,9 @@ func (scheduler *Scheduler) Run(ctx context.Context) (SchedulerBridge, error) {
 	return SchedulerBridge{
 		ClusterStateRequestStream: clusterStateRequestStream,
 		ClusterStateStream:        clusterStateStream,
+		SnapshotRequestStream:     snapshotRequestStream,
+		SnapshotStream:            snapshotStream,
+		AuditLog:                  scheduler.auditLog,
+		Stopped:                   stopped,
 	}, nil
 }
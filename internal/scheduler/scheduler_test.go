@@ -0,0 +1,255 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/connector"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+)
+
+func TestCheckSuggestionMigrations(t *testing.T) {
+	setUpConfig(t)
+	config.SchedulerGeneralConfig.DryRun = true
+	defer func() { config.SchedulerGeneralConfig.DryRun = false }()
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 2},
+		{Cpu: 2, Memory: 2},
+		{Cpu: 2, Memory: 2},
+	})
+	nodes := clusterState.Edge.Config.Nodes
+	pod := builder.GetPods([]string{"A"})[0]
+	if err := clusterState.DeployEdge(pod, nodes[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Suggestions are made on a copy of the cluster state.
+	suggest := func(from *model.Node, to *model.Node) model.ReorderSuggestion {
+		suggestedPod := clusterState.Clone().PodsMap[pod.Id]
+		suggestedPod.Node = from
+
+		return model.ReorderSuggestion{
+			Decision: model.DecisionForNewPods{
+				Migrations: []*model.Migration{{Pod: suggestedPod, Node: to}},
+			},
+		}
+	}
+
+	for _, test := range []struct {
+		name       string
+		suggestion model.ReorderSuggestion
+		wantedNode *model.Node
+	}{
+		{"stays on its node", suggest(nodes[0], nodes[0]), nil},
+		{"changed its node", suggest(nodes[1], nodes[2]), nil},
+		{"has no target", suggest(nodes[0], nil), nil},
+		{"moves", suggest(nodes[0], nodes[1]), nodes[1]},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			scheduler, err := New(clusterState, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scheduler.checkSuggestion(test.suggestion)
+
+			plans := scheduler.dryRunRecord.plans
+			if test.wantedNode == nil {
+				if len(plans) != 0 {
+					t.Fatalf("got actions %v, wanted the migration to be skipped", plans[0].Actions)
+				}
+				return
+			}
+
+			if len(plans) != 1 {
+				t.Fatalf("got %d plans, wanted the pod to be moved", len(plans))
+			}
+			actions := plans[0].Actions
+			if len(actions) != 2 ||
+				actions[0].Action != DELETE_ACTION || actions[0].PodId != pod.Id ||
+				actions[1].Action != MIGRATE_ACTION || actions[1].NodeId != test.wantedNode.Id {
+				t.Fatalf("unexpected actions %v", actions)
+			}
+		})
+	}
+}
+
+// A connector that records the pods it binds and deletes,
+// binding the failing pod returns an error.
+type recordingConnector struct {
+	connector.Connector
+
+	failing int
+	bound   []int
+	deleted []int
+}
+
+func (c *recordingConnector) Deploy(pod *model.Pod, node *model.Node) error {
+	if pod.Id == c.failing {
+		return fmt.Errorf("could not bind pod %d", pod.Id)
+	}
+
+	c.bound = append(c.bound, pod.Id)
+	return nil
+}
+
+func (c *recordingConnector) GetPendingPods() ([]*model.Pod, error) {
+	return nil, nil
+}
+
+func (c *recordingConnector) DeletePod(pod *model.Pod) (bool, error) {
+	c.deleted = append(c.deleted, pod.Id)
+	return true, nil
+}
+
+func TestBindPodGroup(t *testing.T) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{{Cpu: 4, Memory: 4}})
+	pods := builder.GetPods([]string{"A", "A", "A"})
+	node := clusterState.Edge.Config.Nodes[0]
+	nodes := []*model.Node{node, node, node}
+	ids := []int{pods[0].Id, pods[1].Id, pods[2].Id}
+
+	c := &recordingConnector{failing: -1}
+	scheduler := &Scheduler{clusterState: clusterState, connector: c}
+
+	elements := getBindPodGroupPlanElements(scheduler, pods, nodes)
+	if len(elements) != len(pods) {
+		t.Fatalf("got %d plan elements, wanted one for each pod", len(elements))
+	}
+	if err := elements[0].do(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, element := range elements[1:] {
+		if err := element.do(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(c.bound, ids) || len(c.deleted) != 0 {
+		t.Fatalf("bound %v and deleted %v, wanted all the pods bound once", c.bound, c.deleted)
+	}
+
+	// The pods bound before the failing one are deleted.
+	c = &recordingConnector{failing: pods[2].Id}
+	scheduler.connector = c
+	if err := getBindPodGroupPlanElements(scheduler, pods, nodes)[0].do(nil); err == nil {
+		t.Fatalf("binding the group did not fail")
+	}
+	if !slices.Equal(c.deleted, ids[:2]) {
+		t.Errorf("deleted %v, wanted the bound pods %v", c.deleted, ids[:2])
+	}
+}
+
+func TestBindPodGroupInAnyOrder(t *testing.T) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{{Cpu: 4, Memory: 4}})
+	pods := builder.GetPods([]string{"A", "A", "A"})
+	node := clusterState.Edge.Config.Nodes[0]
+	for _, pod := range pods {
+		clusterState.PodsMap[pod.Id] = pod
+	}
+
+	scheduler := &Scheduler{clusterState: clusterState, connector: &recordingConnector{failing: -1}}
+	scheduler.schedulePlan(getBindPodGroupPlanElements(scheduler, pods, []*model.Node{node, node, node}), PLACING)
+
+	// The pods are bound at once, so their events may come in reverse.
+	for i := len(pods) - 1; i >= 0; i-- {
+		scheduler.handleEvent(&connector.Event{
+			EventType: connector.POD_CHANGED,
+			Pod:       pods[i],
+			Node:      node,
+			Status:    model.RUNNING,
+		})
+
+		// A mismatched event flushes all the expectations.
+		if len(scheduler.expectations) != i {
+			t.Fatalf("got %d expectations after the event of pod %d, wanted %d", len(scheduler.expectations), pods[i].Id, i)
+		}
+	}
+	for _, pod := range pods {
+		if pod.Node != node {
+			t.Errorf("pod %d is not deployed on the node", pod.Id)
+		}
+	}
+}
+
+func TestRunStopsItsGoroutines(t *testing.T) {
+	scheduler, _, _ := newSimScheduler(t, connector.SimConfig{})
+	config.SchedulerGeneralConfig.CloudSuggestDuration = 1
+
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	bridge, err := scheduler.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leaves suggestions and deferred functions in flight.
+	<-time.After(20 * time.Millisecond)
+	scheduler.afterFunc(time.Millisecond, func() {})
+	cancel()
+	<-bridge.Stopped
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines are left after the scheduler stopped, wanted %d", runtime.NumGoroutine(), goroutines)
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+}
+
+func TestSnapshotBindingPods(t *testing.T) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 1, EdgeShare: 1},
+	})
+	clusterState := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 2},
+	})
+	pods := builder.GetPods([]string{"A", "A", "A"})
+
+	scheduler, err := New(clusterState, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first pod is bound, the second is placed and the third is pending again.
+	for _, pod := range pods {
+		scheduler.goingToPlace[pod.Id] = pod
+	}
+	if err := clusterState.DeployEdge(pods[1], clusterState.Edge.Config.Nodes[0]); err != nil {
+		t.Fatal(err)
+	}
+	scheduler.newPodBuffer = pods[2:]
+
+	snapshot := scheduler.snapshot()
+	if len(snapshot.BindingPods) != 1 || snapshot.BindingPods[0].Id != pods[0].Id {
+		t.Fatalf("got binding pods %v, wanted only pod %d", snapshot.BindingPods, pods[0].Id)
+	}
+	if len(snapshot.PendingPods) != 1 || len(snapshot.ClusterState.Edge.Pods) != 1 {
+		t.Fatalf("got %d pending and %d edge pods, wanted 1 of each", len(snapshot.PendingPods), len(snapshot.ClusterState.Edge.Pods))
+	}
+}
@@ -0,0 +1,276 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/connector"
+	"github.com/amsen20/ecmus/internal/model"
+	"github.com/amsen20/ecmus/internal/model/testing_tool"
+	"github.com/amsen20/ecmus/internal/utils"
+	"gonum.org/v1/gonum/mat"
+)
+
+func newSimScheduler(t *testing.T, simConfig connector.SimConfig) (*Scheduler, *connector.SimConnector, *testing_tool.Builder) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "A", Cpu: 1, Memory: 2, EdgeShare: 0.5},
+		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 0.5},
+		{Name: "C", Cpu: 0.5, Memory: 1, EdgeShare: 1},
+		{Name: "D", Cpu: 2, Memory: 4, EdgeShare: 1},
+	})
+	topology := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 4},
+		{Cpu: 2, Memory: 2},
+		{Cpu: 2, Memory: 3},
+	})
+
+	clusterState := model.NewClusterState()
+	sim := connector.NewSimConnector(clusterState, simConfig)
+	for _, node := range topology.Edge.Config.Nodes {
+		sim.AddNode(node, "edge")
+	}
+	for _, node := range topology.Cloud.Nodes {
+		sim.AddNode(node, "cloud")
+	}
+	for ind, name := range []string{"A", "B", "C", "D"} {
+		sim.AddDeployment(builder.Deployments[name], []int{2, 2, 2, 1}[ind])
+	}
+
+	scheduler, err := New(clusterState, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return scheduler, sim, builder
+}
+
+// Checks that all pods are bound and the scheduler's
+// view of the cluster is the same as the simulated cluster.
+func expectConverged(t *testing.T, scheduler *Scheduler, sim *connector.SimConnector) {
+	for _, problem := range divergence(scheduler, sim) {
+		t.Error(problem)
+	}
+}
+
+// Returns the differences between the scheduler's
+// view of the cluster and the simulated cluster.
+func divergence(scheduler *Scheduler, sim *connector.SimConnector) []string {
+	var problems []string
+
+	used := make(map[int]*model.Pod)
+	for _, simPod := range sim.Pods() {
+		if simPod.Terminating {
+			problems = append(problems, fmt.Sprintf("pod %d is still being deleted", simPod.Id))
+			continue
+		}
+		if simPod.NodeId == -1 {
+			problems = append(problems, fmt.Sprintf("pod %d of deployment %d is not bound", simPod.Id, simPod.Deployment.Id))
+			continue
+		}
+
+		pod, ok := scheduler.clusterState.PodsMap[simPod.Id]
+		if !ok || pod.Node == nil || pod.Node.Id != simPod.NodeId {
+			problems = append(problems, fmt.Sprintf("scheduler does not know pod %d is on node %d", simPod.Id, simPod.NodeId))
+		}
+		used[simPod.Id] = pod
+	}
+
+	for id := range scheduler.clusterState.PodsMap {
+		if _, ok := used[id]; !ok {
+			problems = append(problems, fmt.Sprintf("scheduler knows pod %d which does not exist", id))
+		}
+	}
+
+	for _, node := range scheduler.clusterState.Edge.Config.Nodes {
+		if utils.LThan(node.Resources, scheduler.clusterState.NodeResourcesUsed[node.Id]) {
+			problems = append(problems, fmt.Sprintf("node %d is overcommitted", node.Id))
+		}
+	}
+
+	for _, node := range scheduler.clusterState.Cloud.Nodes {
+		if utils.LThan(node.Resources, scheduler.clusterState.Cloud.ResourcesUsed[node.Id]) {
+			problems = append(problems, fmt.Sprintf("cloud node %d is overcommitted", node.Id))
+		}
+	}
+
+	return problems
+}
+
+func TestSimConnector(t *testing.T) {
+	scheduler, sim, builder := newSimScheduler(t, connector.DefaultSimConfig)
+
+	clock := sim.Clock()
+	clock.AfterFunc(20*time.Second, func() {
+		sim.Scale(builder.Deployments["C"].Id, 4)
+		sim.Scale(builder.Deployments["A"].Id, 1)
+	})
+	clock.AfterFunc(40*time.Second, func() {
+		sim.Scale(builder.Deployments["D"].Id, 2)
+	})
+
+	if err := scheduler.RunOnClock(clock, 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	expectConverged(t, scheduler, sim)
+	if len(sim.Pods()) != 9 {
+		t.Errorf("got %d pods, wanted 9", len(sim.Pods()))
+	}
+}
+
+func TestSimConnectorWithFaults(t *testing.T) {
+	simConfig := connector.DefaultSimConfig
+	simConfig.Seed = 42
+	simConfig.Faults = connector.SimFaults{
+		DuplicateRate: 0.2,
+		ReorderRate:   0.3,
+		ReorderDelay:  500 * time.Millisecond,
+	}
+	scheduler, sim, builder := newSimScheduler(t, simConfig)
+
+	clock := sim.Clock()
+	clock.AfterFunc(20*time.Second, func() {
+		sim.Scale(builder.Deployments["C"].Id, 5)
+		sim.Scale(builder.Deployments["D"].Id, 2)
+	})
+	clock.AfterFunc(40*time.Second, func() {
+		sim.Scale(builder.Deployments["C"].Id, 1)
+		sim.Scale(builder.Deployments["A"].Id, 3)
+	})
+
+	if err := scheduler.RunOnClock(clock, 2*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	expectConverged(t, scheduler, sim)
+}
+
+func TestSimConnectorHealthRecovery(t *testing.T) {
+	scheduler, sim, builder := newSimScheduler(t, connector.DefaultSimConfig)
+
+	stuck := false
+	clock := sim.Clock()
+	clock.AfterFunc(20*time.Second, func() {
+		// The new pod is found as a pending pod, but the scheduler
+		// never sees it bound and waits for it until it recovers.
+		sim.SetFaults(connector.SimFaults{DropRate: 1})
+		sim.Scale(builder.Deployments["C"].Id, 3)
+	})
+	clock.AfterFunc(25*time.Second, func() {
+		sim.SetFaults(connector.SimFaults{})
+		stuck = len(scheduler.expectations) > 0
+	})
+
+	if err := scheduler.RunOnClock(clock, 3*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if !stuck {
+		t.Errorf("the scheduler should have waited for the dropped events")
+	}
+	expectConverged(t, scheduler, sim)
+}
+
+func TestHeterogeneousCloud(t *testing.T) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "D", Cpu: 2, Memory: 4, EdgeShare: 1},
+	})
+	topology := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 4},
+	})
+
+	// A pod costs 1 on the cheap node and 2.5 on the expensive one.
+	cheap := &model.Node{Id: utils.Hash("cheap"), Resources: mat.NewVecDense(2, []float64{2, 4}), Cost: 1}
+	expensive := &model.Node{Id: utils.Hash("expensive"), Resources: mat.NewVecDense(2, []float64{8, 16}), Cost: 10}
+
+	clusterState := model.NewClusterState()
+	sim := connector.NewSimConnector(clusterState, connector.DefaultSimConfig)
+	sim.AddNode(topology.Edge.Config.Nodes[0], "edge")
+	sim.AddNode(expensive, "cloud")
+	sim.AddNode(cheap, "cloud")
+	sim.AddDeployment(builder.Deployments["D"], 4)
+
+	scheduler, err := New(clusterState, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.RunOnClock(sim.Clock(), 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	expectConverged(t, scheduler, sim)
+
+	podsOn := make(map[int]int)
+	for _, simPod := range sim.Pods() {
+		podsOn[simPod.NodeId]++
+	}
+	if podsOn[cheap.Id] != 1 || podsOn[expensive.Id] != 2 {
+		t.Errorf("got %d pods on the cheap node and %d on the expensive one, wanted 1 and 2", podsOn[cheap.Id], podsOn[expensive.Id])
+	}
+}
+
+func TestPreemptionOnSim(t *testing.T) {
+	setUpConfig(t)
+
+	builder := testing_tool.New()
+	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
+		{Name: "low", Cpu: 1, Memory: 2, EdgeShare: 1},
+		{Name: "high", Cpu: 2, Memory: 4, EdgeShare: 1, Priority: 1000},
+	})
+	topology := builder.GetEmptyCluster([]*testing_tool.NodeDesc{
+		{Cpu: 2, Memory: 4},
+	})
+	edgeNode := topology.Edge.Config.Nodes[0]
+
+	clusterState := model.NewClusterState()
+	sim := connector.NewSimConnector(clusterState, connector.DefaultSimConfig)
+	sim.AddNode(edgeNode, "edge")
+	sim.AddNode(topology.Cloud.Nodes[0], "cloud")
+	sim.AddDeployment(builder.Deployments["low"], 3)
+	sim.AddDeployment(builder.Deployments["high"], 0)
+
+	scheduler, err := New(clusterState, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := sim.Clock()
+	clock.AfterFunc(20*time.Second, func() {
+		sim.Scale(builder.Deployments["high"].Id, 1)
+	})
+	if err := scheduler.RunOnClock(clock, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	expectConverged(t, scheduler, sim)
+	for _, simPod := range sim.Pods() {
+		onEdge := simPod.NodeId == edgeNode.Id
+		if isHigh := simPod.Deployment.Id == builder.Deployments["high"].Id; isHigh != onEdge {
+			t.Errorf("pod %d of deployment %d is on node %d", simPod.Id, simPod.Deployment.Id, simPod.NodeId)
+		}
+	}
+
+	preempted := false
+	for _, record := range scheduler.auditLog.Latest(10) {
+		preempted = preempted || len(record.Preemptions) > 0
+	}
+	if !preempted {
+		t.Errorf("the preemption is not in the audit log")
+	}
+}
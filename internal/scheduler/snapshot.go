@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"slices"
+
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Scheduler's state at a moment, which is safe to
+// be read outside of the scheduler's main life cycle.
+type Snapshot struct {
+	ClusterState *model.ClusterState
+	// Pods that are waiting for the scheduler's decision.
+	PendingPods []*model.Pod
+	// Pods that are bound but their bindings are not confirmed yet.
+	BindingPods []*model.Pod
+	// Expectations of the current plan, in order.
+	Expectations []ExpectationSnapshot
+	// Only in dry run mode.
+	DryRunPlans        []DryRunPlan
+	ObservedPlacements []ObservedPlacement
+}
+
+type ExpectationSnapshot struct {
+	Id   uint32
+	Type string
+}
+
+func (scheduler *Scheduler) snapshot() *Snapshot {
+	ret := &Snapshot{
+		ClusterState: scheduler.clusterState.Clone(),
+	}
+
+	copyPod := func(pod *model.Pod) *model.Pod {
+		return &model.Pod{
+			Id:         pod.Id,
+			Deployment: pod.Deployment,
+			Node:       pod.Node,
+			Status:     pod.Status,
+			Group:      pod.Group,
+		}
+	}
+
+	isPending := make(map[int]bool)
+	for _, pod := range scheduler.newPodBuffer {
+		ret.PendingPods = append(ret.PendingPods, copyPod(pod))
+		isPending[pod.Id] = true
+	}
+
+	// The placed pods are in the cluster state already.
+	for _, pod := range scheduler.goingToPlace {
+		if pod.Node == nil && !isPending[pod.Id] {
+			ret.BindingPods = append(ret.BindingPods, copyPod(pod))
+		}
+	}
+	slices.SortFunc(ret.BindingPods, func(a, b *model.Pod) int {
+		return a.Id - b.Id
+	})
+
+	for _, expectation := range scheduler.expectations {
+		ret.Expectations = append(ret.Expectations, ExpectationSnapshot{
+			Id:   expectation.id,
+			Type: expectationTypeToString(expectation.tp),
+		})
+	}
+
+	if scheduler.dryRunRecord != nil {
+		ret.DryRunPlans = append(ret.DryRunPlans, scheduler.dryRunRecord.plans...)
+		ret.ObservedPlacements = append(ret.ObservedPlacements, scheduler.dryRunRecord.placements...)
+	}
+
+	return ret
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/internal/connector"
+)
+
+// Runs the scheduler's main life cycle on the virtual clock
+// until the given time, instead of real time. Periodic work
+// is scheduled on the clock and events are handled as soon as
+// they are delivered, all in the caller's goroutine, so with a
+// connector driven by the same clock runs are deterministic.
+// It can be called again with a later time to continue the run.
+func (scheduler *Scheduler) RunOnClock(clock *connector.VirtualClock, until time.Duration) error {
+	if scheduler.clock == nil {
+		if err := scheduler.startOnClock(clock); err != nil {
+			return err
+		}
+	}
+
+	for {
+		next, ok := clock.Next()
+		if !ok || next > until {
+			return nil
+		}
+		clock.Step()
+
+	events:
+		for {
+			select {
+			case event, ok := <-scheduler.eventStream:
+				if !ok {
+					scheduler.rewatch()
+					break events
+				}
+				scheduler.handleEvent(event)
+			default:
+				break events
+			}
+		}
+
+		scheduler.updateMetrics()
+	}
+}
+
+// Watches the events and schedules the periodic work on the clock.
+func (scheduler *Scheduler) startOnClock(clock *connector.VirtualClock) error {
+	eventStream, err := scheduler.connector.WatchSchedulingEvents()
+	if err != nil {
+		log.Err(err).Send()
+
+		return fmt.Errorf("could not start watching scheduling events")
+	}
+	scheduler.eventStream = eventStream
+	scheduler.clock = clock
+	scheduler.afterFunc = func(d time.Duration, f func()) {
+		clock.AfterFunc(d, f)
+	}
+
+	every := func(period time.Duration, f func()) {
+		if period <= 0 {
+			return
+		}
+
+		var tick func()
+		tick = func() {
+			f()
+			clock.AfterFunc(period, tick)
+		}
+		clock.AfterFunc(period, tick)
+	}
+
+	every(time.Duration(config.SchedulerGeneralConfig.FlushPeriodDuration)*time.Millisecond, scheduler.schedule)
+	every(time.Duration(config.SchedulerGeneralConfig.HealthCheckDuration)*time.Millisecond, scheduler.checkHealth)
+	every(time.Duration(config.SchedulerGeneralConfig.CloudSuggestDuration)*time.Millisecond, func() {
+		scheduler.checkSuggestion(scheduler.algorithm.SuggestReorder(scheduler.clusterState.Clone()))
+	})
+
+	return nil
+}
@@ -3,15 +3,24 @@ package utils
 import (
 	"math"
 
+	"github.com/amsen20/ecmus/internal/config"
 	"gonum.org/v1/gonum/mat"
 )
 
+// Resources that are ignored in fragmentation or
+// the node does not provide at all are not considered.
 func CalcDeFragmentation(resources *mat.VecDense, nodeResources *mat.VecDense) float64 {
 	var ret float64
 	ret = 1
 	numberOfZeros := 0
+	numberOfConsidered := 0
 
 	for i := 0; i < resources.Len(); i++ {
+		if isIgnoredInFragmentation(i) || nodeResources.AtVec(i) == 0 {
+			continue
+		}
+		numberOfConsidered++
+
 		if math.Abs(resources.AtVec(i)) < 1e-10 {
 			numberOfZeros++
 		}
@@ -20,9 +29,14 @@ func CalcDeFragmentation(resources *mat.VecDense, nodeResources *mat.VecDense) f
 		ret *= norm
 	}
 
-	if numberOfZeros == resources.Len() {
+	if numberOfZeros == numberOfConsidered {
 		ret = 0.1
 	}
 
 	return ret
 }
+
+func isIgnoredInFragmentation(i int) bool {
+	resources := config.SchedulerGeneralConfig.Resources
+	return i < len(resources) && resources[i].IgnoreInFragmentation
+}
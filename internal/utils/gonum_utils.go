@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 
+	"github.com/amsen20/ecmus/internal/config"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -36,6 +37,19 @@ func SAddVec(a, b *mat.VecDense) {
 	a.AddVec(a, b)
 }
 
+// Sets each entry of a to the maximum of it and b's entry.
+func SMaxVec(a, b *mat.VecDense) {
+	if a.Len() != b.Len() {
+		panic("Two vectors should have the same length.")
+	}
+
+	for i := 0; i < a.Len(); i += 1 {
+		if b.AtVec(i) > a.AtVec(i) {
+			a.SetVec(i, b.AtVec(i))
+		}
+	}
+}
+
 func LEThan(a, b *mat.VecDense) bool {
 	if a.Len() != b.Len() {
 		panic("Two vectors should have the same length.")
@@ -55,12 +69,34 @@ func LThan(a, b *mat.VecDense) bool {
 }
 
 func ToString(a *mat.VecDense) string {
-	// ret := ""
-	// for i := 0; i < a.Len(); i += 1 {
-	// 	ret += fmt.Sprintf("%v", a.AtVec(i))
-	// }
+	ret := ""
+	for i := 0; i < a.Len(); i += 1 {
+		if i > 0 {
+			ret += ", "
+		}
+		ret += fmt.Sprintf("%s: %f", resourceName(i), a.AtVec(i))
+	}
+
+	return "(" + ret + ")"
+}
+
+// Same as ToString but without resource names.
+func ToTuple(a *mat.VecDense) string {
+	ret := ""
+	for i := 0; i < a.Len(); i += 1 {
+		if i > 0 {
+			ret += ", "
+		}
+		ret += fmt.Sprintf("%f", a.AtVec(i))
+	}
 
-	// return ret
+	return "(" + ret + ")"
+}
+
+func resourceName(i int) string {
+	if i < len(config.SchedulerGeneralConfig.Resources) {
+		return config.SchedulerGeneralConfig.Resources[i].Name
+	}
 
-	return fmt.Sprintf("(Cpu: %f, Mem: %f)", a.AtVec(0), a.AtVec(1))
+	return fmt.Sprintf("resource %d", i)
 }
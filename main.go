@@ -15,12 +15,16 @@ import (
 	"github.com/amsen20/ecmus/internal/scheduler"
 	"github.com/amsen20/ecmus/logging"
 	"github.com/amsen20/ecmus/statistics"
-	"gopkg.in/yaml.v2"
 )
 
 var log = logging.Get()
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		simulate(os.Args[2:])
+		return
+	}
+
 	config_file_path := flag.String("config_file", "", "Path to config file")
 	flag.Parse()
 
@@ -31,7 +35,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := yaml.UnmarshalStrict(yamlFile, &config.SchedulerGeneralConfig); err != nil {
+	if err := config.Load(yamlFile); err != nil {
 		log.Err(err).Msgf("could not load config")
 		os.Exit(1)
 	}
@@ -50,6 +54,12 @@ func main() {
 			log.Err(err).Msg("could not init the connector")
 			os.Exit(1)
 		}
+	case "kubernetes_cached":
+		c, err = connector.NewCachedKubeConnector(clusterState)
+		if err != nil {
+			log.Err(err).Msg("could not init the connector")
+			os.Exit(1)
+		}
 	default:
 		log.Error().Msg("connector kind is not recognized")
 		os.Exit(1)
@@ -66,7 +76,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	schedulerContext := context.Background()
+	schedulerContext, stopScheduler := context.WithCancel(context.Background())
 
 	// Scheduler's bridge is a way for other goroutines to ask
 	// the scheduler for getting snapshots of the current state.
@@ -79,15 +89,13 @@ func main() {
 	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
 
-	// Setup statistics.
-	statistics.Init()
-	statistics.Set("restarts", 0)
-
 	// Simple gui in web-server for checking state's status.
 	gui.SetUp(schedulerBridge)
 	go gui.Run()
 
 	<-signalChannel
 	log.Info().Msgf("exiting gracefully...")
+	stopScheduler()
+	<-schedulerBridge.Stopped
 	log.Info().Msgf("\n%s", statistics.Display())
 }
@@ -4,14 +4,20 @@ import matplotlib.pyplot as plt
 
 x = list(range(100))
 
+# Older reports have a list of each metric instead of the frames.
+def load(path, what):
+    with open(path, "r") as f:
+        report = json.load(f)
+    if "frames" not in report:
+        return report[what]
+    return [frame[what] for frame in report["frames"]]
+
 what = input()
-with open(sys.argv[1], "r") as f:
-    y1 = json.load(f)[what]
-    print(sum(y1)/len(y1))
+y1 = load(sys.argv[1], what)
+print(sum(y1)/len(y1))
 
-with open(sys.argv[2], "r") as f:
-    y2 = json.load(f)[what]
-    print(sum(y2)/len(y2))
+y2 = load(sys.argv[2], what)
+print(sum(y2)/len(y2))
 
 fig, ax = plt.subplots()
 
@@ -6,7 +6,17 @@ with open("data.json") as f:
     data = json.load(f)
 
 deployments = {k: np.array(v["resources"]) * v["share"] for k, v in data["deployments"].items()}
-edge_resources = np.array(data["edge_resources"])
+
+# Older data files only have the resources of the whole edge,
+# which is taken as a single edge node.
+if "edge_nodes" in data:
+    edge_nodes = data["edge_nodes"]
+else:
+    edge_nodes = [{"name": "edge", "cpu": data["edge_resources"][0], "memory": data["edge_resources"][1]}]
+edge_resources = np.array(data.get(
+    "edge_resources",
+    [sum(node["cpu"] for node in edge_nodes), sum(node["memory"] for node in edge_nodes)],
+))
 
 last_frame = {name: 0 for name in deployments.keys()}
 scenario = []
@@ -42,5 +52,15 @@ for _ in range(100):
         "new_pods": addition
     })
 
+for ind, frame in enumerate(scenario):
+    frame["time"] = ind
+
 with open("scenario.json", "w") as f:
-    json.dump(scenario, f)
+    json.dump({
+        "edge_nodes": edge_nodes,
+        "deployments": [
+            {"name": k, "cpu": v["resources"][0], "memory": v["resources"][1], "edge_share": v["share"]}
+            for k, v in data["deployments"].items()
+        ],
+        "frames": scenario,
+    }, f)
@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/amsen20/ecmus/internal/model"
+)
+
+// Report formats:
+const (
+	JSON_FORMAT = "json"
+	CSV_FORMAT  = "csv"
+)
+
+type Report struct {
+	Algorithm string         `json:"algorithm"`
+	Frames    []*FrameReport `json:"frames"`
+}
+
+// State of the cluster after a frame and
+// what the algorithm did to get there.
+type FrameReport struct {
+	Frame       int     `json:"frame"`
+	Time        float64 `json:"time"`
+	NewPods     int     `json:"new_pods"`
+	DeletedPods int     `json:"deleted_pods"`
+
+	// QoS score of the cluster and the number of
+	// deployments having their edge share satisfied.
+	QoS          float64 `json:"qos"`
+	QoSSatisfied int     `json:"qos_satisfied"`
+	// Edge's resource usage as computed for fragmentation.
+	EdgeUsage float64 `json:"edge_usage"`
+	// Used share of edge's total amount of each resource.
+	EdgeUtilization map[string]float64 `json:"edge_utilization"`
+
+	// Pods moved between edge nodes.
+	Migrations int `json:"migrations"`
+	// Pods moved from edge to cloud.
+	Offloads int `json:"offloads"`
+	// Pods moved from cloud to edge by reorder suggestions.
+	CloudToEdge int `json:"cloud_to_edge"`
+
+	// Time spent by the algorithm deciding about the frame.
+	DecisionLatency float64 `json:"decision_latency_ms"`
+}
+
+func (frameReport *FrameReport) addDecision(decision model.DecisionForNewPods) {
+	frameReport.Migrations += len(decision.Migrations)
+	frameReport.Offloads += len(decision.EdgeToCloudOffloadingPods)
+}
+
+// Writes the report in the format, either json or csv.
+func (report *Report) Write(writer io.Writer, format string) error {
+	switch format {
+	case JSON_FORMAT:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", " ")
+		return encoder.Encode(report)
+	case CSV_FORMAT:
+		return report.writeCSV(writer)
+	}
+
+	return fmt.Errorf("report format %q is not recognized", format)
+}
+
+func (report *Report) writeCSV(writer io.Writer) error {
+	resourceNames := make([]string, 0)
+	if len(report.Frames) > 0 {
+		for name := range report.Frames[0].EdgeUtilization {
+			resourceNames = append(resourceNames, name)
+		}
+	}
+	sort.Strings(resourceNames)
+
+	csvWriter := csv.NewWriter(writer)
+
+	header := []string{"algorithm", "frame", "time", "new_pods", "deleted_pods", "qos", "qos_satisfied", "edge_usage"}
+	for _, name := range resourceNames {
+		header = append(header, "edge_utilization_"+name)
+	}
+	header = append(header, "migrations", "offloads", "cloud_to_edge", "decision_latency_ms")
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	formatFloat := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	for _, frame := range report.Frames {
+		row := []string{
+			report.Algorithm,
+			strconv.Itoa(frame.Frame),
+			formatFloat(frame.Time),
+			strconv.Itoa(frame.NewPods),
+			strconv.Itoa(frame.DeletedPods),
+			formatFloat(frame.QoS),
+			strconv.Itoa(frame.QoSSatisfied),
+			formatFloat(frame.EdgeUsage),
+		}
+		for _, name := range resourceNames {
+			row = append(row, formatFloat(frame.EdgeUtilization[name]))
+		}
+		row = append(
+			row,
+			strconv.Itoa(frame.Migrations),
+			strconv.Itoa(frame.Offloads),
+			strconv.Itoa(frame.CloudToEdge),
+			formatFloat(frame.DecisionLatency),
+		)
+
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
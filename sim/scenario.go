@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A scenario describes the cluster's topology, its deployments
+// and the pods that arrive and get deleted over time.
+// Resources are in the configured scales (like memory in MBs).
+type Scenario struct {
+	EdgeNodes   []*NodeSpec       `json:"edge_nodes"`
+	Deployments []*DeploymentSpec `json:"deployments"`
+	Frames      []*Frame          `json:"frames"`
+}
+
+type NodeSpec struct {
+	Name   string  `json:"name"`
+	Cpu    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+	// Other configured resources by their names.
+	Others map[string]float64 `json:"others"`
+}
+
+type DeploymentSpec struct {
+	Name      string             `json:"name"`
+	Cpu       float64            `json:"cpu"`
+	Memory    float64            `json:"memory"`
+	Others    map[string]float64 `json:"others"`
+	EdgeShare float64            `json:"edge_share"`
+}
+
+// Pods that arrive and get deleted at the same time,
+// each pod is given by its deployment's name.
+type Frame struct {
+	// Seconds since the start of the scenario.
+	Time        float64  `json:"time"`
+	NewPods     []string `json:"new_pods"`
+	DeletedPods []string `json:"delete_pods"`
+}
+
+// Reads and validates the scenario file,
+// frames are sorted by their time.
+func LoadScenario(path string) (*Scenario, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not read the scenario file")
+	}
+
+	scenario := &Scenario{}
+	if err := json.Unmarshal(content, scenario); err != nil {
+		log.Err(err).Send()
+
+		return nil, fmt.Errorf("could not parse the scenario file")
+	}
+
+	if err := scenario.validate(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(scenario.Frames, func(i, j int) bool {
+		return scenario.Frames[i].Time < scenario.Frames[j].Time
+	})
+
+	return scenario, nil
+}
+
+func (scenario *Scenario) validate() error {
+	if len(scenario.EdgeNodes) == 0 {
+		return fmt.Errorf("scenario has no edge nodes")
+	}
+
+	deployments := make(map[string]bool)
+	for ind, deployment := range scenario.Deployments {
+		if deployment.Name == "" {
+			return fmt.Errorf("deployment %d has no name", ind)
+		}
+		if deployments[deployment.Name] {
+			return fmt.Errorf("deployment %s is described more than once", deployment.Name)
+		}
+		if deployment.EdgeShare < 0 || deployment.EdgeShare > 1 {
+			return fmt.Errorf("edge share of deployment %s should be between 0 and 1", deployment.Name)
+		}
+		deployments[deployment.Name] = true
+	}
+
+	for ind, frame := range scenario.Frames {
+		for _, name := range append(append([]string{}, frame.NewPods...), frame.DeletedPods...) {
+			if !deployments[name] {
+				return fmt.Errorf("frame %d refers to deployment %s which is not described", ind, name)
+			}
+		}
+	}
+
+	return nil
+}
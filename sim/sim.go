@@ -1,165 +1,175 @@
+// Trace-driven simulator of the scheduler's algorithms,
+// it replays a scenario's frames on an in-memory cluster
+// and reports how the algorithm performed after each frame.
 package sim
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"time"
 
 	"github.com/amsen20/ecmus/alg"
+	"github.com/amsen20/ecmus/internal/config"
 	"github.com/amsen20/ecmus/internal/model"
 	"github.com/amsen20/ecmus/internal/model/testing_tool"
 	"github.com/amsen20/ecmus/internal/utils"
 	"github.com/amsen20/ecmus/logging"
 )
 
-type Frame struct {
-	NewPods     []string `json:"new_pods"`
-	DeletedPods []string `json:"delete_pods"`
-}
-
-var report struct {
-	QoS  []float64 `json:"qos"`
-	Edge []float64 `json:"edge_usage"`
-}
-
-type algorithmType int
-
-const (
-	KUBERNETES_DEFAULT algorithmType = iota
-	QASRE
-)
-
 var log = logging.Get()
 
-var (
-	clusterState *model.ClusterState
-	builder      *testing_tool.Builder
-)
+// Number of reorder suggestions applied after each frame's decision.
+const SUGGESTIONS_PER_FRAME = 2
 
-func setUpBuilder() {
-	builder = testing_tool.New()
-	builder.ImportDeployments([]*testing_tool.DeploymentDesc{
-		{Name: "A", Cpu: 1, Memory: 2, EdgeShare: 0.5},
-		{Name: "B", Cpu: 1, Memory: 1, EdgeShare: 0.5},
-		{Name: "C", Cpu: 0.5, Memory: 1, EdgeShare: 1},
-		{Name: "D", Cpu: 2, Memory: 4, EdgeShare: 1},
-	})
-
-	clusterState = builder.GetCluster(
-		map[*testing_tool.NodeDesc][]string{
-			{Cpu: 2, Memory: 4}: {},
-			{Cpu: 2, Memory: 2}: {},
-			{Cpu: 2, Memory: 3}: {},
-		},
-		[]string{},
-	)
+type simulation struct {
+	algorithm    alg.Algorithm
+	builder      *testing_tool.Builder
+	clusterState *model.ClusterState
 }
 
-func deletePods(deletedPods []*model.Pod) {
-pods:
-	for _, pod := range deletedPods {
-		for _, edgePod := range clusterState.Edge.Pods {
-			if pod.Deployment.Id == edgePod.Deployment.Id {
-				clusterState.RemovePod(edgePod)
-				continue pods
-			}
-		}
-
-		for _, cloudPod := range clusterState.Cloud.Pods {
-			if pod.Deployment.Id == cloudPod.Deployment.Id {
-				clusterState.RemovePod(cloudPod)
-				continue pods
-			}
-		}
+func newSimulation(scenario *Scenario, algorithm alg.Algorithm) *simulation {
+	builder := testing_tool.New()
+
+	deploymentsDesc := make([]*testing_tool.DeploymentDesc, 0)
+	for _, deployment := range scenario.Deployments {
+		deploymentsDesc = append(deploymentsDesc, &testing_tool.DeploymentDesc{
+			Name:      deployment.Name,
+			Cpu:       deployment.Cpu,
+			Memory:    deployment.Memory,
+			Others:    deployment.Others,
+			EdgeShare: deployment.EdgeShare,
+		})
+	}
+	builder.ImportDeployments(deploymentsDesc)
+
+	nodesDesc := make([]*testing_tool.NodeDesc, 0)
+	for _, node := range scenario.EdgeNodes {
+		nodesDesc = append(nodesDesc, &testing_tool.NodeDesc{
+			Cpu:    node.Cpu,
+			Memory: node.Memory,
+			Others: node.Others,
+		})
+	}
 
-		panic("didn't find the pod")
+	return &simulation{
+		algorithm:    algorithm,
+		builder:      builder,
+		clusterState: builder.GetEmptyCluster(nodesDesc),
 	}
 }
 
-func kubeDefaultSchedulePods(newPods []*model.Pod) {
-pods:
-	for _, pod := range newPods {
-		resRem := clusterState.GetNodesResourcesRemained()
-		for _, node := range clusterState.Edge.Config.Nodes {
-			if utils.LEThan(pod.Deployment.ResourcesRequired, resRem[node.Id]) {
-				if err := clusterState.DeployEdge(pod, node); err != nil {
-					panic(err)
-				}
+// Runs the scenario with the algorithm and returns the report of its frames.
+func Run(scenario *Scenario, algorithmName string) (*Report, error) {
+	if algorithmName == "" {
+		algorithmName = alg.QOS_ALGORITHM
+	}
+	algorithm, err := alg.Get(algorithmName)
+	if err != nil {
+		return nil, err
+	}
 
-				continue pods
-			}
+	s := newSimulation(scenario, algorithm)
+	report := &Report{
+		Algorithm: algorithmName,
+		Frames:    make([]*FrameReport, 0),
+	}
+
+	for ind, frame := range scenario.Frames {
+		log.Info().Msgf("processing frame: %d, new pods: %d, deleted pods: %d", ind, len(frame.NewPods), len(frame.DeletedPods))
+
+		frameReport, err := s.runFrame(frame)
+		if err != nil {
+			log.Err(err).Send()
+
+			return nil, fmt.Errorf("could not run frame %d", ind)
 		}
+		frameReport.Frame = ind
 
-		clusterState.DeployCloud(pod)
+		report.Frames = append(report.Frames, frameReport)
 	}
-}
 
-func Start() {
-	var testingAlgorithm algorithmType
+	return report, nil
+}
 
-	var choice string
-	fmt.Scan(&choice)
-	if choice == "QASRE" {
-		testingAlgorithm = QASRE
-	} else {
-		testingAlgorithm = KUBERNETES_DEFAULT
+func (s *simulation) runFrame(frame *Frame) (*FrameReport, error) {
+	ret := &FrameReport{
+		Time:        frame.Time,
+		NewPods:     len(frame.NewPods),
+		DeletedPods: len(frame.DeletedPods),
 	}
 
-	jsonFile, err := os.Open("./sim/scenario.json")
-	if err != nil {
-		panic(err)
+	if err := s.deletePods(frame.DeletedPods); err != nil {
+		return nil, err
 	}
-	defer jsonFile.Close()
 
-	bytes, _ := ioutil.ReadAll(jsonFile)
-	var frames []*Frame
-	err = json.Unmarshal([]byte(bytes), &frames)
-	if err != nil {
-		fmt.Println(err.Error())
+	newPods := s.builder.GetPods(frame.NewPods)
+
+	start := time.Now()
+	decision := s.algorithm.DecideForNewPods(s.clusterState, newPods, true)
+	ret.DecisionLatency += time.Since(start).Seconds() * 1000
+	ret.addDecision(decision)
+	alg.TestingApplyAlgorithmDecision(s.clusterState, s.algorithm, decision)
+
+	for i := 0; i < SUGGESTIONS_PER_FRAME; i++ {
+		start := time.Now()
+		suggestion := s.algorithm.SuggestReorder(s.clusterState)
+		ret.DecisionLatency += time.Since(start).Seconds() * 1000
+		ret.CloudToEdge += len(suggestion.CloudToEdgePods)
+		ret.addDecision(suggestion.Decision)
+		alg.TestingApplyAlgorithmSuggestion(s.clusterState, s.algorithm, suggestion)
 	}
 
-	setUpBuilder()
-
-	for ind, frame := range frames {
-		log.Info().Msgf("processing frame: %d, length: %d", ind, len(frame.NewPods))
-		newPods := builder.GetPods(frame.NewPods)
-		deletedPods := builder.GetPods(frame.DeletedPods)
+	if err := s.measure(ret); err != nil {
+		return nil, err
+	}
 
-		deletePods(deletedPods)
+	return ret, nil
+}
 
-		switch testingAlgorithm {
-		case KUBERNETES_DEFAULT:
-			kubeDefaultSchedulePods(newPods)
-		case QASRE:
-			decision := alg.MakeDecisionForNewPods(clusterState, newPods, true)
-			alg.TestingApplyDecision(clusterState, decision)
-			alg.TestingApplySuggestion(clusterState, alg.SuggestReorder(clusterState))
-			alg.TestingApplySuggestion(clusterState, alg.SuggestReorder(clusterState))
+// Deletes a pod of each given deployment, edge pods first.
+func (s *simulation) deletePods(deploymentNames []string) error {
+pods:
+	for _, name := range deploymentNames {
+		deployment := s.builder.Deployments[name]
+
+		for _, pods := range [][]*model.Pod{s.clusterState.Edge.Pods, s.clusterState.Cloud.Pods} {
+			for _, pod := range pods {
+				if pod.Deployment.Id == deployment.Id {
+					s.clusterState.RemovePod(pod)
+					continue pods
+				}
+			}
 		}
 
-		qos, err := alg.CalcNumberOfQosSatisfactions(clusterState.Edge.Config, clusterState.Cloud.Pods, clusterState.Edge.Pods, nil, nil)
-		if err != nil {
-			panic(err)
-		}
+		return fmt.Errorf("there is no pod of deployment %s to delete", name)
+	}
 
-		qosSatisfied := 0
-		for deploymentId, info := range qos.DeploymentsQoS {
-			edgeShare := clusterState.Edge.Config.DeploymentIdToDeployment[deploymentId].EdgeShare
-			if float64(info.NumberOfPods)*edgeShare <= float64(info.NumberOfPodOnEdge) {
-				qosSatisfied += 1
-			}
-		}
+	return nil
+}
+
+func (s *simulation) measure(frameReport *FrameReport) error {
+	edge := s.clusterState.Edge
 
-		report.QoS = append(report.QoS, float64(qosSatisfied))
-		report.Edge = append(report.Edge, utils.CalcDeFragmentation(clusterState.Edge.UsedResources, clusterState.Edge.Config.Resources))
+	qos, err := alg.CalcNumberOfQosSatisfactions(edge.Config, s.clusterState.Cloud.Pods, edge.Pods, nil, nil)
+	if err != nil {
+		return err
 	}
+	frameReport.QoS = qos.Score
 
-	for i := range report.QoS {
-		fmt.Printf("%f, %f\n", report.QoS[i], report.Edge[i])
+	for deploymentId, info := range qos.DeploymentsQoS {
+		edgeShare := edge.Config.DeploymentIdToDeployment[deploymentId].EdgeShare
+		if float64(info.NumberOfPods)*edgeShare <= info.EdgeProximity {
+			frameReport.QoSSatisfied += 1
+		}
 	}
 
-	content, _ := json.MarshalIndent(report, "", " ")
+	frameReport.EdgeUsage = utils.CalcDeFragmentation(edge.UsedResources, edge.Config.Resources)
+	frameReport.EdgeUtilization = make(map[string]float64)
+	for ind, resource := range config.SchedulerGeneralConfig.Resources {
+		if edge.Config.Resources.AtVec(ind) == 0 {
+			continue
+		}
+		frameReport.EdgeUtilization[resource.Name] = edge.UsedResources.AtVec(ind) / edge.Config.Resources.AtVec(ind)
+	}
 
-	_ = ioutil.WriteFile(fmt.Sprintf("./sim/%s.json", choice), content, 0644)
+	return nil
 }
@@ -0,0 +1,63 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"testing"
+
+	"github.com/amsen20/ecmus/alg"
+	"github.com/amsen20/ecmus/internal/config"
+)
+
+func TestRun(t *testing.T) {
+	yamlFile, err := os.ReadFile("../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(yamlFile); err != nil {
+		t.Fatal(err)
+	}
+
+	scenario, err := LoadScenario("example_scenario.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range alg.Names() {
+		t.Run(name, func(t *testing.T) {
+			report, err := Run(scenario, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Frames) != len(scenario.Frames) {
+				t.Fatalf("got %d frames, wanted %d", len(report.Frames), len(scenario.Frames))
+			}
+
+			for _, frame := range report.Frames {
+				for resource, utilization := range frame.EdgeUtilization {
+					if utilization < 0 || utilization > 1 {
+						t.Errorf("frame %d has edge utilization %f of %s", frame.Frame, utilization, resource)
+					}
+				}
+			}
+
+			content := &bytes.Buffer{}
+			if err := report.Write(content, CSV_FORMAT); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(content).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(scenario.Frames)+1 {
+				t.Errorf("got %d csv rows, wanted a header and %d frames", len(rows), len(scenario.Frames))
+			}
+		})
+	}
+
+	scenario.Frames = append(scenario.Frames, &Frame{DeletedPods: []string{"D", "D", "D"}})
+	if _, err := Run(scenario, alg.QOS_ALGORITHM); err == nil {
+		t.Errorf("deleting pods that do not exist should fail")
+	}
+}
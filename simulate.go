@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/amsen20/ecmus/internal/config"
+	"github.com/amsen20/ecmus/sim"
+)
+
+// Runs a scenario with an algorithm in the simulator,
+// instead of scheduling a real cluster, and writes the report.
+func simulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configFilePath := flags.String("config_file", "config.yaml", "Path to config file")
+	scenarioPath := flags.String("scenario", "", "Path to scenario file")
+	algorithmName := flags.String("algorithm", "", "Name of the algorithm, the config's algorithm if empty")
+	outputPath := flags.String("output", "", "Path to report file")
+	format := flags.String("format", sim.JSON_FORMAT, "Format of the report, either json or csv")
+	flags.Parse(args)
+
+	// Logs are written to stdout, so the report can not be.
+	if *scenarioPath == "" || *outputPath == "" {
+		log.Error().Msg("both scenario and output should be given")
+		os.Exit(1)
+	}
+	if *format != sim.JSON_FORMAT && *format != sim.CSV_FORMAT {
+		log.Error().Msgf("report format %q is not recognized", *format)
+		os.Exit(1)
+	}
+
+	yamlFile, err := os.ReadFile(*configFilePath)
+	if err != nil {
+		log.Err(err).Msgf("could not load config")
+		os.Exit(1)
+	}
+
+	if err := config.Load(yamlFile); err != nil {
+		log.Err(err).Msgf("could not load config")
+		os.Exit(1)
+	}
+
+	if *algorithmName == "" {
+		*algorithmName = config.SchedulerGeneralConfig.Algorithm
+	}
+
+	scenario, err := sim.LoadScenario(*scenarioPath)
+	if err != nil {
+		log.Err(err).Msg("could not load scenario")
+		os.Exit(1)
+	}
+
+	report, err := sim.Run(scenario, *algorithmName)
+	if err != nil {
+		log.Err(err).Msg("could not run simulation")
+		os.Exit(1)
+	}
+
+	file, err := os.Create(*outputPath)
+	if err != nil {
+		log.Err(err).Msg("could not create report file")
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	if err := report.Write(file, *format); err != nil {
+		log.Err(err).Msg("could not write report")
+		os.Exit(1)
+	}
+}
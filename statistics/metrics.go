@@ -0,0 +1,71 @@
+package statistics
+
+// Scheduler's metrics:
+var (
+	ExpectationsAdded = NewCounter(
+		"ecmus_expectations_added_total",
+		"Number of expectations added to the scheduler's plans.",
+		"type",
+	)
+	ExpectationsDone = NewCounter(
+		"ecmus_expectations_done_total",
+		"Number of expectations that occurred as expected.",
+		"type",
+	)
+	Restarts = NewCounter(
+		"ecmus_restarts_total",
+		"Number of times the scheduler reset its view of the cluster.",
+	)
+	DecisionDuration = NewHistogram(
+		"ecmus_decision_duration_seconds",
+		"Duration of the scheduling algorithm's decisions.",
+		DURATION_BUCKETS,
+		"operation",
+	)
+	DeploymentQoS = NewGauge(
+		"ecmus_deployment_qos",
+		"QoS score of each deployment.",
+		"deployment",
+	)
+	DeploymentEdgeShare = NewGauge(
+		"ecmus_deployment_edge_share",
+		"Share of each deployment's pods that are on edge.",
+		"deployment",
+	)
+	DeploymentPromisedEdgeShare = NewGauge(
+		"ecmus_deployment_promised_edge_share",
+		"Share of each deployment's pods that is promised to be on edge.",
+		"deployment",
+	)
+	EdgeNodeUtilization = NewGauge(
+		"ecmus_edge_node_utilization",
+		"Used share of each edge node's resources.",
+		"node", "resource",
+	)
+	SpreadViolations = NewGauge(
+		"ecmus_spread_violations",
+		"Number of edge pods breaking their deployments' spread rules.",
+	)
+	DeferredReorders = NewCounter(
+		"ecmus_deferred_reorders_total",
+		"Number of reorders deferred to keep the disruption budgets or the migration rate limits.",
+		"reason",
+	)
+	BufferLength = NewGauge(
+		"ecmus_buffer_length",
+		"Number of items in the scheduler's buffers.",
+		"buffer",
+	)
+)
+
+// Reasons of deferring reorders:
+const (
+	DISRUPTION_BUDGET = "disruption_budget"
+	MIGRATION_RATE    = "migration_rate"
+)
+
+// Operations measured by the decision duration:
+const (
+	DECIDE_FOR_NEW_PODS = "decide_for_new_pods"
+	SUGGEST_REORDER     = "suggest_reorder"
+)
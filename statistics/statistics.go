@@ -1,46 +1,277 @@
+// Metrics of the scheduler, exposed in prometheus'
+// text exposition format by the gui server.
+// Metrics are safe to be used from any goroutine.
 package statistics
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
-type statisticsData struct {
-	dataMap map[string]int
+type metricKind string
 
-	mutex sync.Mutex
+// metric kinds:
+const (
+	COUNTER   metricKind = "counter"
+	GAUGE     metricKind = "gauge"
+	HISTOGRAM metricKind = "histogram"
+)
+
+// Buckets of histograms measuring durations, in seconds.
+var DURATION_BUCKETS = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// A single series of a metric, identified by its label values.
+type series struct {
+	labelValues []string
+
+	value float64
+	// only for histograms, counts[i] is the number of
+	// observations less than or equal to buckets[i]
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type metric struct {
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+	buckets    []float64
+
+	// series by their joined label values
+	series map[string]*series
+	mutex  sync.Mutex
+}
+
+type Counter struct{ *metric }
+type Gauge struct{ *metric }
+type Histogram struct{ *metric }
+
+var registry struct {
+	metrics []*metric
+	mutex   sync.Mutex
+}
+
+func register(name, help string, kind metricKind, buckets []float64, labelNames []string) *metric {
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+
+	// Metrics without labels are always exposed, even if they are zero.
+	if len(labelNames) == 0 {
+		m.get(nil)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.metrics = append(registry.metrics, m)
+
+	return m
+}
+
+func NewCounter(name, help string, labelNames ...string) Counter {
+	return Counter{register(name, help, COUNTER, nil, labelNames)}
+}
+
+func NewGauge(name, help string, labelNames ...string) Gauge {
+	return Gauge{register(name, help, GAUGE, nil, labelNames)}
+}
+
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) Histogram {
+	return Histogram{register(name, help, HISTOGRAM, buckets, labelNames)}
+}
+
+// Returns the series with the label values, creates it if
+// it does not exist. The metric's mutex should be held.
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", m.name, len(m.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+// Removes all series of the metric, for gauges
+// whose label values (like deployments) can disappear.
+func (m *metric) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.series = make(map[string]*series)
+	if len(m.labelNames) == 0 {
+		m.get(nil)
+	}
+}
+
+func (c Counter) Add(value float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.get(labelValues).value += value
+}
+
+func (c Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (g Gauge) Set(value float64, labelValues ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.get(labelValues).value = value
+}
+
+// A value of a gauge's series, identified by its label values.
+type GaugeValue struct {
+	Value       float64
+	LabelValues []string
 }
 
-var stats *statisticsData
+// Replaces all series of the gauge with the values at once, so the
+// series that are kept are never exposed missing in between.
+func (g Gauge) SetAll(values []GaugeValue) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 
-func Init() {
-	stats = &statisticsData{
-		dataMap: make(map[string]int),
+	g.series = make(map[string]*series)
+	for _, value := range values {
+		g.get(value.LabelValues).value = value.Value
 	}
 }
 
-func Set(key string, value int) {
-	stats.mutex.Lock()
-	defer stats.mutex.Unlock()
+func (h Histogram) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 
-	stats.dataMap[key] = value
+	s := h.get(labelValues)
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
 }
 
-func Change(key string, value int) {
-	stats.mutex.Lock()
-	defer stats.mutex.Unlock()
+// Writes all metrics in prometheus' text exposition format.
+func Write(w io.Writer) error {
+	registry.mutex.Lock()
+	metrics := make([]*metric, len(registry.metrics))
+	copy(metrics, registry.metrics)
+	registry.mutex.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name < metrics[j].name
+	})
 
-	stats.dataMap[key] += value
+	for _, m := range metrics {
+		if _, err := io.WriteString(w, m.expose()); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
+// Returns all metrics in prometheus' text exposition format.
 func Display() string {
-	stats.mutex.Lock()
-	defer stats.mutex.Unlock()
+	var builder strings.Builder
+	_ = Write(&builder)
+
+	return builder.String()
+}
+
+func (m *metric) expose() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# HELP %s %s\n", m.name, escape(m.help, false))
+	fmt.Fprintf(&builder, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != HISTOGRAM {
+			fmt.Fprintf(&builder, "%s%s %s\n", m.name, m.labels(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+
+		for i, bound := range m.buckets {
+			fmt.Fprintf(&builder, "%s_bucket%s %d\n", m.name, m.labels(s.labelValues, formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(&builder, "%s_bucket%s %d\n", m.name, m.labels(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(&builder, "%s_sum%s %s\n", m.name, m.labels(s.labelValues, ""), formatFloat(s.sum))
+		fmt.Fprintf(&builder, "%s_count%s %d\n", m.name, m.labels(s.labelValues, ""), s.count)
+	}
+
+	return builder.String()
+}
+
+// Formats the labels of a series, with the le label of
+// histogram buckets if it is not empty.
+func (m *metric) labels(labelValues []string, le string) string {
+	var pairs []string
+	for i, name := range m.labelNames {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escape(labelValues[i], true)))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=\"%s\"", le))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string, isLabel bool) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	if isLabel {
+		value = strings.ReplaceAll(value, `"`, `\"`)
+	}
+
+	return value
+}
 
-	result := "Statistics results are:\n"
-	for key, value := range stats.dataMap {
-		result += fmt.Sprintf("Number of %s is %d\n", key, value)
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
 	}
 
-	return result
+	return strconv.FormatFloat(value, 'g', -1, 64)
 }
@@ -0,0 +1,54 @@
+package statistics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	counter := NewCounter("test_events_total", "Number of test events.", "type")
+	counter.Inc("a")
+	counter.Add(2, "b")
+	counter.Inc("a")
+
+	gauge := NewGauge("test_value", "A \"quoted\" value.\nIn two lines.")
+	gauge.Set(0.5)
+
+	labeled := NewGauge("test_labeled", "A labeled value.", "name")
+	labeled.Set(1, "with \"quote\"")
+
+	histogram := NewHistogram("test_duration_seconds", "Duration of tests.", []float64{0.1, 1}, "operation")
+	histogram.Observe(0.05, "run")
+	histogram.Observe(0.5, "run")
+	histogram.Observe(2, "run")
+
+	exposition := Display()
+	for _, expected := range []string{
+		"# HELP test_events_total Number of test events.\n# TYPE test_events_total counter\n" +
+			"test_events_total{type=\"a\"} 2\ntest_events_total{type=\"b\"} 2\n",
+		"# HELP test_value A \"quoted\" value.\\nIn two lines.\n# TYPE test_value gauge\ntest_value 0.5\n",
+		"test_labeled{name=\"with \\\"quote\\\"\"} 1\n",
+		"# TYPE test_duration_seconds histogram\n" +
+			"test_duration_seconds_bucket{operation=\"run\",le=\"0.1\"} 1\n" +
+			"test_duration_seconds_bucket{operation=\"run\",le=\"1\"} 2\n" +
+			"test_duration_seconds_bucket{operation=\"run\",le=\"+Inf\"} 3\n" +
+			"test_duration_seconds_sum{operation=\"run\"} 2.55\n" +
+			"test_duration_seconds_count{operation=\"run\"} 3\n",
+		"ecmus_restarts_total 0\n",
+	} {
+		if !strings.Contains(exposition, expected) {
+			t.Fatalf("exposition does not contain:\n%s\ngot:\n%s", expected, exposition)
+		}
+	}
+
+	labeled.SetAll([]GaugeValue{{Value: 2, LabelValues: []string{"b"}}})
+	if exposition := Display(); strings.Contains(exposition, "test_labeled{name=\"with") ||
+		!strings.Contains(exposition, "test_labeled{name=\"b\"} 2\n") {
+		t.Fatalf("gauge should only have the series that are set all at once")
+	}
+
+	labeled.Reset()
+	if strings.Contains(Display(), "test_labeled{") {
+		t.Fatalf("reset gauge should not have any series")
+	}
+}